@@ -6,17 +6,37 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	dbpkg "netfence/internal/db"
+	"netfence/internal/app"
+	"netfence/internal/auditchain"
+	"netfence/internal/auditsink"
+	"netfence/internal/analyze"
+	"netfence/internal/confd"
+	"netfence/internal/config"
+	"netfence/internal/githist"
+	"netfence/internal/ident"
+	"netfence/internal/lint"
 	"netfence/internal/model"
+	"netfence/internal/output"
+	"netfence/internal/plan"
+	"netfence/internal/profile"
 	"netfence/internal/render"
 	"netfence/internal/repo"
+	"netfence/internal/resolve"
 	"netfence/internal/service"
+	"netfence/internal/simulate"
+	"netfence/internal/snapshot"
 	"netfence/internal/tui"
 	"netfence/internal/util"
+	"netfence/internal/vars"
 
 	"github.com/spf13/cobra"
 	_ "modernc.org/sqlite"
@@ -45,14 +65,7 @@ func ensureDB(path string) error {
 		if err := dbpkg.ApplyAll(ctx, db); err != nil {
 			return err
 		}
-		// users bootstrap
-		if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS users(name TEXT PRIMARY KEY, role TEXT)`); err != nil {
-			return err
-		}
-		if _, err := db.ExecContext(ctx, `INSERT OR IGNORE INTO users(name, role) VALUES('root','admin'),('operator','operator')`); err != nil {
-			return err
-		}
-		return nil
+		return bootstrapUsers(ctx, db)
 	}
 
 	// Файловая БД
@@ -80,27 +93,126 @@ func ensureDB(path string) error {
 	if err := dbpkg.ApplyAll(ctx, db); err != nil {
 		return err
 	}
-	// bootstrap users (на случай, если миграции этого не делают)
-	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS users(name TEXT PRIMARY KEY, role TEXT)`); err != nil {
-		return err
-	}
-	if _, err := db.ExecContext(ctx, `INSERT OR IGNORE INTO users(name, role) VALUES('root','admin'),('operator','operator')`); err != nil {
-		return err
-	}
-	return nil
+	return bootstrapUsers(ctx, db)
+}
+
+// bootstrapUsers: пустая таблица users (все удалены в обход `netfence user`)
+// — снова root/admin, иначе никто не сможет ничего изменить. Удалённые
+// через `user del` пользователи обратно не появляются.
+func bootstrapUsers(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO users(name, role) SELECT 'root','admin' WHERE NOT EXISTS (SELECT 1 FROM users)`)
+	return err
 }
 
 func main() {
 	dbPath := defaultDB
-	actor := "root"
+	var actor string
 
 	root := &cobra.Command{
-		Use:   "netfence",
-		Short: "netfence - firewall/NFT manager with SQLite and TUI",
+		Use:     "netfence",
+		Short:   "netfence - firewall/NFT manager with SQLite and TUI",
+		Version: app.Version,
+		// ошибки печатает main: текстом или JSON (-o json)
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
+	root.SetFlagErrorFunc(func(_ *cobra.Command, err error) error { return usageError{app.Invalid(err)} })
 
 	root.PersistentFlags().StringVar(&dbPath, "db", defaultDB, "path to firewall sqlite db")
-	root.PersistentFlags().StringVar(&actor, "as", "root", "actor (RBAC user)")
+	root.PersistentFlags().StringVar(&actor, "as", "", "act as another netfence user (admins only; default: the calling OS user)")
+	var dnsServer, ns, profilesDir, cfgPath string
+	root.PersistentFlags().StringVar(&cfgPath, "config", config.DefaultPath, "netfence config file (optional)")
+	root.PersistentFlags().StringVar(&profilesDir, "profiles-dir", profile.DefaultDir, "directory with application profile YAML files")
+	root.PersistentFlags().StringVar(&ns, "netns", "", "network namespace (ip netns name or path) to manage instead of the host")
+	var outFmt string
+	root.PersistentFlags().StringVarP(&outFmt, "output", "o", output.Table, "output format for listings and errors: table|json|yaml|csv")
+	var cfg config.Config
+	var hist service.HistoryExporter
+	var sinks []auditsink.Sink
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if !output.Valid(outFmt) {
+			return app.Invalidf("bad --output %q (table|json|yaml|csv)", outFmt)
+		}
+		ns = util.NetnsKey(ns)
+		util.SetNetns(ns)
+		var err error
+		if cfg, err = config.Load(cfgPath); err != nil {
+			return err
+		}
+		hist = gitHistory(cfg, ns)
+		if f := cfg.Audit.HMACKeyFile; f != "" {
+			key, err := auditchain.LoadKey(f)
+			if err != nil {
+				return err
+			}
+			repo.SetAuditKey(key)
+		}
+		if sinks, err = auditsink.FromConfig(cfg.Audit.Sinks); err != nil {
+			return app.Invalid(err)
+		}
+		// actor — настоящий пользователь ОС; другой --as только у admin-а
+		caller := ident.Current()
+		repo.SetRealActor(caller.Name, caller.UID)
+		if actor == "" || actor == caller.Name {
+			actor = caller.Name
+			return nil
+		}
+		return impersonate(dbPath, caller.Name, actor)
+	}
+	// forwardAudit отправляет недоставленные записи аудита во внешние
+	// приёмники. Ошибки приёмников только в результате: на изменение они не
+	// влияют, запись уйдёт при следующем запуске.
+	forwardAudit := func(ctx context.Context) ([]service.ForwardResult, error) {
+		if len(sinks) == 0 {
+			return nil, nil
+		}
+		if _, err := os.Stat(dbPath); err != nil {
+			return nil, nil
+		}
+		conn, err := openDB(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+			return nil, err
+		}
+		return service.AuditService{Repo: repo.AuditRepo{DB: conn}}.Forward(ctx, sinks), nil
+	}
+	// warnForward — forwardAudit с предупреждениями в stderr.
+	warnForward := func(ctx context.Context) {
+		rs, err := forwardAudit(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "warning: audit forward:", err)
+		}
+		for _, r := range rs {
+			if r.Err != nil {
+				fmt.Fprintf(os.Stderr, "warning: audit sink %s: %v (%d pending)\n", r.Sink, r.Err, r.Pending)
+			}
+		}
+	}
+	// directChange: при approval.required правила и defaults меняются только
+	// через одобренные change request-ы.
+	directChange := func() error {
+		if cfg.Approval.Required {
+			return service.ErrApprovalRequired
+		}
+		return nil
+	}
+	root.PersistentFlags().StringVar(&dnsServer, "dns-server", "", "DNS server host:port for FQDN destinations (default: from /etc/resolv.conf)")
+	var revMsg string
+	root.PersistentFlags().StringVarP(&revMsg, "message", "m", "", "message for the revision created by this change")
+
+	// recordRevision сохраняет ревизию после изменения ruleset-а; ошибка
+	// истории не отменяет уже сделанное изменение.
+	recordRevision := func(ctx context.Context, conn *sql.DB, msg string) {
+		if revMsg != "" {
+			msg = revMsg
+		}
+		if _, err := newRevisionService(conn, ns, hist).Record(ctx, actor, msg); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: revision not recorded:", err)
+		}
+	}
 
 	// --- list ---
 	var onlyEnabled bool
@@ -121,11 +233,19 @@ func main() {
 			if err := dbpkg.ApplyAll(ctx, conn); err != nil {
 				return err
 			}
-			rs, err := repo.RuleRepo{DB: conn}.List(ctx, onlyEnabled)
+			rs, err := repo.RuleRepo{DB: conn, NS: ns}.List(ctx, onlyEnabled)
 			if err != nil {
 				return err
 			}
+			if outFmt != output.Table {
+				return output.Print(os.Stdout, outFmt, output.NewRules(rs), nil)
+			}
 			printRulesTable(rs)
+			if st, err := newApplyService(conn, ns, dnsServer, hist).Status(ctx); err == nil && !st.Ever {
+				fmt.Println("\nthe ruleset has never been applied (see `netfence status`)")
+			} else if err == nil && st.PendingCount() > 0 {
+				fmt.Printf("\n%d pending change(s), not applied yet (see `netfence status`)\n", st.PendingCount())
+			}
 			return nil
 		},
 	}
@@ -149,12 +269,11 @@ func main() {
 			if err := dbpkg.ApplyAll(ctx, conn); err != nil {
 				return err
 			}
-			def, err := repo.DefaultsRepo{DB: conn}.Get(ctx)
+			def, err := repo.DefaultsRepo{DB: conn, NS: ns}.Get(ctx)
 			if err != nil {
 				return err
 			}
-			printDefaultsTable(def)
-			return nil
+			return output.Print(os.Stdout, outFmt, output.NewDefaults(def), func() { printDefaultsTable(def) })
 		},
 	}
 
@@ -188,12 +307,15 @@ func main() {
 			if err != nil {
 				return err
 			}
-			if role != "admin" {
-				return fmt.Errorf("rbac: need admin, got %s", role)
+			if err := app.Require(actor, role, "admin"); err != nil {
+				return err
+			}
+			if err := directChange(); err != nil {
+				return err
 			}
 
-			ds := service.DefaultsService{Repo: repo.DefaultsRepo{DB: conn}}
-			if err := ds.Set(ctx, model.Defaults{
+			ds := service.DefaultsService{Repo: repo.DefaultsRepo{DB: conn, NS: ns}, Audit: service.AuditService{Repo: repo.AuditRepo{DB: conn}}}
+			if err := ds.Set(ctx, actor, model.Defaults{
 				InputPolicy:   inpol,
 				ForwardPolicy: fwdpol,
 				OutputPolicy:  outpol,
@@ -202,8 +324,7 @@ func main() {
 				return err
 			}
 
-			_ = service.AuditService{Repo: repo.AuditRepo{DB: conn}}.Log(ctx, actor, "set_defaults", "defaults:1",
-				map[string]string{"input": inpol, "forward": fwdpol, "output": outpol, "log": logpref})
+			recordRevision(ctx, conn, "set defaults")
 			fmt.Println("ok")
 			return nil
 		},
@@ -244,16 +365,19 @@ func main() {
 			if err != nil {
 				return err
 			}
-			if role != "admin" && role != "operator" {
-				return fmt.Errorf("rbac: need operator or admin, got %s", role)
+			if err := app.Require(actor, role, "operator", "admin"); err != nil {
+				return err
+			}
+			if err := directChange(); err != nil {
+				return err
 			}
 
 			var prts []int
 			if ports != "" {
 				for _, p := range strings.Split(ports, ",") {
-					var v int
-					if _, e := fmt.Sscan(strings.TrimSpace(p), &v); e != nil {
-						return e
+					v, e := strconv.Atoi(strings.TrimSpace(p))
+					if e != nil {
+						return app.Invalidf("bad port %q in --ports", p)
 					}
 					prts = append(prts, v)
 				}
@@ -273,13 +397,14 @@ func main() {
 				r.Comment = &comment
 			}
 
-			rr := repo.RuleRepo{DB: conn}
+			rr := repo.RuleRepo{DB: conn, NS: ns}
 			as := service.AuditService{Repo: repo.AuditRepo{DB: conn}}
-			svc := service.RulesService{Repo: rr, Audit: as}
+			svc := service.RulesService{Repo: rr, Audit: as, Warn: warnf}
 			id, err := svc.Add(ctx, actor, r)
 			if err != nil {
 				return err
 			}
+			recordRevision(ctx, conn, fmt.Sprintf("add rule %d", id))
 			fmt.Printf("created id=%d\n", id)
 			return nil
 		},
@@ -290,8 +415,8 @@ func main() {
 	add.Flags().StringVar(&inif, "in-if", "", "incoming interface")
 	add.Flags().StringVar(&outif, "out-if", "", "outgoing interface")
 	add.Flags().StringVar(&ports, "ports", "", "csv ports e.g. 22,80,443")
-	add.Flags().StringVar(&srcs, "src", "", "csv src CIDRs")
-	add.Flags().StringVar(&dsts, "dst", "", "csv dst CIDRs")
+	add.Flags().StringVar(&srcs, "src", "", "csv src CIDRs or iface:<if>:address|network")
+	add.Flags().StringVar(&dsts, "dst", "", "csv dst CIDRs, DNS names or iface:<if>:address|network")
 	add.Flags().StringVar(&comment, "comment", "", "comment")
 	add.Flags().BoolVar(&enabled, "enabled", true, "enabled")
 
@@ -325,19 +450,46 @@ func main() {
 			if err != nil {
 				return err
 			}
-			if role != "admin" && role != "operator" {
-				return fmt.Errorf("rbac: need operator or admin, got %s", role)
+			if err := app.Require(actor, role, "operator", "admin"); err != nil {
+				return err
+			}
+			if err := directChange(); err != nil {
+				return err
 			}
 
-			var id int64
-			_, _ = fmt.Sscan(args[0], &id)
-			svc := service.RulesService{Repo: repo.RuleRepo{DB: conn}, Audit: service.AuditService{Repo: repo.AuditRepo{DB: conn}}}
-			return svc.Delete(ctx, actor, id)
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil || id <= 0 {
+				return app.Invalidf("bad rule id %q", args[0])
+			}
+			svc := service.RulesService{Repo: repo.RuleRepo{DB: conn, NS: ns}, Audit: service.AuditService{Repo: repo.AuditRepo{DB: conn}}}
+			if err := svc.Delete(ctx, actor, id); err != nil {
+				return err
+			}
+			recordRevision(ctx, conn, fmt.Sprintf("delete rule %d", id))
+			return nil
 		},
 	}
 
 	// --- export/import YAML ---
 	var path string
+	// varFiles: файлы переменных (--vars) для ${name} во входных снапшотах.
+	var varFiles []string
+	var desiredPath string // plan/sync/render-config -f
+	// confDir: вместо одного файла — фрагменты conf.d (--dir).
+	var confDir string
+	addInputFlags := func(c *cobra.Command) {
+		c.Flags().StringArrayVar(&varFiles, "vars", nil, "YAML file with variables for ${name} references (repeatable; later files win)")
+		c.Flags().StringVar(&confDir, "dir", "", "merge *.yaml fragments from a directory (e.g. "+confd.DefaultDir+") instead of --file")
+		c.MarkFlagsMutuallyExclusive("file", "dir")
+	}
+	loadSnapshot := func(file string) (model.Snapshot, error) {
+		if confDir != "" {
+			snap, err := confd.Load(confDir, varFiles)
+			return snap, app.Invalid(err)
+		}
+		snap, err := vars.Load(file, varFiles)
+		return snap, app.Invalid(err)
+	}
 	export := &cobra.Command{
 		Use:   "export",
 		Short: "Export snapshot to YAML",
@@ -356,17 +508,21 @@ func main() {
 				return err
 			}
 
-			def, _ := repo.DefaultsRepo{DB: conn}.Get(ctx)
-			rules, _ := repo.RuleRepo{DB: conn}.List(ctx, false)
-			snap := struct {
-				Defaults model.Defaults `yaml:"defaults"`
-				Rules    []model.Rule   `yaml:"rules"`
-			}{def, rules}
-			return util.WriteYAML(path, snap)
+			snap, err := newSyncService(conn, ns).Current(ctx)
+			if err != nil {
+				return err
+			}
+			// без зон секцию не пишем: такой файл зонами не управляет
+			if len(snap.Zones.List) == 0 {
+				snap.Zones = nil
+			}
+			return snapshot.Write(path, snap)
 		},
 	}
 	export.Flags().StringVar(&path, "file", "netfence.yaml", "output yaml file")
 
+	var importMode string
+	var importPrune, importDryRun bool
 	importCmd := &cobra.Command{
 		Use:   "import",
 		Short: "Import snapshot from YAML",
@@ -395,56 +551,78 @@ func main() {
 			if err != nil {
 				return err
 			}
-			if role != "admin" {
-				return fmt.Errorf("rbac: need admin")
-			}
-
-			var snap struct {
-				Defaults model.Defaults `yaml:"defaults"`
-				Rules    []model.Rule   `yaml:"rules"`
-			}
-			if err := util.ReadYAML(path, &snap); err != nil {
+			if err := app.Require(actor, role, "admin"); err != nil {
 				return err
 			}
-
-			tx, err := conn.BeginTx(ctx, nil)
+			snap, err := loadSnapshot(path)
 			if err != nil {
 				return err
 			}
-			if _, err := tx.Exec(`DELETE FROM rules`); err != nil {
-				_ = tx.Rollback()
+			sync := newSyncService(conn, ns)
+			if importDryRun {
+				p, err := sync.ImportPlan(ctx, snap, importMode, importPrune)
+				if err != nil {
+					return err
+				}
+				printPlan(p)
+				return nil
+			}
+			if err := directChange(); err != nil {
 				return err
 			}
-			if _, err := tx.Exec(`UPDATE defaults SET input_policy=?,forward_policy=?,output_policy=?,log_prefix=? WHERE id=1`,
-				snap.Defaults.InputPolicy, snap.Defaults.ForwardPolicy, snap.Defaults.OutputPolicy, snap.Defaults.LogPrefix); err != nil {
-				_ = tx.Rollback()
+
+			p, err := sync.Import(ctx, actor, snap, importMode, importPrune)
+			if err != nil {
 				return err
 			}
-			rr := repo.RuleRepo{DB: conn}
-			for i := range snap.Rules {
-				if _, err := rr.Create(ctx, &snap.Rules[i]); err != nil {
-					_ = tx.Rollback()
-					return err
+			printPlan(p)
+			if !p.Empty() {
+				src := path
+				if confDir != "" {
+					src = confDir
 				}
+				recordRevision(ctx, conn, "import "+src)
 			}
-			if err := tx.Commit(); err != nil {
-				return err
-			}
-			_ = service.AuditService{Repo: repo.AuditRepo{DB: conn}}.Log(ctx, actor, "import_yaml", "snapshot", map[string]any{"count": len(snap.Rules)})
 			fmt.Println("imported")
 			return nil
 		},
 	}
 	importCmd.Flags().StringVar(&path, "file", "netfence.yaml", "input yaml file")
+	importCmd.Flags().StringVar(&importMode, "mode", plan.ModeReplace, "replace|merge|append")
+	importCmd.Flags().BoolVar(&importPrune, "prune", false, "merge: delete rules absent from the file")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "print the changes without applying them")
+	addInputFlags(importCmd)
 
-	// --- dryrun (табличный превью) ---
-	dryrun := &cobra.Command{
-		Use:   "dryrun",
-		Short: "Preview ruleset (tables)",
+	renderCmd := &cobra.Command{
+		Use:   "render-config",
+		Short: "Print a YAML snapshot with variables expanded",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snap, err := loadSnapshot(desiredPath)
+			if err != nil {
+				return err
+			}
+			b, err := snapshot.Marshal(snap)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(b)
+			return err
+		},
+	}
+
+	// --- plan / sync (декларативно из YAML) ---
+	planCmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show changes sync would make to match a YAML snapshot",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := ensureDB(dbPath); err != nil {
 				return err
 			}
+			want, err := loadSnapshot(desiredPath)
+			if err != nil {
+				return err
+			}
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
 			conn, err := openDB(dbPath)
@@ -456,30 +634,35 @@ func main() {
 				return err
 			}
 
-			def, _ := repo.DefaultsRepo{DB: conn}.Get(ctx)
-			rules, _ := repo.RuleRepo{DB: conn}.List(ctx, true)
-			printDefaultsTable(def)
-			fmt.Println()
-			printRulesTable(rules)
+			p, err := newSyncService(conn, ns).Plan(ctx, want)
+			if err != nil {
+				return err
+			}
+			printPlan(p)
 			return nil
 		},
 	}
+	planCmd.Flags().StringVarP(&desiredPath, "file", "f", "netfence.yaml", "desired state yaml file")
+	addInputFlags(planCmd)
 
-	// --- apply ---
-	apply := &cobra.Command{
-		Use:   "apply",
-		Short: "Apply rules to nftables",
+	syncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Apply the changes shown by plan in one transaction",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := ensureDB(dbPath); err != nil {
 				return err
 			}
+			want, err := loadSnapshot(desiredPath)
+			if err != nil {
+				return err
+			}
 			lock, err := util.Acquire(lockFile)
 			if err != nil {
 				return err
 			}
 			defer lock.Release()
 
-			ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
 			conn, err := openDB(dbPath)
 			if err != nil {
@@ -494,111 +677,1991 @@ func main() {
 			if err != nil {
 				return err
 			}
-			if role != "admin" && role != "operator" {
-				return fmt.Errorf("rbac: need operator or admin, got %s", role)
+			if err := app.Require(actor, role, "admin"); err != nil {
+				return err
+			}
+			if err := directChange(); err != nil {
+				return err
 			}
 
-			def, _ := repo.DefaultsRepo{DB: conn}.Get(ctx)
-			rules, _ := repo.RuleRepo{DB: conn}.List(ctx, true)
-			script := render.Render(def, rules)
-			_, stderr, err := (util.ShellRunner{}).Run("nft", []byte(script), "-f", "-")
+			p, err := newSyncService(conn, ns).Sync(ctx, actor, want)
 			if err != nil {
-				return fmt.Errorf("nft failed: %v\n%s", err, stderr)
+				return err
+			}
+			printPlan(p)
+			if !p.Empty() {
+				recordRevision(ctx, conn, "sync "+desiredPath)
+				fmt.Println("synced")
 			}
-			_ = service.AuditService{Repo: repo.AuditRepo{DB: conn}}.Log(ctx, actor, "apply", "ruleset", map[string]int{"rules": len(rules)})
-			fmt.Println("applied")
 			return nil
 		},
 	}
+	syncCmd.Flags().StringVarP(&desiredPath, "file", "f", "netfence.yaml", "desired state yaml file")
+	addInputFlags(syncCmd)
+	renderCmd.Flags().StringVarP(&desiredPath, "file", "f", "netfence.yaml", "YAML snapshot with vars")
+	addInputFlags(renderCmd)
 
-	// --- tui ---
-	tuiCmd := &cobra.Command{
-		Use:   "tui",
-		Short: "Interactive terminal UI",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := ensureDB(dbPath); err != nil {
+	// --- история ревизий ---
+	// revisionCmd: общий каркас команд истории; write — rollback (lock + admin).
+	revisionCmd := func(write bool, fn func(ctx context.Context, svc service.RevisionService) error) error {
+		if err := ensureDB(dbPath); err != nil {
+			return err
+		}
+		if write {
+			lock, err := util.Acquire(lockFile)
+			if err != nil {
 				return err
 			}
-			ctx, cancel := context.WithCancel(context.Background())
-			defer cancel()
-			return tui.Run(ctx, dbPath, actor)
-		},
-	}
-
-	root.AddCommand(listCmd, defGet, defSet, add, del, export, importCmd, dryrun, apply, tuiCmd)
-
-	// Без аргументов — сразу TUI
-	if len(os.Args) == 1 {
-		if err := ensureDB(dbPath); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+			defer lock.Release()
 		}
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		if err := tui.Run(ctx, dbPath, actor); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+		conn, err := openDB(dbPath)
+		if err != nil {
+			return err
 		}
-		return
+		defer conn.Close()
+		if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+			return err
+		}
+		if write {
+			role, err := repo.UserRepo{DB: conn}.RoleOf(ctx, actor)
+			if err != nil {
+				return err
+			}
+			if err := app.Require(actor, role, "admin"); err != nil {
+				return err
+			}
+			if err := directChange(); err != nil {
+				return err
+			}
+		}
+		return fn(ctx, newRevisionService(conn, ns, hist))
+	}
+	parseRev := func(s string) (int64, error) {
+		var n int64
+		if _, err := fmt.Sscan(strings.TrimPrefix(s, "r"), &n); err != nil || n <= 0 {
+			return 0, app.Invalidf("bad revision %q", s)
+		}
+		return n, nil
 	}
 
-	if err := root.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	var histLimit int
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "List ruleset revisions",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return revisionCmd(false, func(ctx context.Context, svc service.RevisionService) error {
+				revs, err := svc.History(ctx, histLimit)
+				if err != nil {
+					return err
+				}
+				return output.Print(os.Stdout, outFmt, output.NewRevisions(revs), func() { printRevisionsTable(revs) })
+			})
+		},
 	}
-}
+	historyCmd.Flags().IntVar(&histLimit, "limit", 20, "show at most N revisions (0 = all)")
 
-func splitCSV(s string) []string {
-	if strings.TrimSpace(s) == "" {
-		return nil
+	showRevCmd := &cobra.Command{
+		Use:   "show-revision <n>",
+		Short: "Print a revision as a YAML snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, err := parseRev(args[0])
+			if err != nil {
+				return err
+			}
+			return revisionCmd(false, func(ctx context.Context, svc service.RevisionService) error {
+				v, err := svc.Get(ctx, n)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("# revision %d, %s by %s: %s\n", v.Rev, v.TS.Local().Format("2006-01-02 15:04:05"), v.Actor, v.Message)
+				b, err := snapshot.Marshal(v.Snapshot)
+				if err != nil {
+					return err
+				}
+				_, err = os.Stdout.Write(b)
+				return err
+			})
+		},
 	}
-	parts := strings.Split(s, ",")
-	out := make([]string, 0, len(parts))
-	for _, p := range parts {
-		p = strings.TrimSpace(p)
-		if p != "" {
-			out = append(out, p)
-		}
+
+	var diffPending bool
+	diffCmd := &cobra.Command{
+		Use:   "diff <a> <b> | diff --pending",
+		Short: "Show changes between two revisions, or what apply would change",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if diffPending {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if diffPending {
+				return revisionCmd(false, func(ctx context.Context, svc service.RevisionService) error {
+					st, err := newApplyService(svc.Repo.DB, ns, dnsServer, hist).Status(ctx)
+					if err != nil {
+						return err
+					}
+					printPendingDiff(st)
+					return nil
+				})
+			}
+			a, err := parseRev(args[0])
+			if err != nil {
+				return err
+			}
+			b, err := parseRev(args[1])
+			if err != nil {
+				return err
+			}
+			return revisionCmd(false, func(ctx context.Context, svc service.RevisionService) error {
+				p, err := svc.Diff(ctx, a, b)
+				if err != nil {
+					return err
+				}
+				printChanges(p)
+				return nil
+			})
+		},
 	}
-	return out
-}
 
-// ---------- pretty printers ----------
+	diffCmd.Flags().BoolVar(&diffPending, "pending", false, "show what apply would change: DB changes since the last apply and the nft script diff")
 
-func printRulesTable(rs []model.Rule) {
-	fmt.Println("ID  CHAIN    PROTO  ACTION  EN  IN_IF     OUT_IF    PORTS        SRC               DST               ICMP     COMMENT")
-	for _, x := range rs {
-		inIf, outIf, comment := "-", "-", "-"
-		if x.InIf != nil && *x.InIf != "" {
-			inIf = *x.InIf
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the applied revision and pending (not applied) changes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return revisionCmd(false, func(ctx context.Context, svc service.RevisionService) error {
+				st, err := newApplyService(svc.Repo.DB, ns, dnsServer, hist).Status(ctx)
+				if err != nil {
+					return err
+				}
+				return output.Print(os.Stdout, outFmt, output.NewStatus(st), func() { printStatus(st) })
+			})
+		},
+	}
+
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback <n>",
+		Short: "Restore rules, defaults and zones from a revision (run apply afterwards)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, err := parseRev(args[0])
+			if err != nil {
+				return err
+			}
+			return revisionCmd(true, func(ctx context.Context, svc service.RevisionService) error {
+				p, rev, err := svc.Rollback(ctx, actor, n)
+				if err != nil {
+					return err
+				}
+				printPlan(p)
+				if v, err := svc.Get(ctx, n); err == nil && v.Snapshot.Zones == nil {
+					warnf(fmt.Sprintf("revision %d was recorded before zones were kept in history; zones are left as they are", n))
+				}
+				if rev > 0 {
+					fmt.Printf("rolled back to revision %d (new revision %d)\n", n, rev)
+				}
+				return nil
+			})
+		},
+	}
+
+	// --- change requests (двухэтапные изменения) ---
+	requestCmd := &cobra.Command{
+		Use:   "request",
+		Short: "Change requests: submit, review and apply ruleset changes",
+	}
+	// requestDo: каркас команд request; roles — допустимые роли (пусто — любая).
+	requestDo := func(write bool, roles []string, fn func(ctx context.Context, svc service.RequestService) error) error {
+		if err := ensureDB(dbPath); err != nil {
+			return err
 		}
-		if x.OutIf != nil && *x.OutIf != "" {
-			outIf = *x.OutIf
+		if write {
+			lock, err := util.Acquire(lockFile)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
 		}
-		if x.Comment != nil && *x.Comment != "" {
-			comment = *x.Comment
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		conn, err := openDB(dbPath)
+		if err != nil {
+			return err
 		}
-		en := "-"
-		if x.Enabled {
-			en = "✓"
+		defer conn.Close()
+		if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+			return err
 		}
-		fmt.Printf("%-3d %-8s %-6s %-7s %-3s %-9s %-9s %-12s %-16s %-16s %-8s %-s\n",
-			x.ID, x.Chain, x.Proto, x.Action, en,
-			inIf, outIf,
-			intSlice(x.Ports), strSlice(x.SrcCIDRs), strSlice(x.DstCIDRs),
-			intSlice(x.ICMPTypes), comment)
+		if len(roles) > 0 {
+			role, err := repo.UserRepo{DB: conn}.RoleOf(ctx, actor)
+			if err != nil {
+				return err
+			}
+			if err := app.Require(actor, role, roles...); err != nil {
+				return err
+			}
+		}
+		return fn(ctx, service.RequestService{Repo: repo.RequestRepo{DB: conn, NS: ns}, Revisions: newRevisionService(conn, ns, hist)})
+	}
+	parseReqID := func(s string) (int64, error) {
+		var id int64
+		if _, err := fmt.Sscan(strings.TrimPrefix(s, "#"), &id); err != nil || id <= 0 {
+			return 0, app.Invalidf("bad request id %q", s)
+		}
+		return id, nil
 	}
-}
-
-func printDefaultsTable(def model.Defaults) {
-	fmt.Println("DEFAULT POLICIES")
-	fmt.Printf("%-8s %-8s %-8s %-s\n", "INPUT", "FORWARD", "OUTPUT", "LOG_PREFIX")
-	fmt.Printf("%-8s %-8s %-8s %-s\n", def.InputPolicy, def.ForwardPolicy, def.OutputPolicy, def.LogPrefix)
-}
 
-// helpers for pretty printers
-func intSlice(v []int) string {
-	if len(v) == 0 {
+	var reqFile, reqReason string
+	requestSubmit := &cobra.Command{
+		Use:   "submit",
+		Short: "Submit a desired YAML snapshot for approval",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			want, err := loadSnapshot(reqFile)
+			if err != nil {
+				return err
+			}
+			return requestDo(true, []string{"operator", "admin"}, func(ctx context.Context, svc service.RequestService) error {
+				id, p, err := svc.Submit(ctx, actor, reqReason, want)
+				if err != nil {
+					return err
+				}
+				printPlan(p)
+				fmt.Printf("submitted request %d, waiting for approval\n", id)
+				return nil
+			})
+		},
+	}
+	requestSubmit.Flags().StringVarP(&reqFile, "file", "f", "netfence.yaml", "desired state yaml file")
+	requestSubmit.Flags().StringVar(&reqReason, "reason", "", "justification (required)")
+	addInputFlags(requestSubmit)
+
+	var reqStatus string
+	requestList := &cobra.Command{
+		Use:   "list",
+		Short: "List change requests",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if reqStatus != "" && !oneOf(reqStatus, model.RequestPending, model.RequestApproved, model.RequestRejected, model.RequestApplied) {
+				return app.Invalidf("bad --status %q", reqStatus)
+			}
+			return requestDo(false, nil, func(ctx context.Context, svc service.RequestService) error {
+				crs, err := svc.List(ctx, reqStatus)
+				if err != nil {
+					return err
+				}
+				return output.Print(os.Stdout, outFmt, output.NewRequests(crs), func() { printRequestsTable(crs) })
+			})
+		},
+	}
+	requestList.Flags().StringVar(&reqStatus, "status", "", "pending|approved|rejected|applied")
+
+	requestShow := &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show a change request and what it would change now",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseReqID(args[0])
+			if err != nil {
+				return err
+			}
+			return requestDo(false, nil, func(ctx context.Context, svc service.RequestService) error {
+				cr, p, err := svc.Show(ctx, id)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("request %d: %s\n", cr.ID, cr.Status)
+				fmt.Printf("author:   %s at %s\n", cr.AuthorWho(), cr.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+				fmt.Printf("reason:   %s\n", cr.Reason)
+				fmt.Printf("base:     revision %d\n", cr.BaseRevision)
+				if cr.Reviewer != "" {
+					fmt.Printf("reviewer: %s at %s %s\n", cr.ReviewerWho(), cr.ReviewedAt.Local().Format("2006-01-02 15:04:05"), cr.Note)
+				}
+				fmt.Println()
+				printChanges(p)
+				return nil
+			})
+		},
+	}
+
+	var reqNote string
+	reviewCmd := func(use, done, short string, approve bool) *cobra.Command {
+		c := &cobra.Command{
+			Use:   use + " <id>",
+			Short: short,
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				id, err := parseReqID(args[0])
+				if err != nil {
+					return err
+				}
+				return requestDo(true, []string{"admin"}, func(ctx context.Context, svc service.RequestService) error {
+					if approve {
+						err = svc.Approve(ctx, actor, id, reqNote)
+					} else {
+						err = svc.Reject(ctx, actor, id, reqNote)
+					}
+					if err != nil {
+						return err
+					}
+					fmt.Printf("request %d %s\n", id, done)
+					return nil
+				})
+			},
+		}
+		c.Flags().StringVar(&reqNote, "note", "", "review note")
+		return c
+	}
+	requestApprove := reviewCmd("approve", "approved", "Approve a pending request (admin, not the author)", true)
+	requestReject := reviewCmd("reject", "rejected", "Reject a pending request (admin)", false)
+
+	requestApply := &cobra.Command{
+		Use:   "apply <id>",
+		Short: "Write an approved request to the DB (run `netfence apply` to load it)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseReqID(args[0])
+			if err != nil {
+				return err
+			}
+			return requestDo(true, []string{"operator", "admin"}, func(ctx context.Context, svc service.RequestService) error {
+				p, err := svc.Apply(ctx, actor, id)
+				if err != nil {
+					return err
+				}
+				printPlan(p)
+				fmt.Printf("request %d applied to the DB\n", id)
+				return nil
+			})
+		},
+	}
+	requestCmd.AddCommand(requestSubmit, requestList, requestShow, requestApprove, requestReject, requestApply)
+
+	// --- журнал аудита ---
+	// auditDo: каркас команд audit (только чтение).
+	auditDo := func(fn func(ctx context.Context, svc service.AuditService) error) error {
+		if err := ensureDB(dbPath); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		conn, err := openDB(dbPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+			return err
+		}
+		return fn(ctx, service.AuditService{Repo: repo.AuditRepo{DB: conn}})
+	}
+	var auditF repo.AuditFilter
+	var auditSince, auditUntil string
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Query the audit log",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f := auditF
+			now := time.Now()
+			var err error
+			if auditSince != "" {
+				if f.Since, err = util.ParseTime(auditSince, now); err != nil {
+					return app.Invalidf("--since: %v", err)
+				}
+			}
+			if auditUntil != "" {
+				if f.Until, err = util.ParseTime(auditUntil, now); err != nil {
+					return app.Invalidf("--until: %v", err)
+				}
+			}
+			return auditDo(func(ctx context.Context, svc service.AuditService) error {
+				es, err := svc.List(ctx, f)
+				if err != nil {
+					return err
+				}
+				return output.Print(os.Stdout, outFmt, output.NewAuditEntries(es), func() { printAuditTable(es) })
+			})
+		},
+	}
+	auditCmd.Flags().StringVar(&auditF.Actor, "actor", "", "only entries by this user")
+	auditCmd.Flags().StringVar(&auditF.Action, "action", "", "only this action (add_rule, apply, import_yaml, ...)")
+	auditCmd.Flags().StringVar(&auditF.Object, "object", "", "object: rule:42 exactly, or rule for all rules")
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "from time: RFC3339, YYYY-MM-DD[ HH:MM[:SS]] or ago: 90m, 24h, 7d")
+	auditCmd.Flags().StringVar(&auditUntil, "until", "", "up to time, same formats as --since")
+	auditCmd.Flags().IntVar(&auditF.Limit, "limit", 50, "show at most N entries (0 = all)")
+
+	auditVerify := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the audit log hash chain for altered or deleted entries",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return auditDo(func(ctx context.Context, svc service.AuditService) error {
+				res, err := svc.Verify(ctx)
+				if err != nil {
+					return err
+				}
+				if err := output.Print(os.Stdout, outFmt, output.NewAuditVerify(res), func() { printAuditVerify(res) }); err != nil {
+					return err
+				}
+				if len(res.Problems) > 0 {
+					return exitError{code: app.ExitFindings}
+				}
+				return nil
+			})
+		},
+	}
+
+	var auditAll bool
+	auditShow := &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show an audit entry with a field-level diff of the changed object",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil || id <= 0 {
+				return app.Invalidf("bad audit entry id %q", args[0])
+			}
+			return auditDo(func(ctx context.Context, svc service.AuditService) error {
+				e, err := svc.Get(ctx, id)
+				if err != nil {
+					return err
+				}
+				fields, info, ok := service.ChangeDiff(e.Details)
+				return output.Print(os.Stdout, outFmt, output.NewAuditShow(e, fields, info), func() { printAuditEntry(e, fields, info, ok, auditAll) })
+			})
+		},
+	}
+	auditShow.Flags().BoolVar(&auditAll, "all", false, "also list unchanged fields")
+
+	auditForward := &cobra.Command{
+		Use:   "forward",
+		Short: "Send pending audit entries to the configured sinks now",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(sinks) == 0 {
+				return app.Invalidf("no audit sinks configured (audit.sinks in %s)", cfgPath)
+			}
+			if err := ensureDB(dbPath); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := forwardAudit(ctx)
+			if err != nil {
+				return err
+			}
+			if err := output.Print(os.Stdout, outFmt, output.NewAuditSinks(rs), func() { printAuditSinks(rs) }); err != nil {
+				return err
+			}
+			for _, r := range rs {
+				if r.Err != nil {
+					return exitError{code: 1}
+				}
+			}
+			return nil
+		},
+	}
+
+	var pruneAge, pruneArchive string
+	var pruneRows int
+	var pruneDry bool
+	auditPrune := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete audit entries beyond the retention policy, archiving them first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pol := cfg.Audit.Retention
+			if cmd.Flags().Changed("max-age") {
+				pol.MaxAge = pruneAge
+			}
+			if cmd.Flags().Changed("max-rows") {
+				pol.MaxRows = pruneRows
+			}
+			if cmd.Flags().Changed("archive-dir") {
+				pol.ArchiveDir = pruneArchive
+			}
+			ret, err := retention(pol)
+			if err != nil {
+				return app.Invalid(err)
+			}
+			if !ret.Set() {
+				return app.Invalidf("no retention policy: pass --max-age/--max-rows or set audit.retention in %s", cfgPath)
+			}
+			if err := ensureDB(dbPath); err != nil {
+				return err
+			}
+			lock, err := util.Acquire(lockFile)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			conn, err := openDB(dbPath)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+				return err
+			}
+			if !pruneDry {
+				role, err := repo.UserRepo{DB: conn}.RoleOf(ctx, actor)
+				if err != nil {
+					return err
+				}
+				if err := app.Require(actor, role, "admin"); err != nil {
+					return err
+				}
+			}
+			res, err := service.AuditService{Repo: repo.AuditRepo{DB: conn}}.Prune(ctx, actor, ret, sinkNames(sinks), time.Now(), pruneDry)
+			if err != nil {
+				return err
+			}
+			return output.Print(os.Stdout, outFmt, output.NewAuditPrune(res, pruneDry), func() { printAuditPrune(res, pruneDry) })
+		},
+	}
+	auditPrune.Flags().StringVar(&pruneAge, "max-age", "", "delete entries older than this: 90d, 36h (default: audit.retention.max_age)")
+	auditPrune.Flags().IntVar(&pruneRows, "max-rows", 0, "keep at most N entries (default: audit.retention.max_rows)")
+	auditPrune.Flags().StringVar(&pruneArchive, "archive-dir", "", "write deleted entries to a .jsonl.gz file here first (default: audit.retention.archive_dir)")
+	auditPrune.Flags().BoolVar(&pruneDry, "dry-run", false, "only show what would be deleted")
+	auditCmd.AddCommand(auditVerify, auditShow, auditForward, auditPrune)
+
+	// --- dryrun (табличный превью) ---
+	dryrun := &cobra.Command{
+		Use:   "dryrun",
+		Short: "Preview ruleset (tables)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureDB(dbPath); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := openDB(dbPath)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+				return err
+			}
+
+			def, _ := repo.DefaultsRepo{DB: conn, NS: ns}.Get(ctx)
+			rules, _ := repo.RuleRepo{DB: conn, NS: ns}.List(ctx, true)
+			pv := output.Preview{Defaults: output.NewDefaults(def), Rules: output.NewRules(rules)}
+			return output.Print(os.Stdout, outFmt, pv, func() {
+				printDefaultsTable(def)
+				fmt.Println()
+				printRulesTable(rules)
+			})
+		},
+	}
+
+	// --- analyze ---
+	var anChain string
+	analyzeCmd := &cobra.Command{
+		Use:   "analyze",
+		Short: "Find shadowed, redundant and conflicting rules",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureDB(dbPath); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := openDB(dbPath)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+				return err
+			}
+
+			rules, err := repo.RuleRepo{DB: conn, NS: ns}.List(ctx, true)
+			if err != nil {
+				return err
+			}
+			var fs []analyze.Finding
+			for _, f := range analyze.Analyze(rules) {
+				if anChain == "" || f.Chain == anChain {
+					fs = append(fs, f)
+				}
+			}
+			return output.Print(os.Stdout, outFmt, output.NewFindings(fs), func() {
+				if len(fs) == 0 {
+					fmt.Println("no issues found")
+					return
+				}
+				printFindingsTable(fs)
+			})
+		},
+	}
+	analyzeCmd.Flags().StringVar(&anChain, "chain", "", "only this chain (input|forward|output)")
+
+	// --- lint ---
+	var lintFile, lintFormat, lintFailOn string
+	lintCmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check the ruleset (DB or YAML snapshot) for risky patterns",
+		Long:  "Exit codes: 0 — no issues at or above --fail-on, 2 — such issues found, 1 — lint could not run.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !oneOf(lintFormat, "text", "json") {
+				return app.Invalidf("bad --format %q (text|json)", lintFormat)
+			}
+			if lint.Rank(lintFailOn) < 0 {
+				return app.Invalidf("bad --fail-on %q (info|warning|error)", lintFailOn)
+			}
+			if err := lint.Validate(cfg.Lint); err != nil {
+				return err
+			}
+
+			var snap model.Snapshot
+			if lintFile != "" || confDir != "" {
+				var err error
+				if snap, err = loadSnapshot(lintFile); err != nil {
+					return err
+				}
+			} else {
+				if err := ensureDB(dbPath); err != nil {
+					return err
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				conn, err := openDB(dbPath)
+				if err != nil {
+					return err
+				}
+				defer conn.Close()
+				if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+					return err
+				}
+				if snap.Defaults, err = (repo.DefaultsRepo{DB: conn, NS: ns}).Get(ctx); err != nil {
+					return err
+				}
+				if snap.Rules, err = (repo.RuleRepo{DB: conn, NS: ns}).List(ctx, false); err != nil {
+					return err
+				}
+			}
+
+			issues := lint.Run(snap, lint.Env{IfExists: util.IfExists, Now: time.Now()}, cfg.Lint)
+			format := outFmt
+			if lintFormat == "json" { // --format json — то же, что -o json
+				format = output.JSON
+			}
+			if err := output.Print(os.Stdout, format, output.NewIssues(issues), func() { printLintIssues(issues) }); err != nil {
+				return err
+			}
+			for _, is := range issues {
+				if lint.Rank(is.Severity) >= lint.Rank(lintFailOn) {
+					return exitError{code: app.ExitFindings}
+				}
+			}
+			return nil
+		},
+	}
+	lintCmd.Flags().StringVar(&lintFile, "file", "", "lint a YAML snapshot instead of the DB")
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "text|json (same as -o json)")
+	lintCmd.Flags().StringVar(&lintFailOn, "fail-on", lint.Warning, "lowest severity that fails: info|warning|error")
+	addInputFlags(lintCmd)
+
+	// --- apply ---
+	apply := &cobra.Command{
+		Use:   "apply",
+		Short: "Apply rules to nftables",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureDB(dbPath); err != nil {
+				return err
+			}
+			lock, err := util.Acquire(lockFile)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+			defer cancel()
+			conn, err := openDB(dbPath)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+				return err
+			}
+
+			role, err := repo.UserRepo{DB: conn}.RoleOf(ctx, actor)
+			if err != nil {
+				return err
+			}
+			if err := app.Require(actor, role, "operator", "admin"); err != nil {
+				return err
+			}
+
+			if err := newApplyService(conn, ns, dnsServer, hist).Apply(ctx, actor); err != nil {
+				return err
+			}
+			fmt.Println("applied")
+			return nil
+		},
+	}
+
+	// --- simulate: вердикт для пакета без обращения к ядру ---
+	var simIn, simOut, simSrc, simDst, simProto, simChain string
+	var simPort, simICMP int
+	simulateCmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Show whether a packet would be allowed by the stored ruleset",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pkt, err := simulate.ParsePacket(simIn, simOut, simSrc, simDst, simProto, simPort, simICMP)
+			if err != nil {
+				return err
+			}
+			chainName := simChain
+			if chainName == "" {
+				chainName = simulate.ChainFor(pkt)
+			}
+			if !oneOf(chainName, "input", "forward", "output") {
+				return app.Invalidf("invalid chain %q (use input|forward|output)", chainName)
+			}
+			if err := ensureDB(dbPath); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := openDB(dbPath)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+				return err
+			}
+			rs, err := newApplyService(conn, ns, dnsServer, hist).Ruleset(ctx)
+			if err != nil {
+				return err
+			}
+			v := simulate.Evaluate(rs, chainName, pkt)
+			fmt.Printf("verdict: %s\n", v.Action)
+			fmt.Printf("chain:   %s\n", v.Chain)
+			if v.RuleID != 0 {
+				fmt.Printf("rule:    %d\n", v.RuleID)
+			} else {
+				fmt.Printf("matched: %s\n", v.Reason)
+			}
+			return nil
+		},
+	}
+	simulateCmd.Flags().StringVar(&simIn, "in-if", "", "incoming interface")
+	simulateCmd.Flags().StringVar(&simOut, "out-if", "", "outgoing interface")
+	simulateCmd.Flags().StringVar(&simSrc, "src", "", "source IPv4 address")
+	simulateCmd.Flags().StringVar(&simDst, "dst", "", "destination IPv4 address")
+	simulateCmd.Flags().StringVar(&simProto, "proto", "tcp", "tcp|udp|icmp")
+	simulateCmd.Flags().IntVar(&simPort, "dport", 0, "destination port (tcp/udp)")
+	simulateCmd.Flags().IntVar(&simICMP, "icmp-type", -1, "icmp type (icmp)")
+	simulateCmd.Flags().StringVar(&simChain, "chain", "", "input|forward|output (default: derived from --in-if/--out-if)")
+
+	// --- daemon: следит за адресами интерфейсов и переприменяет ruleset ---
+	daemon := &cobra.Command{
+		Use:   "daemon",
+		Short: "Watch interface addresses and DNS names, keep the ruleset in sync",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureDB(dbPath); err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			conn, err := openDB(dbPath)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+				return err
+			}
+
+			role, err := repo.UserRepo{DB: conn}.RoleOf(ctx, actor)
+			if err != nil {
+				return err
+			}
+			if err := app.Require(actor, role, "operator", "admin"); err != nil {
+				return err
+			}
+
+			reapply := func(reason string) {
+				lock, err := util.Acquire(lockFile)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "lock:", err)
+					return
+				}
+				defer lock.Release()
+				actx, cancel := context.WithTimeout(ctx, 8*time.Second)
+				defer cancel()
+				if err := newApplyService(conn, ns, dnsServer, hist).Apply(actx, actor); err != nil {
+					fmt.Fprintf(os.Stderr, "apply (%s): %v\n", reason, err)
+					return
+				}
+				fmt.Printf("applied (%s)\n", reason)
+			}
+			reapply("startup")
+
+			// DNS-имена: обновляем множества на месте по истечении TTL
+			fqdn := newFQDNService(conn, ns, dnsServer, util.NetnsRunner{NS: ns, Runner: util.ShellRunner{}})
+			// под тем же lock-ом, что apply/sync: множества и кеш меняются
+			// не посреди чужого изменения ruleset-а
+			refresh := func() time.Duration {
+				lock, err := util.Acquire(lockFile)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "lock:", err)
+					return 30 * time.Second
+				}
+				defer lock.Release()
+				rctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+				defer cancel()
+				next, err := fqdn.Refresh(rctx, false)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "fqdn refresh:", err)
+				}
+				if d := time.Until(next); d > time.Second {
+					return d
+				}
+				return time.Second
+			}
+			fqdnTimer := time.NewTimer(refresh())
+			defer fqdnTimer.Stop()
+
+			// аудит: недоставленные записи уходят в приёмники в фоне
+			forwardTick := time.NewTicker(auditForwardEvery)
+			defer forwardTick.Stop()
+			forward := func() {
+				fctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+				defer cancel()
+				warnForward(fctx)
+			}
+			forward()
+
+			// очистка журнала по audit.retention: при старте и раз в час
+			ret, err := retention(cfg.Audit.Retention)
+			if err != nil {
+				return app.Invalid(err)
+			}
+			pruneTick := time.NewTicker(auditPruneEvery)
+			defer pruneTick.Stop()
+			prune := func() {
+				if !ret.Set() {
+					return
+				}
+				pctx, cancel := context.WithTimeout(ctx, time.Minute)
+				defer cancel()
+				res, err := service.AuditService{Repo: repo.AuditRepo{DB: conn}}.Prune(pctx, actor, ret, sinkNames(sinks), time.Now(), false)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "audit prune:", err)
+					return
+				}
+				if res.Deleted > 0 {
+					fmt.Printf("audit: pruned %d entries (#%d..#%d)\n", res.Deleted, res.FirstID, res.LastID)
+				}
+			}
+			prune()
+
+			changed := make(chan string, 64)
+			errc := make(chan error, 1)
+			go func() {
+				errc <- util.WatchAddrs(ctx, func(name string) {
+					select {
+					case changed <- name:
+					default:
+					}
+				})
+			}()
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case err := <-errc:
+					return err
+				case <-fqdnTimer.C:
+					fqdnTimer.Reset(refresh())
+				case <-forwardTick.C:
+					forward()
+				case <-pruneTick.C:
+					prune()
+				case name := <-changed:
+					// собираем пачку событий (DHCP меняет адрес в несколько шагов)
+					names := map[string]bool{name: true}
+					debounce := time.After(500 * time.Millisecond)
+				collect:
+					for {
+						select {
+						case n := <-changed:
+							names[n] = true
+						case <-debounce:
+							break collect
+						}
+					}
+					rules, err := repo.RuleRepo{DB: conn, NS: ns}.List(ctx, true)
+					if err != nil {
+						fmt.Fprintln(os.Stderr, "list rules:", err)
+						continue
+					}
+					refs := render.IfaceRefs(rules)
+					for n := range names {
+						if refs[n] {
+							reapply("address change on " + n)
+							break
+						}
+					}
+				}
+			}
+		},
+	}
+
+	// --- fqdn: DNS-имена в dst правил ---
+	fqdnCmd := &cobra.Command{
+		Use:   "fqdn",
+		Short: "DNS-name destinations resolved into nft sets",
+	}
+	fqdnList := &cobra.Command{
+		Use:   "list",
+		Short: "Show resolved addresses of DNS-name destinations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureDB(dbPath); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := openDB(dbPath)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+				return err
+			}
+			es, err := newFQDNService(conn, ns, dnsServer, nil).Entries(ctx)
+			if err != nil {
+				return err
+			}
+			return output.Print(os.Stdout, outFmt, output.NewFQDNs(es), func() { printFQDNTable(es) })
+		},
+	}
+	var fqdnForce bool
+	fqdnRefresh := &cobra.Command{
+		Use:   "refresh",
+		Short: "Resolve DNS names and update nft sets in place",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureDB(dbPath); err != nil {
+				return err
+			}
+			lock, err := util.Acquire(lockFile)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			conn, err := openDB(dbPath)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+				return err
+			}
+
+			role, err := repo.UserRepo{DB: conn}.RoleOf(ctx, actor)
+			if err != nil {
+				return err
+			}
+			if err := app.Require(actor, role, "operator", "admin"); err != nil {
+				return err
+			}
+
+			svc := newFQDNService(conn, ns, dnsServer, util.NetnsRunner{NS: ns, Runner: util.ShellRunner{}})
+			_, rerr := svc.Refresh(ctx, fqdnForce)
+			es, err := svc.Entries(ctx)
+			if err != nil {
+				return err
+			}
+			if err := output.Print(os.Stdout, outFmt, output.NewFQDNs(es), func() { printFQDNTable(es) }); err != nil {
+				return err
+			}
+			return rerr
+		},
+	}
+	fqdnRefresh.Flags().BoolVar(&fqdnForce, "force", false, "re-resolve all names, ignoring TTL")
+	fqdnCmd.AddCommand(fqdnList, fqdnRefresh)
+
+	// --- zones ---
+	zoneCmd := &cobra.Command{
+		Use:   "zone",
+		Short: "Zones: interface groups with input and zone-to-zone forward policies",
+	}
+	zoneList := &cobra.Command{
+		Use:   "list",
+		Short: "List zones and the forward policy matrix",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureDB(dbPath); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := openDB(dbPath)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+				return err
+			}
+			zs, ps, err := service.ZoneService{Repo: repo.ZoneRepo{DB: conn, NS: ns}}.List(ctx)
+			if err != nil {
+				return err
+			}
+			return output.Print(os.Stdout, outFmt, output.NewZones(zs, ps), func() { printZonesTable(zs, ps) })
+		},
+	}
+	// общая обвязка изменяющих zone-команд: БД, lock, RBAC (зоны — политики, нужен admin)
+	zoneMutate := func(msg string, fn func(ctx context.Context, svc service.ZoneService) error) error {
+		if err := ensureDB(dbPath); err != nil {
+			return err
+		}
+		lock, err := util.Acquire(lockFile)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn, err := openDB(dbPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+			return err
+		}
+
+		role, err := repo.UserRepo{DB: conn}.RoleOf(ctx, actor)
+		if err != nil {
+			return err
+		}
+		if err := app.Require(actor, role, "admin"); err != nil {
+			return err
+		}
+		// политики зон и матрица forward — часть ruleset-а
+		if err := directChange(); err != nil {
+			return err
+		}
+		svc := service.ZoneService{Repo: repo.ZoneRepo{DB: conn, NS: ns}, Audit: service.AuditService{Repo: repo.AuditRepo{DB: conn}}}
+		if err := fn(ctx, svc); err != nil {
+			return err
+		}
+		recordRevision(ctx, conn, msg)
+		fmt.Println("ok")
+		return nil
+	}
+	var zoneInput, zoneIfs string
+	zoneAdd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Create a zone (or update its input policy) and add interfaces to it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return zoneMutate("zone "+cmd.Name()+" "+strings.Join(args, " "), func(ctx context.Context, svc service.ZoneService) error {
+				return svc.Save(ctx, actor, model.Zone{Name: args[0], InputPolicy: strings.ToLower(zoneInput), Ifaces: splitCSV(zoneIfs)})
+			})
+		},
+	}
+	zoneAdd.Flags().StringVar(&zoneInput, "input", "drop", "input policy for traffic entering via the zone (accept|drop)")
+	zoneAdd.Flags().StringVar(&zoneIfs, "if", "", "csv interfaces to put into the zone")
+	zoneDel := &cobra.Command{
+		Use:   "del <name>",
+		Short: "Delete a zone with its interfaces and forward policies",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return zoneMutate("zone "+cmd.Name()+" "+strings.Join(args, " "), func(ctx context.Context, svc service.ZoneService) error {
+				return svc.Delete(ctx, actor, args[0])
+			})
+		},
+	}
+	zoneAddIf := &cobra.Command{
+		Use:   "add-if <zone> <iface>",
+		Short: "Move an interface into a zone",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return zoneMutate("zone "+cmd.Name()+" "+strings.Join(args, " "), func(ctx context.Context, svc service.ZoneService) error {
+				return svc.AddIface(ctx, actor, args[0], args[1])
+			})
+		},
+	}
+	zoneDelIf := &cobra.Command{
+		Use:   "del-if <zone> <iface>",
+		Short: "Remove an interface from a zone",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return zoneMutate("zone "+cmd.Name()+" "+strings.Join(args, " "), func(ctx context.Context, svc service.ZoneService) error {
+				return svc.DelIface(ctx, actor, args[0], args[1])
+			})
+		},
+	}
+	zoneFwd := &cobra.Command{
+		Use:   "forward <from> <to> <accept|drop|none>",
+		Short: "Set the forward policy from one zone to another (none removes it)",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return zoneMutate("zone "+cmd.Name()+" "+strings.Join(args, " "), func(ctx context.Context, svc service.ZoneService) error {
+				return svc.SetPolicy(ctx, actor, model.ZonePolicy{From: args[0], To: args[1], Action: strings.ToLower(args[2])})
+			})
+		},
+	}
+	zoneCmd.AddCommand(zoneList, zoneAdd, zoneDel, zoneAddIf, zoneDelIf, zoneFwd)
+
+	// --- пользователи и роли ---
+	userCmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage netfence users (OS user names) and their roles",
+	}
+	// userDo: БД, RBAC admin; изменения — под lock-ом
+	userDo := func(mutate bool, fn func(ctx context.Context, svc service.UserService) error) error {
+		if err := ensureDB(dbPath); err != nil {
+			return err
+		}
+		if mutate {
+			lock, err := util.Acquire(lockFile)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn, err := openDB(dbPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+			return err
+		}
+		users := repo.UserRepo{DB: conn}
+		role, err := users.RoleOf(ctx, actor)
+		if err != nil {
+			return err
+		}
+		if err := app.Require(actor, role, model.RoleAdmin); err != nil {
+			return err
+		}
+		return fn(ctx, service.UserService{Repo: users, Audit: service.AuditService{Repo: repo.AuditRepo{DB: conn}}})
+	}
+	userList := &cobra.Command{
+		Use:   "list",
+		Short: "List users and roles",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userDo(false, func(ctx context.Context, svc service.UserService) error {
+				us, err := svc.List(ctx)
+				if err != nil {
+					return err
+				}
+				return output.Print(os.Stdout, outFmt, output.NewUsers(us), func() { printUsersTable(us) })
+			})
+		},
+	}
+	var userRole string
+	userAdd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add a user (an OS user name) with a role",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userDo(true, func(ctx context.Context, svc service.UserService) error {
+				if err := svc.Add(ctx, actor, model.User{Name: args[0], Role: strings.ToLower(userRole)}); err != nil {
+					return err
+				}
+				fmt.Printf("user %s added (%s)\n", args[0], strings.ToLower(userRole))
+				return nil
+			})
+		},
+	}
+	userAdd.Flags().StringVar(&userRole, "role", model.RoleViewer, "role: admin|operator|viewer")
+	userDel := &cobra.Command{
+		Use:   "del <name>",
+		Short: "Delete a user (the last admin cannot be deleted)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userDo(true, func(ctx context.Context, svc service.UserService) error {
+				if err := svc.Delete(ctx, actor, args[0]); err != nil {
+					return err
+				}
+				fmt.Printf("user %s deleted\n", args[0])
+				return nil
+			})
+		},
+	}
+	userSetRole := &cobra.Command{
+		Use:   "set-role <name> <role>",
+		Short: "Change a user's role (admin|operator|viewer)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userDo(true, func(ctx context.Context, svc service.UserService) error {
+				role := strings.ToLower(args[1])
+				if err := svc.SetRole(ctx, actor, args[0], role); err != nil {
+					return err
+				}
+				fmt.Printf("user %s is now %s\n", args[0], role)
+				return nil
+			})
+		},
+	}
+	userCmd.AddCommand(userList, userAdd, userDel, userSetRole)
+
+	// --- allow/deny <profile>: правила из профилей приложений ---
+	profileRuleCmd := func(use, action string) *cobra.Command {
+		var from, to, chain, inif, comment string
+		c := &cobra.Command{
+			Use:   use + " <profile>",
+			Short: strings.ToUpper(action[:1]) + action[1:] + " traffic of an application profile (e.g. ssh, https)",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := ensureDB(dbPath); err != nil {
+					return err
+				}
+				lock, err := util.Acquire(lockFile)
+				if err != nil {
+					return err
+				}
+				defer lock.Release()
+
+				ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+				defer cancel()
+				conn, err := openDB(dbPath)
+				if err != nil {
+					return err
+				}
+				defer conn.Close()
+				if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+					return err
+				}
+
+				role, err := repo.UserRepo{DB: conn}.RoleOf(ctx, actor)
+				if err != nil {
+					return err
+				}
+				if err := app.Require(actor, role, "operator", "admin"); err != nil {
+					return err
+				}
+				if err := directChange(); err != nil {
+					return err
+				}
+
+				cat, err := profile.Load(profilesDir)
+				if err != nil {
+					return err
+				}
+				tmpl := model.Rule{Chain: chain, Action: action, Enabled: true, DstCIDRs: splitCSV(to)}
+				if comment != "" {
+					tmpl.Comment = &comment
+				}
+				// --from: имя зоны (по правилу на каждый её интерфейс) или csv адресов
+				tmpls := []model.Rule{tmpl}
+				if z, zerr := (repo.ZoneRepo{DB: conn, NS: ns}).Get(ctx, from); from != "" && zerr == nil {
+					if inif != "" {
+						return fmt.Errorf("--from zone %q and --in-if are mutually exclusive", from)
+					}
+					if len(z.Ifaces) == 0 {
+						return fmt.Errorf("zone %q has no interfaces", from)
+					}
+					tmpls = tmpls[:0]
+					for _, ifname := range z.Ifaces {
+						t := tmpl
+						t.InIf = &ifname
+						tmpls = append(tmpls, t)
+					}
+				} else {
+					tmpls[0].SrcCIDRs = splitCSV(from)
+					if inif != "" {
+						tmpls[0].InIf = &inif
+					}
+				}
+
+				svc := service.ProfileService{
+					Rules:   service.RulesService{Repo: repo.RuleRepo{DB: conn, NS: ns}, Audit: service.AuditService{Repo: repo.AuditRepo{DB: conn}}, Warn: warnf},
+					Catalog: cat,
+				}
+				ids, err := svc.Allow(ctx, actor, args[0], tmpls...)
+				if err != nil {
+					return err
+				}
+				for _, id := range ids {
+					fmt.Printf("created id=%d\n", id)
+				}
+				recordRevision(ctx, conn, use+" "+args[0])
+				return nil
+			},
+		}
+		c.Flags().StringVar(&from, "from", "", "zone name or csv of source CIDRs / iface:<if>:address|network")
+		c.Flags().StringVar(&to, "to", "", "csv destination CIDRs / DNS names / iface refs")
+		c.Flags().StringVar(&chain, "chain", "input", "input|forward|output")
+		c.Flags().StringVar(&inif, "in-if", "", "incoming interface")
+		c.Flags().StringVar(&comment, "comment", "", "comment")
+		return c
+	}
+	allowCmd := profileRuleCmd("allow", "accept")
+	denyCmd := profileRuleCmd("deny", "drop")
+
+	profileCmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Application profiles catalog (built-in and " + profile.DefaultDir + ")",
+	}
+	profileList := &cobra.Command{
+		Use:   "list",
+		Short: "List available profiles",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cat, err := profile.Load(profilesDir)
+			if err != nil {
+				return err
+			}
+			return output.Print(os.Stdout, outFmt, output.NewProfiles(cat), func() { printProfilesTable(cat) })
+		},
+	}
+	// общая обвязка изменяющих profile-команд: БД, lock, RBAC operator/admin
+	profileMutate := func(msg string, fn func(ctx context.Context, svc service.ProfileService) error) error {
+		if err := ensureDB(dbPath); err != nil {
+			return err
+		}
+		lock, err := util.Acquire(lockFile)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		conn, err := openDB(dbPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err := dbpkg.ApplyAll(ctx, conn); err != nil {
+			return err
+		}
+
+		role, err := repo.UserRepo{DB: conn}.RoleOf(ctx, actor)
+		if err != nil {
+			return err
+		}
+		if err := app.Require(actor, role, "operator", "admin"); err != nil {
+			return err
+		}
+		if err := directChange(); err != nil {
+			return err
+		}
+		cat, err := profile.Load(profilesDir)
+		if err != nil {
+			return err
+		}
+		defer recordRevision(ctx, conn, msg)
+		return fn(ctx, service.ProfileService{
+			Rules:   service.RulesService{Repo: repo.RuleRepo{DB: conn, NS: ns}, Audit: service.AuditService{Repo: repo.AuditRepo{DB: conn}}, Warn: warnf},
+			Catalog: cat,
+		})
+	}
+	profileRemove := &cobra.Command{
+		Use:   "remove <profile>",
+		Short: "Delete all rules created from a profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return profileMutate("profile remove "+args[0], func(ctx context.Context, svc service.ProfileService) error {
+				n, err := svc.Remove(ctx, actor, args[0])
+				if err != nil {
+					return err
+				}
+				fmt.Printf("deleted %d rule(s)\n", n)
+				return nil
+			})
+		},
+	}
+	profileSync := &cobra.Command{
+		Use:   "sync",
+		Short: "Re-expand profile rules after profiles were updated or removed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return profileMutate("profile sync", func(ctx context.Context, svc service.ProfileService) error {
+				n, err := svc.Sync(ctx, actor)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("updated %d rule group(s)\n", n)
+				return nil
+			})
+		},
+	}
+	profileCmd.AddCommand(profileList, profileRemove, profileSync)
+
+	// --- tui ---
+	tuiCmd := &cobra.Command{
+		Use:   "tui",
+		Short: "Interactive terminal UI",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureDB(dbPath); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if len(sinks) > 0 {
+				go func() {
+					t := time.NewTicker(auditForwardEvery)
+					defer t.Stop()
+					for {
+						select {
+						case <-ctx.Done():
+							return
+						case <-t.C:
+							fctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+							// stderr занят экраном TUI: ошибки видны в audit forward
+							_, _ = forwardAudit(fctx)
+							cancel()
+						}
+					}
+				}()
+			}
+			return tui.Run(ctx, dbPath, actor, ns, cfg)
+		},
+	}
+
+	root.AddCommand(listCmd, defGet, defSet, add, del, allowCmd, denyCmd, profileCmd, export, importCmd, renderCmd, planCmd, syncCmd, historyCmd, showRevCmd, diffCmd, rollbackCmd, statusCmd, requestCmd, auditCmd, dryrun, simulateCmd, analyzeCmd, lintCmd, apply, daemon, fqdnCmd, zoneCmd, userCmd, tuiCmd)
+
+	// Без аргументов — сразу TUI
+	if len(os.Args) == 1 {
+		root.SetArgs([]string{"tui"})
+	}
+
+	markArgErrors(root)
+	cmd, err := root.ExecuteC()
+	// записи, сделанные командой, сразу уходят во внешние приёмники;
+	// daemon, tui и audit forward отправляют сами
+	if cmd != daemon && cmd != tuiCmd && cmd != auditForward {
+		fctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		warnForward(fctx)
+		cancel()
+	}
+	if err != nil {
+		var ee exitError
+		if errors.As(err, &ee) {
+			os.Exit(ee.code)
+		}
+		code, exit := errorCode(err)
+		output.PrintError(os.Stderr, outFmt, output.Error{Code: code, Message: err.Error(), ExitCode: exit})
+		var ue usageError
+		if errors.As(err, &ue) && outFmt != output.JSON && outFmt != output.YAML {
+			fmt.Fprintf(os.Stderr, "Run '%s --help' for usage.\n", cmd.CommandPath())
+		}
+		os.Exit(exit)
+	}
+}
+
+// impersonate проверяет --as: действовать от имени другого пользователя
+// может только admin, и этот пользователь должен существовать.
+func impersonate(dbPath, real, as string) error {
+	if err := ensureDB(dbPath); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	users := repo.UserRepo{DB: conn}
+	role, err := users.RoleOf(ctx, real)
+	if err != nil {
+		return fmt.Errorf("--as %s: %w", as, err)
+	}
+	if err := app.Require(real, role, "admin"); err != nil {
+		return fmt.Errorf("--as %s: only admins can act as another user: %w", as, err)
+	}
+	if _, err := users.RoleOf(ctx, as); err != nil {
+		return fmt.Errorf("--as: %w", err)
+	}
+	return nil
+}
+
+// auditPruneEvery — период очистки журнала аудита в daemon.
+const auditPruneEvery = time.Hour
+
+// retention — политика хранения из конфига (или флагов audit prune).
+func retention(c config.AuditRetention) (service.Retention, error) {
+	r := service.Retention{MaxRows: c.MaxRows, ArchiveDir: c.ArchiveDir}
+	if c.MaxRows < 0 {
+		return r, fmt.Errorf("audit retention: max rows must not be negative")
+	}
+	if c.MaxAge != "" {
+		d, err := util.ParseAge(c.MaxAge)
+		if err != nil {
+			return r, fmt.Errorf("audit retention max age: %w", err)
+		}
+		r.MaxAge = d
+	}
+	return r, nil
+}
+
+// sinkNames — имена приёмников: их недоставленные записи prune не трогает.
+func sinkNames(ss []auditsink.Sink) []string {
+	var out []string
+	for _, s := range ss {
+		out = append(out, s.Name())
+	}
+	return out
+}
+
+// auditForwardEvery — период отправки аудита в приёмники в daemon и TUI.
+const auditForwardEvery = 15 * time.Second
+
+// exitError — завершение с кодом code без сообщения (результат уже выведен).
+type exitError struct{ code int }
+
+func (e exitError) Error() string { return fmt.Sprintf("exit status %d", e.code) }
+
+// usageError — неверные флаги или аргументы: к сообщению добавляется
+// подсказка про --help.
+type usageError struct{ error }
+
+func (e usageError) Unwrap() error { return e.error }
+
+// errorCode — код ошибки для -o json и статус завершения.
+func errorCode(err error) (string, int) {
+	var denied *app.DeniedError
+	var input app.InputError
+	switch {
+	case errors.As(err, &denied), errors.Is(err, app.ErrUnknownUser), errors.Is(err, service.ErrApprovalRequired), errors.Is(err, service.ErrApproveAs):
+		return "permission_denied", app.ExitDenied
+	case errors.As(err, &input), errors.Is(err, service.ErrInvalid):
+		return "invalid_input", app.ExitInvalid
+	case errors.Is(err, repo.ErrNoRevision), errors.Is(err, repo.ErrNoRequest), errors.Is(err, repo.ErrNoAuditEntry), errors.Is(err, repo.ErrNoUser), errors.Is(err, repo.ErrNoRule):
+		return "not_found", app.ExitNotFound
+	}
+	return "error", app.ExitError
+}
+
+// markArgErrors помечает ошибки проверки позиционных аргументов как ошибки
+// ввода (ExactArgs и т.п. возвращают обычные ошибки).
+func markArgErrors(c *cobra.Command) {
+	if args := c.Args; args != nil {
+		c.Args = func(cmd *cobra.Command, a []string) error {
+			if err := args(cmd, a); err != nil {
+				return usageError{app.Invalid(err)}
+			}
+			return nil
+		}
+	}
+	for _, sub := range c.Commands() {
+		markArgErrors(sub)
+	}
+}
+
+// newApplyService: nft запускается внутри namespace ns (если задан).
+func newApplyService(conn *sql.DB, ns, dnsServer string, hist service.HistoryExporter) service.ApplyService {
+	return service.ApplyService{
+		Rules:    repo.RuleRepo{DB: conn, NS: ns},
+		Defaults: repo.DefaultsRepo{DB: conn, NS: ns},
+		Zones:    repo.ZoneRepo{DB: conn, NS: ns},
+		FQDN:     newFQDNService(conn, ns, dnsServer, nil),
+		Audit:    service.AuditService{Repo: repo.AuditRepo{DB: conn}},
+		Runner:   util.NetnsRunner{NS: ns, Runner: util.ShellRunner{}},
+
+		Applied:   repo.AppliedRepo{DB: conn, NS: ns},
+		Revisions: repo.RevisionRepo{DB: conn, NS: ns},
+		Export:    hist,
+	}
+}
+
+func newSyncService(conn *sql.DB, ns string) service.SyncService {
+	return service.SyncService{
+		Rules:    repo.RuleRepo{DB: conn, NS: ns},
+		Defaults: repo.DefaultsRepo{DB: conn, NS: ns},
+		Zones:    repo.ZoneRepo{DB: conn, NS: ns},
+		Audit:    service.AuditService{Repo: repo.AuditRepo{DB: conn}},
+	}
+}
+
+func newRevisionService(conn *sql.DB, ns string, hist service.HistoryExporter) service.RevisionService {
+	return service.RevisionService{Repo: repo.RevisionRepo{DB: conn, NS: ns}, Sync: newSyncService(conn, ns), Export: hist}
+}
+
+// gitHistory: выгрузка снимков в git (config git.dir); nil — выключено.
+func gitHistory(cfg config.Config, ns string) service.HistoryExporter {
+	if cfg.Git.Dir == "" {
+		return nil
+	}
+	return githist.Repo{Dir: cfg.Git.Dir, NS: ns}
+}
+
+func newFQDNService(conn *sql.DB, ns, dnsServer string, runner util.Runner) service.FQDNService {
+	return service.FQDNService{
+		Repo:     repo.FQDNRepo{DB: conn, NS: ns},
+		Rules:    repo.RuleRepo{DB: conn, NS: ns},
+		Resolver: resolve.DNSResolver{Server: dnsServer},
+		Runner:   runner,
+		Applied:  repo.AppliedRepo{DB: conn, NS: ns},
+	}
+}
+
+func oneOf(v string, xs ...string) bool {
+	for _, x := range xs {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+func splitCSV(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ---------- pretty printers ----------
+
+func printRulesTable(rs []model.Rule) {
+	fmt.Println("ID  CHAIN    PROTO  ACTION  EN  IN_IF     OUT_IF    PORTS        SRC               DST               ICMP     PROFILE   ORIGIN        COMMENT")
+	for _, x := range rs {
+		inIf, outIf, comment, prof, origin := "-", "-", "-", "-", "-"
+		if x.InIf != nil && *x.InIf != "" {
+			inIf = *x.InIf
+		}
+		if x.OutIf != nil && *x.OutIf != "" {
+			outIf = *x.OutIf
+		}
+		if x.Comment != nil && *x.Comment != "" {
+			comment = *x.Comment
+		}
+		if x.Profile != nil && *x.Profile != "" {
+			prof = *x.Profile
+		}
+		if x.Origin != nil && *x.Origin != "" {
+			origin = *x.Origin
+		}
+		en := "-"
+		if x.Enabled {
+			en = "✓"
+		}
+		fmt.Printf("%-3d %-8s %-6s %-7s %-3s %-9s %-9s %-12s %-16s %-16s %-8s %-9s %-13s %-s\n",
+			x.ID, x.Chain, x.Proto, x.Action, en,
+			inIf, outIf,
+			intSlice(x.Ports), strSlice(x.SrcCIDRs), strSlice(x.DstCIDRs),
+			intSlice(x.ICMPTypes), prof, origin, comment)
+	}
+}
+
+func printDefaultsTable(def model.Defaults) {
+	fmt.Println("DEFAULT POLICIES")
+	fmt.Printf("%-8s %-8s %-8s %-s\n", "INPUT", "FORWARD", "OUTPUT", "LOG_PREFIX")
+	fmt.Printf("%-8s %-8s %-8s %-s\n", def.InputPolicy, def.ForwardPolicy, def.OutputPolicy, def.LogPrefix)
+}
+
+func printFQDNTable(es []model.FQDNEntry) {
+	fmt.Println("NAME                            ADDRS                              EXPIRES   ERROR")
+	for _, e := range es {
+		exp := "-"
+		if !e.ExpiresAt.IsZero() {
+			exp = time.Until(e.ExpiresAt).Round(time.Second).String()
+		}
+		errS := "-"
+		if e.Error != "" {
+			errS = e.Error
+		}
+		fmt.Printf("%-31s %-34s %-9s %-s\n", e.Name, strSlice(e.Addrs), exp, errS)
+	}
+}
+
+func printPlan(p plan.Plan) {
+	if p.Empty() {
+		fmt.Println("no changes")
+		return
+	}
+	printChanges(p)
+	add, change, del := p.Counts()
+	fmt.Printf("Plan: %d to add, %d to change, %d to delete.\n", add, change, del)
+}
+
+func printChanges(p plan.Plan) {
+	if p.Empty() {
+		fmt.Println("no changes")
+		return
+	}
+	if d := p.Defaults; d != nil {
+		fmt.Printf("~ defaults: %s\n", fieldChanges(d.Fields))
+	}
+	if z := p.Zones; z != nil {
+		for _, f := range z.Fields {
+			switch {
+			case f.Old == "":
+				fmt.Printf("+ %s: %s\n", f.Name, f.New)
+			case f.New == "":
+				fmt.Printf("- %s: %s\n", f.Name, f.Old)
+			default:
+				fmt.Printf("~ %s: %q -> %q\n", f.Name, f.Old, f.New)
+			}
+		}
+	}
+	for _, c := range p.Rules {
+		switch c.Op {
+		case plan.Create:
+			fmt.Printf("+ rule: %s\n", c.Key)
+		case plan.Update:
+			fmt.Printf("~ rule %d: %s (%s)\n", c.Old.ID, c.Key, fieldChanges(c.Fields))
+		case plan.Delete:
+			fmt.Printf("- rule %d: %s\n", c.Old.ID, c.Key)
+		}
+	}
+}
+
+func printStatus(st service.Status) {
+	if st.Ever {
+		fmt.Printf("applied:  revision %d at %s by %s (sha256 %s)\n", st.Applied.Revision,
+			st.Applied.AppliedAt.Local().Format("2006-01-02 15:04:05"), st.Applied.Actor, st.Applied.Hash[:12])
+	} else {
+		fmt.Println("applied:  never")
+	}
+	fmt.Printf("current:  revision %d\n", st.Revision)
+	switch n := st.PendingCount(); {
+	case n > 0 && !st.Ever:
+		fmt.Printf("pending:  %d change(s) against the built-in policy; see `netfence diff --pending`\n", n)
+	case n > 0:
+		fmt.Printf("pending:  %d change(s); see `netfence diff --pending`\n", n)
+	case !st.Unchanged:
+		fmt.Println("pending:  none in the DB, but the rendered ruleset changed (interface or DNS addresses)")
+	default:
+		fmt.Println("pending:  none")
+	}
+}
+
+func printPendingDiff(st service.Status) {
+	if st.Ever {
+		fmt.Println("# DB changes since the last apply")
+	} else {
+		fmt.Println("# DB changes against the built-in policy (never applied)")
+	}
+	printChanges(st.Pending)
+	fmt.Println()
+	fmt.Println("# nft script changes")
+	lines := util.LineDiff(st.Applied.Script, st.Script)
+	if len(lines) == 0 {
+		fmt.Println("no changes")
+	}
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+}
+
+func printRequestsTable(crs []model.ChangeRequest) {
+	fmt.Println("ID    STATUS    AUTHOR           REVIEWER         CREATED              REASON")
+	for _, cr := range crs {
+		fmt.Printf("%-5d %-9s %-16s %-16s %-20s %s\n", cr.ID, cr.Status, cr.AuthorWho(), orDash(cr.ReviewerWho()),
+			cr.CreatedAt.Local().Format("2006-01-02 15:04:05"), cr.Reason)
+	}
+}
+
+// printAuditTable: details в таблице обрезаются, целиком — в -o json|yaml.
+func printAuditTable(es []model.AuditEntry) {
+	fmt.Println("ID     TIME                 ACTOR            ACTION            OBJECT        DETAILS")
+	for _, e := range es {
+		d := e.Details
+		if r := []rune(d); len(r) > 60 {
+			d = string(r[:59]) + "…"
+		}
+		fmt.Printf("%-6d %-20s %-16s %-17s %-13s %s\n", e.ID, e.TS.Local().Format("2006-01-02 15:04:05"), e.Who(), e.Action, e.Object, d)
+	}
+}
+
+// printAuditEntry: заголовок записи и diff полей; записи без before/after —
+// details как есть.
+func printAuditEntry(e model.AuditEntry, fields []service.FieldDiff, info any, ok, all bool) {
+	fmt.Printf("entry %d  %s  %s  %s  %s\n", e.ID, e.TS.Local().Format("2006-01-02 15:04:05"), e.Who(), e.Action, e.Object)
+	if !ok {
+		fmt.Println("  details:", e.Details)
+		return
+	}
+	n := 0
+	for _, f := range fields {
+		if f.Op != service.FieldSame || all {
+			fmt.Println("  " + f.String())
+			n++
+		}
+	}
+	if n == 0 {
+		fmt.Println("  no field changes")
+	}
+	if m, isMap := info.(map[string]any); isMap && len(m) > 0 {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = fmt.Sprintf("%s=%v", k, m[k])
+		}
+		fmt.Println("  info:", strings.Join(parts, " "))
+	}
+}
+
+func printAuditVerify(res auditchain.Result) {
+	for _, p := range res.Problems {
+		fmt.Printf("entry %d: %s\n", p.ID, p.Message)
+	}
+	if len(res.Problems) > 0 {
+		fmt.Printf("audit log FAILED verification: %d problem(s) in %d entries\n", len(res.Problems), res.Entries)
+		return
+	}
+	fmt.Printf("audit log OK: %d entries chained", res.Entries)
+	if res.WithHMAC > 0 {
+		fmt.Printf(", %d with valid HMAC", res.WithHMAC)
+	}
+	if res.PrunedUpTo > 0 {
+		fmt.Printf(", entries up to #%d pruned", res.PrunedUpTo)
+	}
+	fmt.Println()
+	if res.Unchecked > 0 {
+		fmt.Printf("note: %d HMAC(s) not checked, set audit.hmac_key_file\n", res.Unchecked)
+	}
+}
+
+func printAuditSinks(rs []service.ForwardResult) {
+	fmt.Println("SINK                                     SENT   PENDING  ERROR")
+	for _, r := range rs {
+		e := "-"
+		if r.Err != nil {
+			e = r.Err.Error()
+		}
+		fmt.Printf("%-40s %-6d %-8d %s\n", r.Sink, r.Sent, r.Pending, e)
+	}
+}
+
+func printAuditPrune(res service.PruneResult, dry bool) {
+	switch {
+	case res.Deleted == 0:
+		fmt.Println("nothing to prune")
+	case dry:
+		fmt.Printf("would delete %d entries (#%d..#%d)\n", res.Deleted, res.FirstID, res.LastID)
+	default:
+		fmt.Printf("deleted %d entries (#%d..#%d)\n", res.Deleted, res.FirstID, res.LastID)
+	}
+	if res.Archive != "" {
+		fmt.Println("archived to", res.Archive)
+	}
+	if res.Held > 0 {
+		fmt.Printf("kept %d entries not yet delivered to audit sinks (see audit forward)\n", res.Held)
+	}
+}
+
+func printUsersTable(us []model.User) {
+	fmt.Println("USER                             ROLE")
+	for _, u := range us {
+		fmt.Printf("%-32s %s\n", u.Name, u.Role)
+	}
+}
+
+func printRevisionsTable(revs []model.Revision) {
+	fmt.Println("REV   TIME                 ACTOR       MESSAGE")
+	for _, v := range revs {
+		fmt.Printf("%-5d %-20s %-11s %s\n", v.Rev, v.TS.Local().Format("2006-01-02 15:04:05"), v.Actor, v.Message)
+	}
+}
+
+func fieldChanges(fs []plan.FieldChange) string {
+	parts := make([]string, len(fs))
+	for i, f := range fs {
+		parts[i] = fmt.Sprintf("%s %q -> %q", f.Name, f.Old, f.New)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func printLintIssues(issues []lint.Issue) {
+	if len(issues) == 0 {
+		fmt.Println("no issues found")
+		return
+	}
+	fmt.Println("SEVERITY  CHECK                 RULE  MESSAGE")
+	for _, is := range issues {
+		rule := "-"
+		if is.RuleID != 0 {
+			rule = fmt.Sprint(is.RuleID)
+		}
+		fmt.Printf("%-9s %-21s %-5s %s\n", is.Severity, is.Check, rule, is.Message)
+	}
+}
+
+func printFindingsTable(fs []analyze.Finding) {
+	fmt.Println("KIND       CHAIN    RULE  BY    DETAIL")
+	for _, f := range fs {
+		fmt.Printf("%-10s %-8s %-5d %-5d %s\n", f.Kind, f.Chain, f.RuleID, f.ByID, f.Detail)
+	}
+}
+
+// warnf печатает предупреждение сервиса в stderr.
+func warnf(msg string) { fmt.Fprintln(os.Stderr, "warning:", msg) }
+
+func printZonesTable(zs []model.Zone, ps []model.ZonePolicy) {
+	fmt.Println("ZONE             INPUT    IFACES")
+	for _, z := range zs {
+		fmt.Printf("%-16s %-8s %-s\n", z.Name, z.InputPolicy, strSlice(z.Ifaces))
+	}
+	if len(zs) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("FORWARD (from \\ to)")
+	fmt.Printf("%-16s", "")
+	for _, to := range zs {
+		fmt.Printf(" %-10s", to.Name)
+	}
+	fmt.Println()
+	cell := map[string]string{}
+	for _, p := range ps {
+		cell[p.From+"->"+p.To] = p.Action
+	}
+	for _, from := range zs {
+		fmt.Printf("%-16s", from.Name)
+		for _, to := range zs {
+			v := cell[from.Name+"->"+to.Name]
+			if v == "" {
+				v = "-"
+			}
+			fmt.Printf(" %-10s", v)
+		}
+		fmt.Println()
+	}
+}
+
+func printProfilesTable(cat profile.Catalog) {
+	fmt.Println("PROFILE          RULES                    SOURCE                                DESCRIPTION")
+	for _, n := range cat.Names() {
+		p := cat[n]
+		var es []string
+		for _, e := range p.Rules {
+			es = append(es, e.Proto+"/"+strings.Trim(intSlice(e.Ports), "[]"))
+		}
+		fmt.Printf("%-16s %-24s %-37s %-s\n", p.Name, strings.Join(es, " "), p.Source, p.Description)
+	}
+}
+
+// helpers for pretty printers
+func intSlice(v []int) string {
+	if len(v) == 0 {
 		return "[]"
 	}
 	var sb strings.Builder
@@ -618,3 +2681,10 @@ func strSlice(v []string) string {
 	}
 	return "[" + strings.Join(v, ",") + "]"
 }
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,219 @@
+package analyze
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"netfence/internal/model"
+)
+
+// Виды находок.
+const (
+	Shadowed  = "shadowed"  // более раннее правило с другим действием ловит весь трафик правила
+	Redundant = "redundant" // более раннее правило с тем же действием ловит весь трафик правила
+	Conflict  = "conflict"  // частичное пересечение с противоположным действием: исход зависит от порядка
+)
+
+// Finding — проблема с правилом RuleID, вызванная более ранним правилом ByID.
+type Finding struct {
+	Kind   string
+	Chain  string
+	RuleID int64
+	ByID   int64
+	Detail string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("rule %d is %s by rule %d (%s)", f.RuleID, verb(f.Kind), f.ByID, f.Detail)
+}
+
+func verb(kind string) string {
+	switch kind {
+	case Redundant:
+		return "made redundant"
+	case Conflict:
+		return "in conflict with"
+	}
+	return kind
+}
+
+// Analyze проходит включённые правила каждой цепочки в порядке рендера и
+// сравнивает каждое правило со всеми предыдущими.
+func Analyze(rules []model.Rule) []Finding {
+	var out []Finding
+	var seen []model.Rule
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		out = append(out, Check(seen, r)...)
+		seen = append(seen, r)
+	}
+	return out
+}
+
+// Check сравнивает правило r с правилами before, которые рендерятся раньше.
+// Для правила важна только первая находка «shadowed/redundant»: дальше
+// трафик до него всё равно не доходит.
+func Check(before []model.Rule, r model.Rule) []Finding {
+	var out []Finding
+	for _, prev := range before {
+		if !prev.Enabled || prev.Chain != r.Chain || prev.ID == r.ID {
+			continue
+		}
+		switch {
+		case covers(prev, r):
+			kind := Redundant
+			if prev.Action != r.Action {
+				kind = Shadowed
+			}
+			return append(out, Finding{Kind: kind, Chain: r.Chain, RuleID: r.ID, ByID: prev.ID,
+				Detail: fmt.Sprintf("all matching traffic hits rule %d (%s) first", prev.ID, prev.Action)})
+		case prev.Action != r.Action && !covers(r, prev) && overlaps(prev, r):
+			out = append(out, Finding{Kind: Conflict, Chain: r.Chain, RuleID: r.ID, ByID: prev.ID,
+				Detail: fmt.Sprintf("overlapping traffic hits rule %d (%s) first", prev.ID, prev.Action)})
+		}
+	}
+	return out
+}
+
+// covers: любой пакет, совпадающий с b, совпадает и с a.
+func covers(a, b model.Rule) bool {
+	return ifCovers(a.InIf, b.InIf) && ifCovers(a.OutIf, b.OutIf) &&
+		(a.Proto == "all" || a.Proto == b.Proto) &&
+		intsCover(ports(a), ports(b)) && intsCover(icmpTypes(a), icmpTypes(b)) &&
+		addrsCover(a.SrcCIDRs, b.SrcCIDRs) && addrsCover(a.DstCIDRs, b.DstCIDRs)
+}
+
+// overlaps: существует пакет, совпадающий с обоими правилами.
+func overlaps(a, b model.Rule) bool {
+	return ifOverlap(a.InIf, b.InIf) && ifOverlap(a.OutIf, b.OutIf) &&
+		(a.Proto == "all" || b.Proto == "all" || a.Proto == b.Proto) &&
+		intsOverlap(ports(a), ports(b)) && intsOverlap(icmpTypes(a), icmpTypes(b)) &&
+		addrsOverlap(a.SrcCIDRs, b.SrcCIDRs) && addrsOverlap(a.DstCIDRs, b.DstCIDRs)
+}
+
+// ports/icmpTypes повторяют рендер: порты учитываются только для tcp/udp,
+// типы ICMP — только для icmp. nil означает «любой».
+func ports(r model.Rule) []int {
+	if r.Proto == "tcp" || r.Proto == "udp" {
+		return r.Ports
+	}
+	return nil
+}
+
+func icmpTypes(r model.Rule) []int {
+	if r.Proto == "icmp" {
+		return r.ICMPTypes
+	}
+	return nil
+}
+
+func ifCovers(a, b *string) bool { return a == nil || (b != nil && *a == *b) }
+
+func ifOverlap(a, b *string) bool { return a == nil || b == nil || *a == *b }
+
+func intsCover(a, b []int) bool {
+	if len(a) == 0 {
+		return true
+	}
+	if len(b) == 0 {
+		return false
+	}
+	for _, x := range b {
+		if !hasInt(a, x) {
+			return false
+		}
+	}
+	return true
+}
+
+func intsOverlap(a, b []int) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, x := range b {
+		if hasInt(a, x) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasInt(xs []int, v int) bool {
+	for _, x := range xs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// addrsCover: каждый адрес из b лежит внутри какого-то адреса из a.
+// iface-ссылки и DNS-имена статически не раскрываются и сравниваются
+// только на точное совпадение — так анализ не даёт ложных срабатываний.
+func addrsCover(a, b []string) bool {
+	if len(a) == 0 {
+		return true
+	}
+	if len(b) == 0 {
+		return false
+	}
+	for _, y := range b {
+		ok := false
+		for _, x := range a {
+			if addrContains(x, y) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func addrsOverlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if addrContains(x, y) || addrContains(y, x) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func addrContains(outer, inner string) bool {
+	if strings.EqualFold(outer, inner) {
+		return true
+	}
+	o, ok1 := parseNet(outer)
+	i, ok2 := parseNet(inner)
+	if !ok1 || !ok2 {
+		return false
+	}
+	on, _ := o.Mask.Size()
+	in, _ := i.Mask.Size()
+	return on <= in && o.Contains(i.IP)
+}
+
+func parseNet(s string) (*net.IPNet, bool) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s).To4()
+		if ip == nil {
+			return nil, false
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}, true
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, false
+	}
+	return n, true
+}
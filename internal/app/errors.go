@@ -0,0 +1,30 @@
+package app
+
+import "fmt"
+
+// Коды завершения CLI.
+const (
+	ExitError    = 1 // прочие ошибки
+	ExitFindings = 2 // lint: есть находки не ниже --fail-on
+	ExitInvalid  = 3 // неверные флаги, аргументы или входные данные
+	ExitDenied   = 4 // отказ RBAC или approval.required
+	ExitNotFound = 5 // нет такой ревизии, правила, change request-а, записи аудита или пользователя
+)
+
+// InputError — ошибка во входных данных: флаги, аргументы, YAML-файлы.
+type InputError struct{ Err error }
+
+func (e InputError) Error() string { return e.Err.Error() }
+func (e InputError) Unwrap() error { return e.Err }
+
+// Invalid помечает err как ошибку входных данных (nil остаётся nil).
+func Invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return InputError{Err: err}
+}
+
+func Invalidf(format string, a ...any) error {
+	return InputError{Err: fmt.Errorf(format, a...)}
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownUser — actor-а нет в таблице users.
+var ErrUnknownUser = errors.New("unknown user")
+
+// DeniedError — отказ RBAC: роли Role нет среди Need.
+type DeniedError struct {
+	Actor string
+	Role  string
+	Need  []string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("rbac: need %s, got %s", strings.Join(e.Need, " or "), e.Role)
+}
+
+// Require возвращает *DeniedError, если role не входит в need.
+func Require(actor, role string, need ...string) error {
+	for _, n := range need {
+		if role == n {
+			return nil
+		}
+	}
+	return &DeniedError{Actor: actor, Role: role, Need: need}
+}
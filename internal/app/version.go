@@ -0,0 +1,6 @@
+package app
+
+// Version — версия сборки, задаётся линкером:
+//
+//	go build -ldflags "-X netfence/internal/app.Version=1.4.0" ./cmd/netfence
+var Version = "dev"
@@ -0,0 +1,127 @@
+// Package auditchain — цепочка хешей журнала аудита: каждая запись хранит
+// хеш предыдущей и свой хеш от полей и prev_hash, так что изменение или
+// удаление записи в середине журнала обнаруживается. Без ключа HMAC цепочку
+// можно пересчитать целиком, поэтому при доступе к БД у злоумышленника
+// защищает только HMAC с ключом вне БД.
+package auditchain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TSLayout — формат ts в хеше (как CURRENT_TIMESTAMP в SQLite, UTC).
+const TSLayout = "2006-01-02 15:04:05"
+
+// Row — запись журнала в том виде, в каком она хешируется.
+type Row struct {
+	ID        int64
+	TS        string // TSLayout
+	Actor     string
+	Action    string
+	Object    string
+	Details   string
+	RealActor string // настоящий пользователь ОС; у старых записей пусто
+	RealUID   int64
+	PrevHash  string
+	Hash      string // пусто — запись вне цепочки
+	HMAC      string // пусто — без HMAC
+}
+
+// Sum — хеш записи: sha256 от JSON-массива полей, hex. Поля настоящего
+// пользователя входят, только если заданы: хеши старых записей не меняются.
+func Sum(r Row) string {
+	f := []any{r.PrevHash, r.ID, r.TS, r.Actor, r.Action, r.Object, r.Details}
+	if r.RealActor != "" {
+		f = append(f, r.RealActor, r.RealUID)
+	}
+	b, _ := json.Marshal(f)
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// MAC — HMAC-SHA256 хеша записи ключом key, hex.
+func MAC(key []byte, hash string) string {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(hash))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// LoadKey читает ключ HMAC из файла (пробелы по краям отбрасываются).
+func LoadKey(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("audit hmac key: %w", err)
+	}
+	key := []byte(strings.TrimSpace(string(b)))
+	if len(key) < 16 {
+		return nil, fmt.Errorf("audit hmac key %s: too short (need at least 16 bytes)", path)
+	}
+	return key, nil
+}
+
+// Problem — нарушение цепочки в записи ID.
+type Problem struct {
+	ID      int64
+	Message string
+}
+
+// Result — итог Verify.
+type Result struct {
+	Entries   int // проверено записей
+	WithHMAC  int // из них с проверенным HMAC
+	Unchecked int // с HMAC, но без ключа для проверки
+	// PrunedUpTo — записи до этого ID удалены очисткой журнала (0 — нет);
+	// заполняет вызывающий.
+	PrunedUpTo int64
+	Problems   []Problem
+}
+
+// Verify проверяет записи rows (по возрастанию ID). start — ожидаемый
+// prev_hash первой записи ("" — начало журнала). Без key HMAC не
+// проверяется. Обнаруживаются: изменённые записи (хеш не сходится),
+// удалённые или вставленные (разрыв prev_hash), записи без хеша, а с
+// ключом — неверный или снятый HMAC.
+func Verify(rows []Row, start string, key []byte) Result {
+	res := Result{Entries: len(rows)}
+	add := func(id int64, format string, args ...any) {
+		res.Problems = append(res.Problems, Problem{ID: id, Message: fmt.Sprintf(format, args...)})
+	}
+	prev, macSeen := start, false
+	for i, r := range rows {
+		if r.Hash == "" {
+			add(r.ID, "not chained (written outside netfence?)")
+			continue
+		}
+		if r.PrevHash != prev {
+			if i == 0 {
+				add(r.ID, "chain does not start here: earlier entries were deleted")
+			} else {
+				add(r.ID, "chain broken: previous entry was deleted, replaced or inserted")
+			}
+		}
+		if Sum(r) != r.Hash {
+			add(r.ID, "entry was modified (hash mismatch)")
+		}
+		switch {
+		case r.HMAC == "":
+			if macSeen && key != nil {
+				add(r.ID, "HMAC missing")
+			}
+		case key == nil:
+			res.Unchecked++
+		case !hmac.Equal([]byte(MAC(key, r.Hash)), []byte(r.HMAC)):
+			add(r.ID, "bad HMAC")
+		default:
+			res.WithHMAC++
+		}
+		macSeen = macSeen || r.HMAC != ""
+		prev = r.Hash
+	}
+	return res
+}
@@ -0,0 +1,307 @@
+package auditsink_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"netfence/internal/auditsink"
+	"netfence/internal/config"
+)
+
+func testEvent(id int64) auditsink.Event {
+	return auditsink.Event{
+		ID:        id,
+		Time:      time.Date(2026, 3, 1, 12, 30, 45, 123000000, time.UTC),
+		Host:      "fw1",
+		Actor:     "root",
+		RealActor: `al"ice]`,
+		Action:    "add_rule",
+		Object:    fmt.Sprintf("rule:%d", id),
+		Details:   json.RawMessage(`{"before":null,"after":{"id":1}}`),
+		Hash:      "abc123",
+	}
+}
+
+func newSink(t *testing.T, c config.AuditSink) auditsink.Sink {
+	t.Helper()
+	s, err := auditsink.New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// rfc5424 — <PRI>1 TIMESTAMP HOST APP-NAME PROCID MSGID [SD] MSG
+var rfc5424 = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) (\S+) (\d+) (\S+) (\[netfence@32473 (?:[a-z_]+="(?:[^"\\\]]|\\.)*" ?)+\]) (.*)$`)
+
+// checkSyslog проверяет одно сообщение RFC 5424 и возвращает событие из MSG.
+func checkSyslog(t *testing.T, msg string, wantPRI int) auditsink.Event {
+	t.Helper()
+	m := rfc5424.FindStringSubmatch(msg)
+	if m == nil {
+		t.Fatalf("not an RFC 5424 message: %q", msg)
+	}
+	if pri, _ := strconv.Atoi(m[1]); pri != wantPRI {
+		t.Errorf("PRI = %d, want %d", pri, wantPRI)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, m[2]); err != nil {
+		t.Errorf("timestamp %q: %v", m[2], err)
+	}
+	if m[3] != "fw1" || m[4] != "nf-test" || m[6] != "add_rule" {
+		t.Errorf("header host=%q app=%q msgid=%q", m[3], m[4], m[6])
+	}
+	if !strings.Contains(m[7], `real_actor="al\"ice\]"`) {
+		t.Errorf("structured data not escaped: %s", m[7])
+	}
+	var e auditsink.Event
+	if err := json.Unmarshal([]byte(m[8]), &e); err != nil {
+		t.Fatalf("MSG is not the event JSON: %v", err)
+	}
+	if !strings.Contains(m[7], fmt.Sprintf(`id="%d"`, e.ID)) {
+		t.Errorf("structured data %s does not match event %d", m[7], e.ID)
+	}
+	return e
+}
+
+func TestSyslogUnixgram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	s := newSink(t, config.AuditSink{Type: "syslog", Network: "unixgram", Address: path, Facility: "local3", Tag: "nf-test"})
+	if got, want := s.Name(), "syslog:unixgram:"+path; got != want {
+		t.Errorf("name = %q, want %q", got, want)
+	}
+	for id := int64(1); id <= 2; id++ {
+		if err := s.Send(context.Background(), testEvent(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// одно сообщение на датаграмму, без префикса длины и перевода строки
+	buf := make([]byte, 8192)
+	_ = l.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for id := int64(1); id <= 2; id++ {
+		n, err := l.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := checkSyslog(t, string(buf[:n]), 19*8+5) // local3.notice
+		if e.ID != id || e.RealActor != `al"ice]` || string(e.Details) != `{"before":null,"after":{"id":1}}` {
+			t.Errorf("event %d = %+v", id, e)
+		}
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	s := newSink(t, config.AuditSink{Type: "syslog", Network: "udp", Address: l.LocalAddr().String(), Tag: "nf-test"})
+	if err := s.Send(context.Background(), testEvent(5)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 8192)
+	_ = l.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := l.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSyslog(t, string(buf[:n]), 10*8+5) // authpriv.notice по умолчанию
+}
+
+func TestSyslogStreamOctetCounting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	got := make(chan []string, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			got <- nil
+			return
+		}
+		defer c.Close()
+		_ = c.SetReadDeadline(time.Now().Add(2 * time.Second))
+		r := bufio.NewReader(c)
+		var msgs []string
+		for len(msgs) < 2 {
+			// MSG-LEN SP SYSLOG-MSG (RFC 6587, 3.4.1)
+			ls, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(ls))
+			if err != nil {
+				break
+			}
+			b := make([]byte, n)
+			if _, err := io.ReadFull(r, b); err != nil {
+				break
+			}
+			msgs = append(msgs, string(b))
+		}
+		got <- msgs
+	}()
+	s := newSink(t, config.AuditSink{Type: "syslog", Network: "unix", Address: path, Tag: "nf-test"})
+	for id := int64(1); id <= 2; id++ {
+		if err := s.Send(context.Background(), testEvent(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := <-got
+	if len(msgs) != 2 {
+		t.Fatalf("got %d framed messages, want 2", len(msgs))
+	}
+	for i, m := range msgs {
+		if e := checkSyslog(t, m, 10*8+5); e.ID != int64(i+1) {
+			t.Errorf("message %d has id %d", i, e.ID)
+		}
+	}
+}
+
+func TestSyslogReconnectsAfterFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	s := newSink(t, config.AuditSink{Type: "syslog", Network: "unixgram", Address: path, Tag: "nf-test"})
+	if err := s.Send(context.Background(), testEvent(1)); err == nil {
+		t.Fatal("send without a listener succeeded")
+	}
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := s.Send(context.Background(), testEvent(1)); err != nil {
+		t.Fatalf("send after the listener came back: %v", err)
+	}
+	buf := make([]byte, 8192)
+	_ = l.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := l.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSyslog(t, string(buf[:n]), 10*8+5)
+}
+
+func TestJournaldNativeProtocol(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	s := newSink(t, config.AuditSink{Type: "journald", Address: path, Tag: "nf-test"})
+	e := testEvent(3)
+	e.Details = json.RawMessage("{\"note\":\n\"two lines\"}")
+	if err := s.Send(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 8192)
+	_ = l.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := l.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]string{}
+	b := buf[:n]
+	for len(b) > 0 {
+		nl := bytes.IndexByte(b, '\n')
+		if nl < 0 {
+			t.Fatalf("unterminated field %q", b)
+		}
+		line := string(b[:nl])
+		b = b[nl+1:]
+		if k, v, ok := strings.Cut(line, "="); ok {
+			fields[k] = v
+			continue
+		}
+		// KEY\n<uint64 LE длина>VALUE\n — значение с переводом строки
+		size := binary.LittleEndian.Uint64(b)
+		fields[line] = string(b[8 : 8+size])
+		b = b[8+size+1:]
+	}
+	want := map[string]string{
+		"SYSLOG_IDENTIFIER":   "nf-test",
+		"PRIORITY":            "5",
+		"NETFENCE_AUDIT_ID":   "3",
+		"NETFENCE_ACTOR":      "root",
+		"NETFENCE_REAL_ACTOR": `al"ice]`,
+		"NETFENCE_ACTION":     "add_rule",
+		"NETFENCE_OBJECT":     "rule:3",
+		"NETFENCE_DETAILS":    "{\"note\":\n\"two lines\"}",
+		"NETFENCE_HASH":       "abc123",
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("%s = %q, want %q", k, fields[k], v)
+		}
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	s := newSink(t, config.AuditSink{Type: "jsonl", Path: path})
+	for id := int64(1); id <= 2; id++ {
+		if err := s.Send(context.Background(), testEvent(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", fi.Mode().Perm())
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(string(b), "\n") {
+		t.Fatalf("file is not two JSON lines: %q", b)
+	}
+	for i, line := range lines {
+		var e auditsink.Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if want := testEvent(int64(i + 1)); e.ID != want.ID || !e.Time.Equal(want.Time) || e.RealActor != want.RealActor || e.Hash != want.Hash {
+			t.Errorf("line %d = %+v", i+1, e)
+		}
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	for _, c := range []config.AuditSink{
+		{Type: "kafka"},
+		{Type: "jsonl"},
+		{Type: "syslog", Network: "sctp"},
+		{Type: "syslog", Network: "udp"},
+		{Type: "syslog", Facility: "local9"},
+	} {
+		if _, err := auditsink.New(c); err == nil {
+			t.Errorf("New(%+v) accepted a bad config", c)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package auditsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const journalSocket = "/run/systemd/journal/socket"
+
+// Journald пишет записи в journal по native-протоколу (датаграмма
+// KEY=VALUE). Поля: MESSAGE, PRIORITY, SYSLOG_IDENTIFIER и NETFENCE_* —
+// по ним удобно фильтровать: journalctl NETFENCE_ACTION=apply.
+type Journald struct {
+	Socket string
+	Tag    string
+
+	conn net.Conn
+}
+
+func (j *Journald) Name() string { return "journald:" + j.Socket }
+
+func (j *Journald) Send(ctx context.Context, e Event) error {
+	var b bytes.Buffer
+	field(&b, "MESSAGE", fmt.Sprintf("netfence audit #%d: %s %s %s", e.ID, e.Actor, e.Action, e.Object))
+	field(&b, "PRIORITY", fmt.Sprint(severityNotice))
+	field(&b, "SYSLOG_IDENTIFIER", j.Tag)
+	field(&b, "NETFENCE_AUDIT_ID", fmt.Sprint(e.ID))
+	field(&b, "NETFENCE_ACTOR", e.Actor)
+	if e.RealActor != "" {
+		field(&b, "NETFENCE_REAL_ACTOR", e.RealActor)
+	}
+	field(&b, "NETFENCE_ACTION", e.Action)
+	field(&b, "NETFENCE_OBJECT", e.Object)
+	field(&b, "NETFENCE_DETAILS", string(e.Details))
+	if e.Hash != "" {
+		field(&b, "NETFENCE_HASH", e.Hash)
+	}
+	if j.conn == nil {
+		var d net.Dialer
+		dctx, cancel := context.WithDeadline(ctx, deadline(ctx))
+		defer cancel()
+		c, err := d.DialContext(dctx, "unixgram", j.Socket)
+		if err != nil {
+			return err
+		}
+		j.conn = c
+	}
+	_ = j.conn.SetWriteDeadline(deadline(ctx))
+	if _, err := j.conn.Write(b.Bytes()); err != nil {
+		_ = j.Close()
+		return err
+	}
+	return nil
+}
+
+func (j *Journald) Close() error {
+	if j.conn == nil {
+		return nil
+	}
+	err := j.conn.Close()
+	j.conn = nil
+	return err
+}
+
+// field: KEY=VALUE\n; значение с переводом строки — KEY\n<длина uint64 LE>VALUE\n.
+func field(b *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(key + "=" + value + "\n")
+		return
+	}
+	b.WriteString(key + "\n")
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
@@ -0,0 +1,39 @@
+package auditsink
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+)
+
+// JSONL дописывает каждую запись строкой JSON в конец файла (O_APPEND,
+// права 0600). Файл открывается на каждую запись, так что logrotate может
+// переименовать его в любой момент.
+type JSONL struct {
+	Path string
+}
+
+func (j *JSONL) Name() string { return "jsonl:" + j.Path }
+
+func (j *JSONL) Send(_ context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	// одна запись за один write: строки не перемешиваются с другими процессами
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (j *JSONL) Close() error { return nil }
@@ -0,0 +1,132 @@
+package auditsink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Syslog отправляет записи в формате RFC 5424: MSGID — действие,
+// structured data netfence@32473 с id/actor/action/object, MSG — Event в
+// JSON. Датаграммные сокеты (unixgram, udp) — одно сообщение на датаграмму,
+// потоковые (unix, tcp) — с префиксом длины (octet counting, RFC 6587).
+type Syslog struct {
+	Network  string
+	Address  string
+	Facility int
+	Tag      string
+
+	conn net.Conn
+}
+
+// sdID — SD-ID в structured data; 32473 — номер IANA для примеров (RFC 5612).
+const sdID = "netfence@32473"
+
+// severityNotice — изменения конфигурации: notice.
+const severityNotice = 5
+
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "daemon": 3, "auth": 4, "syslog": 5, "authpriv": 10,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+func newSyslog(network, addr, facility, tag string) (*Syslog, error) {
+	if network == "" {
+		network = "unixgram"
+	}
+	switch network {
+	case "unixgram", "unix", "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("audit sink syslog: bad network %q (unixgram|unix|udp|tcp)", network)
+	}
+	if addr == "" {
+		if network != "unixgram" && network != "unix" {
+			return nil, fmt.Errorf("audit sink syslog: address is required for %s", network)
+		}
+		addr = "/dev/log"
+	}
+	if facility == "" {
+		facility = "authpriv"
+	}
+	f, ok := facilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("audit sink syslog: unknown facility %q", facility)
+	}
+	return &Syslog{Network: network, Address: addr, Facility: f, Tag: tag}, nil
+}
+
+func (s *Syslog) Name() string { return "syslog:" + s.Network + ":" + s.Address }
+
+func (s *Syslog) Send(ctx context.Context, e Event) error {
+	msg := s.format(e)
+	if s.stream() {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	if s.conn == nil {
+		var d net.Dialer
+		dctx, cancel := context.WithDeadline(ctx, deadline(ctx))
+		defer cancel()
+		c, err := d.DialContext(dctx, s.Network, s.Address)
+		if err != nil {
+			return err
+		}
+		s.conn = c
+	}
+	_ = s.conn.SetWriteDeadline(deadline(ctx))
+	if _, err := s.conn.Write([]byte(msg)); err != nil {
+		_ = s.Close()
+		return err
+	}
+	return nil
+}
+
+func (s *Syslog) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *Syslog) stream() bool { return s.Network == "unix" || s.Network == "tcp" }
+
+// format — сообщение RFC 5424 без завершающего перевода строки.
+func (s *Syslog) format(e Event) string {
+	body, _ := json.Marshal(e)
+	var real string
+	if e.RealActor != "" {
+		real = fmt.Sprintf(` real_actor="%s"`, sdEscape(e.RealActor))
+	}
+	sd := fmt.Sprintf(`[%s id="%d" actor="%s"%s action="%s" object="%s"]`,
+		sdID, e.ID, sdEscape(e.Actor), real, sdEscape(e.Action), sdEscape(e.Object))
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		s.Facility*8+severityNotice, e.Time.UTC().Format(time.RFC3339Nano), header(e.Host, 255),
+		header(s.Tag, 48), os.Getpid(), header(e.Action, 32), sd, body)
+}
+
+// header — поле заголовка: печатный ASCII без пробелов, не длиннее n; пусто — "-".
+func header(v string, n int) string {
+	v = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, v)
+	if v == "" {
+		return "-"
+	}
+	if len(v) > n {
+		v = v[:n]
+	}
+	return v
+}
+
+// sdEscape экранирует значение SD-PARAM: ", \ и ].
+func sdEscape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
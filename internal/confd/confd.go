@@ -0,0 +1,90 @@
+package confd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"netfence/internal/model"
+	"netfence/internal/plan"
+	"netfence/internal/vars"
+)
+
+// DefaultDir — каталог фрагментов по умолчанию.
+const DefaultDir = "/etc/netfence/conf.d"
+
+// Files — фрагменты *.yaml и *.yml каталога в лексикографическом порядке
+// (10-base.yaml, 20-db.yaml, ...). Скрытые файлы пропускаются.
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if ext := filepath.Ext(name); ext == ".yaml" || ext == ".yml" {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s: no *.yaml fragments", dir)
+	}
+	return out, nil
+}
+
+// Load сливает фрагменты каталога в один снапшот. Правила идут в порядке
+// файлов, у каждого Origin — имя файла. defaults и zones может задать только
+// один фрагмент; одинаковый ключ правила в двух местах — конфликт. Переменные
+// vars: действуют внутри своего фрагмента, varFiles — во всех.
+func Load(dir string, varFiles []string) (model.Snapshot, error) {
+	var out model.Snapshot
+	files, err := Files(dir)
+	if err != nil {
+		return out, err
+	}
+	var defaultsFrom, zonesFrom string
+	seen := map[string]string{} // ключ правила -> где определено
+	var errs []error
+	for _, name := range files {
+		snap, err := vars.Load(filepath.Join(dir, name), varFiles)
+		if err != nil {
+			return out, err
+		}
+		if snap.Defaults != (model.Defaults{}) {
+			if defaultsFrom != "" {
+				errs = append(errs, fmt.Errorf("conflict: defaults set in both %s and %s", defaultsFrom, name))
+			} else {
+				defaultsFrom, out.Defaults = name, snap.Defaults
+			}
+		}
+		if snap.Zones != nil {
+			if zonesFrom != "" {
+				errs = append(errs, fmt.Errorf("conflict: zones set in both %s and %s", zonesFrom, name))
+			} else {
+				zonesFrom, out.Zones = name, snap.Zones
+			}
+		}
+		for i := range snap.Rules {
+			r := snap.Rules[i]
+			origin := name
+			r.Origin = &origin
+			at := fmt.Sprintf("%s (rule #%d)", name, i+1)
+			k := plan.Key(r)
+			if prev, ok := seen[k]; ok {
+				errs = append(errs, fmt.Errorf("conflict: rule %q in both %s and %s", k, prev, at))
+				continue
+			}
+			seen[k] = at
+			out.Rules = append(out.Rules, r)
+		}
+	}
+	return out, errors.Join(errs...)
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultPath = "/etc/netfence/netfence.yaml"
+
+// Config — необязательный файл настроек netfence (--config).
+type Config struct {
+	Lint     Lint     `yaml:"lint"`
+	Approval Approval `yaml:"approval"`
+	Git      Git      `yaml:"git"`
+	Audit    Audit    `yaml:"audit"`
+}
+
+// Audit — журнал аудита. HMACKeyFile — файл с ключом (не короче 16 байт),
+// которым подписывается каждая запись; без него цепочку хешей может
+// пересчитать любой, у кого есть запись в БД.
+type Audit struct {
+	HMACKeyFile string         `yaml:"hmac_key_file"`
+	Sinks       []AuditSink    `yaml:"sinks"`
+	Retention   AuditRetention `yaml:"retention"`
+}
+
+// AuditRetention — сколько хранить журнал: записи старше MaxAge (90d, 24h)
+// и сверх последних MaxRows удаляются `audit prune` и daemon-ом. Пустой
+// ArchiveDir — удалённые записи не сохраняются.
+type AuditRetention struct {
+	MaxAge     string `yaml:"max_age"`
+	MaxRows    int    `yaml:"max_rows"`
+	ArchiveDir string `yaml:"archive_dir"`
+}
+
+// AuditSink — внешний приёмник записей аудита (в дополнение к SQLite).
+type AuditSink struct {
+	Type     string `yaml:"type"`     // syslog | journald | jsonl
+	Network  string `yaml:"network"`  // syslog: unixgram (по умолчанию), unix, udp, tcp
+	Address  string `yaml:"address"`  // syslog: /dev/log или host:port; journald: путь сокета
+	Path     string `yaml:"path"`     // jsonl: файл
+	Facility string `yaml:"facility"` // syslog: по умолчанию authpriv
+	Tag      string `yaml:"tag"`      // syslog/journald: по умолчанию netfence
+}
+
+// Git — выгрузка снимков ruleset-а в локальный git-репозиторий после каждого
+// изменения и apply. Пустой Dir — выключено.
+type Git struct {
+	Dir string `yaml:"dir"`
+}
+
+// Approval — двухэтапные изменения: при Required правила и defaults меняются
+// только через одобренные change request-ы.
+type Approval struct {
+	Required bool `yaml:"required"`
+}
+
+// Lint — настройки `netfence lint`.
+type Lint struct {
+	Disable           []string          `yaml:"disable"`             // ID отключённых проверок
+	Severity          map[string]string `yaml:"severity"`            // ID -> info|warning|error
+	StaleDisabledDays int               `yaml:"stale_disabled_days"` // для stale-disabled, по умолчанию 30
+}
+
+// Load читает конфиг; отсутствующий файл — пустой конфиг.
+func Load(path string) (Config, error) {
+	var c Config
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("config %s: %w", path, err)
+	}
+	return c, nil
+}
@@ -8,6 +8,8 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+
+	"netfence/internal/auditchain"
 )
 //go:embed migrations/*.sql
 var fs embed.FS
@@ -44,9 +46,65 @@ func ApplyAll(ctx context.Context, db *sql.DB) error {
 		if ver <= cur { continue }
 		b, err := fs.ReadFile("migrations/" + f)
 		if err != nil { return err }
+		if step := goSteps[ver]; step != nil {
+			if err := applyStep(ctx, db, ver, string(b), step); err != nil {
+				return fmt.Errorf("apply %s: %w", f, err)
+			}
+			continue
+		}
 		if _, err := db.ExecContext(ctx, string(b)); err != nil {
 			return fmt.Errorf("apply %s: %w", f, err)
 		}
 	}
 	return nil
 }
+
+// applyStep выполняет миграцию ver с Go-шагом одной транзакцией: SQL (без
+// BEGIN/COMMIT и без записи в schema_migrations), шаг, запись версии. Если
+// шаг не удался или процесс прервали, версия не записана и миграция
+// повторится целиком при следующем запуске.
+func applyStep(ctx context.Context, db *sql.DB, ver int, script string, step func(ctx context.Context, tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil { return err }
+	defer func() {
+		if err != nil { _ = tx.Rollback() }
+	}()
+	if _, err = tx.ExecContext(ctx, script); err != nil { return err }
+	if err = step(ctx, tx); err != nil { return err }
+	if _, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations(version) VALUES(?)`, ver); err != nil { return err }
+	return tx.Commit()
+}
+
+// goSteps — преобразования данных, которые нельзя выразить в SQL; шаг
+// выполняется в транзакции миграции с тем же номером (см. applyStep).
+var goSteps = map[int]func(ctx context.Context, tx *sql.Tx) error{
+	11: backfillAuditChain,
+}
+
+// backfillAuditChain связывает цепочкой хешей записи аудита, сделанные до
+// миграции 11 (без HMAC: ключа здесь нет).
+func backfillAuditChain(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id,strftime('%Y-%m-%d %H:%M:%S',ts),actor,action,object,details FROM audit_log ORDER BY id`)
+	if err != nil { return err }
+	var all []auditchain.Row
+	for rows.Next() {
+		var r auditchain.Row
+		if err := rows.Scan(&r.ID, &r.TS, &r.Actor, &r.Action, &r.Object, &r.Details); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil { return err }
+	prev := ""
+	for _, r := range all {
+		r.PrevHash = prev
+		r.Hash = auditchain.Sum(r)
+		if _, err := tx.ExecContext(ctx, `UPDATE audit_log SET ts=?,prev_hash=?,hash=? WHERE id=?`, r.TS, r.PrevHash, r.Hash, r.ID); err != nil {
+			return err
+		}
+		prev = r.Hash
+	}
+	return nil
+}
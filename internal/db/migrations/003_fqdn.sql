@@ -0,0 +1,14 @@
+BEGIN;
+-- кеш резолва DNS-имён, указанных в dst правил
+CREATE TABLE fqdn_names(
+  name TEXT PRIMARY KEY,
+  resolved_at DATETIME,
+  expires_at DATETIME,
+  error TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE fqdn_addr(name TEXT NOT NULL REFERENCES fqdn_names(name) ON DELETE CASCADE,
+  addr TEXT NOT NULL,
+  PRIMARY KEY(name, addr)
+);
+INSERT INTO schema_migrations(version) VALUES(3);
+COMMIT;
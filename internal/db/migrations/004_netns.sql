@@ -0,0 +1,19 @@
+BEGIN;
+-- отдельные rulesets для network namespaces; '' — namespace хоста
+ALTER TABLE rules ADD COLUMN netns TEXT NOT NULL DEFAULT '';
+CREATE INDEX idx_rules_netns ON rules(netns);
+
+CREATE TABLE defaults_ns(
+  netns TEXT PRIMARY KEY,
+  input_policy TEXT NOT NULL DEFAULT 'drop' CHECK(input_policy IN('accept','drop')),
+  forward_policy TEXT NOT NULL DEFAULT 'drop' CHECK(forward_policy IN('accept','drop')),
+  output_policy TEXT NOT NULL DEFAULT 'accept' CHECK(output_policy IN('accept','drop')),
+  log_prefix TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO defaults_ns(netns,input_policy,forward_policy,output_policy,log_prefix)
+  SELECT '',input_policy,forward_policy,output_policy,log_prefix FROM defaults WHERE id=1;
+DROP TABLE defaults;
+ALTER TABLE defaults_ns RENAME TO defaults;
+
+INSERT INTO schema_migrations(version) VALUES(4);
+COMMIT;
@@ -0,0 +1,25 @@
+BEGIN;
+-- зоны: группы интерфейсов со своей input-политикой
+CREATE TABLE zones(
+  netns TEXT NOT NULL DEFAULT '',
+  name TEXT NOT NULL,
+  input_policy TEXT NOT NULL DEFAULT 'drop' CHECK(input_policy IN('accept','drop')),
+  PRIMARY KEY(netns, name)
+);
+-- интерфейс принадлежит не более чем одной зоне
+CREATE TABLE zone_iface(
+  netns TEXT NOT NULL DEFAULT '',
+  zone TEXT NOT NULL,
+  iface TEXT NOT NULL,
+  PRIMARY KEY(netns, iface)
+);
+-- матрица forward-политик зона→зона
+CREATE TABLE zone_forward(
+  netns TEXT NOT NULL DEFAULT '',
+  from_zone TEXT NOT NULL,
+  to_zone TEXT NOT NULL,
+  action TEXT NOT NULL CHECK(action IN('accept','drop')),
+  PRIMARY KEY(netns, from_zone, to_zone)
+);
+INSERT INTO schema_migrations(version) VALUES(5);
+COMMIT;
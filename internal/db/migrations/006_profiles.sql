@@ -0,0 +1,6 @@
+BEGIN;
+-- правила, созданные из профиля приложения (allow ssh ...), помечены его именем
+ALTER TABLE rules ADD COLUMN profile TEXT;
+CREATE INDEX idx_rules_profile ON rules(profile);
+INSERT INTO schema_migrations(version) VALUES(6);
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+-- нумерованные снимки ruleset-а (defaults + rules) после каждого изменения
+CREATE TABLE revisions(
+  netns TEXT NOT NULL DEFAULT '',
+  rev INTEGER NOT NULL,
+  ts DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  actor TEXT NOT NULL,
+  message TEXT NOT NULL,
+  snapshot TEXT NOT NULL,     -- YAML, формат export
+  PRIMARY KEY(netns, rev)
+);
+INSERT INTO schema_migrations(version) VALUES(7);
+COMMIT;
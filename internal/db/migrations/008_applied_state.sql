@@ -0,0 +1,13 @@
+BEGIN;
+-- последнее успешно применённое состояние (apply) для каждого namespace
+CREATE TABLE applied_state(
+  netns TEXT PRIMARY KEY,
+  hash TEXT NOT NULL,         -- sha256 nft-скрипта
+  revision INTEGER NOT NULL,  -- последняя ревизия на момент apply (0 — истории не было)
+  applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  actor TEXT NOT NULL,
+  snapshot TEXT NOT NULL,     -- YAML defaults + rules
+  script TEXT NOT NULL
+);
+INSERT INTO schema_migrations(version) VALUES(8);
+COMMIT;
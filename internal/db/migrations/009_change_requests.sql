@@ -0,0 +1,19 @@
+BEGIN;
+-- запросы на изменение ruleset-а: желаемый снимок + обоснование, одобряет другой человек
+CREATE TABLE change_requests(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  netns TEXT NOT NULL DEFAULT '',
+  author TEXT NOT NULL,
+  reason TEXT NOT NULL,
+  snapshot TEXT NOT NULL,           -- YAML, формат export
+  base_revision INTEGER NOT NULL,   -- ревизия, от которой считались изменения
+  status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN('pending','approved','rejected','applied')),
+  reviewer TEXT NOT NULL DEFAULT '',
+  note TEXT NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  reviewed_at DATETIME,
+  applied_at DATETIME
+);
+CREATE INDEX idx_change_requests_status ON change_requests(netns, status);
+INSERT INTO schema_migrations(version) VALUES(9);
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+-- файл conf.d, из которого пришло правило (sync/import --dir)
+ALTER TABLE rules ADD COLUMN origin TEXT;
+INSERT INTO schema_migrations(version) VALUES(10);
+COMMIT;
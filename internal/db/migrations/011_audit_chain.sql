@@ -0,0 +1,6 @@
+-- цепочка хешей журнала аудита (см. internal/auditchain); существующие
+-- записи связывает шаг backfillAuditChain в migrate.go. Транзакцию и запись
+-- версии делает applyStep: версия пишется только вместе с заполненной цепочкой.
+ALTER TABLE audit_log ADD COLUMN prev_hash TEXT;
+ALTER TABLE audit_log ADD COLUMN hash TEXT;
+ALTER TABLE audit_log ADD COLUMN hmac TEXT;
@@ -0,0 +1,10 @@
+BEGIN;
+-- позиция внешних приёмников аудита: последняя доставленная запись
+CREATE TABLE audit_sink_state(
+  sink TEXT PRIMARY KEY,      -- auditsink.Sink.Name(): тип и адрес
+  last_id INTEGER NOT NULL,
+  last_error TEXT,            -- NULL — последняя попытка удачна
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO schema_migrations(version) VALUES(12);
+COMMIT;
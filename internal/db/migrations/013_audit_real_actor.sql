@@ -0,0 +1,7 @@
+BEGIN;
+-- настоящий пользователь ОС, вызвавший netfence (actor — от чьего имени
+-- сделано изменение, может отличаться при --as); NULL — записи до миграции
+ALTER TABLE audit_log ADD COLUMN real_actor TEXT;
+ALTER TABLE audit_log ADD COLUMN real_uid INTEGER;
+INSERT INTO schema_migrations(version) VALUES(13);
+COMMIT;
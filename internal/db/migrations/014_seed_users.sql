@@ -0,0 +1,7 @@
+BEGIN;
+-- пользователь operator раньше создавался при каждом запуске (ensureDB) и
+-- возвращался после удаления; теперь он заводится один раз здесь, дальше
+-- пользователями управляет `netfence user`
+INSERT OR IGNORE INTO users(name, role) VALUES ('operator', 'operator');
+INSERT INTO schema_migrations(version) VALUES(14);
+COMMIT;
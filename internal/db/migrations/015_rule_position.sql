@@ -0,0 +1,16 @@
+BEGIN;
+-- порядок правил в наборе: nft проверяет правила цепочки сверху вниз, так что
+-- порядок — часть ruleset-а, а не следствие ID (sync/rollback/import ставят
+-- правила в порядок файла)
+ALTER TABLE rules ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+-- перемещение правила его не меняет: updated_at не трогаем (на нём stale-disabled)
+DROP TRIGGER IF EXISTS trg_rules_updated_at;
+UPDATE rules SET position = id;
+CREATE TRIGGER trg_rules_updated_at
+AFTER UPDATE ON rules FOR EACH ROW WHEN NEW.position = OLD.position
+BEGIN
+  UPDATE rules SET updated_at=CURRENT_TIMESTAMP WHERE id=OLD.id;
+END;
+CREATE INDEX IF NOT EXISTS idx_rules_position ON rules(netns, position);
+INSERT INTO schema_migrations(version) VALUES(15);
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+-- настоящие пользователи ОС автора и рецензента (см. audit_log.real_actor):
+-- правило двух человек проверяется по ним, а не по --as
+ALTER TABLE change_requests ADD COLUMN real_author TEXT NOT NULL DEFAULT '';
+ALTER TABLE change_requests ADD COLUMN real_reviewer TEXT NOT NULL DEFAULT '';
+UPDATE change_requests SET real_author = author;
+UPDATE change_requests SET real_reviewer = reviewer;
+INSERT INTO schema_migrations(version) VALUES(16);
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+-- кеш DNS-имён — отдельно для каждого namespace: nft-множества у каждого свои,
+-- и "адреса не изменились" надо считать от состояния того же namespace.
+-- Старый общий кеш не переносим: при следующем refresh/apply имена
+-- резолвятся заново и множества каждого namespace обновляются.
+DROP TABLE fqdn_addr;
+DROP TABLE fqdn_names;
+CREATE TABLE fqdn_names(
+  netns TEXT NOT NULL DEFAULT '',
+  name TEXT NOT NULL,
+  resolved_at DATETIME,
+  expires_at DATETIME,
+  error TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY(netns, name)
+);
+CREATE TABLE fqdn_addr(
+  netns TEXT NOT NULL DEFAULT '',
+  name TEXT NOT NULL,
+  addr TEXT NOT NULL,
+  PRIMARY KEY(netns, name, addr),
+  FOREIGN KEY(netns, name) REFERENCES fqdn_names(netns, name) ON DELETE CASCADE
+);
+INSERT INTO schema_migrations(version) VALUES(17);
+COMMIT;
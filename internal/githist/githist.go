@@ -0,0 +1,101 @@
+package githist
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"netfence/internal/model"
+	"netfence/internal/snapshot"
+	"netfence/internal/util"
+)
+
+// Repo — локальный git-репозиторий, куда после каждого изменения или apply
+// выгружается снимок ruleset-а (тот же YAML, что пишет export). Remote не нужен.
+type Repo struct {
+	Dir    string
+	NS     string      // namespace снимка: "" — хост
+	Runner util.Runner // nil — util.ShellRunner
+}
+
+// File — имя файла снимка для namespace-а. Namespace, заданный путём
+// (/proc/<pid>/ns/net), даёт безопасное имя с хешем пути.
+func (r Repo) File() string {
+	if r.NS == "" {
+		return "netfence.yaml"
+	}
+	safe := strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' {
+			return c
+		}
+		return '_'
+	}, r.NS)
+	if safe != r.NS || strings.HasPrefix(safe, ".") {
+		h := fnv.New32a()
+		h.Write([]byte(r.NS))
+		safe = fmt.Sprintf("%s_%08x", strings.Trim(safe, "_."), h.Sum32())
+	}
+	return "netfence@" + safe + ".yaml"
+}
+
+// Commit записывает снимок и коммитит его от имени actor. Если снимок не
+// изменился, коммит создаётся только при allowEmpty (apply).
+func (r Repo) Commit(ctx context.Context, actor, message string, snap model.Snapshot, allowEmpty bool) error {
+	if err := r.init(); err != nil {
+		return err
+	}
+	b, err := snapshot.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir, r.File()), b, 0o644); err != nil {
+		return err
+	}
+	if _, err := r.git("add", "--", r.File()); err != nil {
+		return err
+	}
+	if _, err := r.git("diff", "--cached", "--quiet"); err == nil && !allowEmpty {
+		return nil // нечего коммитить
+	}
+	if r.NS != "" {
+		message += " (netns " + r.NS + ")"
+	}
+	ident := actor + " <" + actor + "@netfence>"
+	args := []string{"-c", "user.name=" + actor, "-c", "user.email=" + actor + "@netfence", "-c", "commit.gpgsign=false",
+		"commit", "-q", "--author", ident, "-m", actor + ": " + message}
+	if allowEmpty {
+		args = append(args, "--allow-empty")
+	}
+	_, err = r.git(args...)
+	return err
+}
+
+func (r Repo) init() error {
+	if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(r.Dir, ".git")); err == nil {
+		return nil
+	}
+	_, err := r.git("init", "-q")
+	return err
+}
+
+func (r Repo) git(args ...string) (string, error) {
+	runner := r.Runner
+	if runner == nil {
+		runner = util.ShellRunner{}
+	}
+	out, stderr, err := runner.Run("git", nil, append([]string{"-C", r.Dir}, args...)...)
+	if err != nil {
+		sub := args[0]
+		for i := 0; i+2 < len(args) && args[i] == "-c"; i += 2 {
+			sub = args[i+2]
+		}
+		return out, fmt.Errorf("git %s: %v: %s", sub, err, strings.TrimSpace(stderr))
+	}
+	return out, nil
+}
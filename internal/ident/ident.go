@@ -0,0 +1,94 @@
+// Package ident — кто на самом деле вызвал netfence. Имя пользователя берётся
+// не из флага --as, а у ОС: peer credentials сокета на stdin (запуск через
+// socket activation), пользователь sudo или UID процесса.
+package ident
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// Caller — настоящий пользователь ОС.
+type Caller struct {
+	Name string // имя пользователя; неизвестный UID — "uid:1234"
+	UID  int
+	Via  string // peer | sudo | uid
+}
+
+// Current — вызвавший пользователь. Переменные SUDO_* учитываются только у
+// процесса с UID 0: их выставляет sudo, а обычный пользователь подделать
+// их может, но получить root — нет. Клиент сокета на stdin учитывается
+// только у сервиса с socket activation (см. activated): иначе любой мог бы
+// подключить stdin к чужому сокету и назваться его владельцем.
+func Current() Caller {
+	// клиент-root ничего не добавляет к UID 0 ниже, а sudo точнее
+	if activated() {
+		if c, ok := stdinPeer(); ok && c.UID != 0 {
+			return c
+		}
+	}
+	uid := os.Getuid()
+	if uid == 0 {
+		if s := os.Getenv("SUDO_UID"); s != "" {
+			if n, err := strconv.Atoi(s); err == nil {
+				name := os.Getenv("SUDO_USER")
+				if name == "" {
+					name = lookup(n)
+				}
+				return Caller{Name: name, UID: n, Via: "sudo"}
+			}
+		}
+	}
+	return Caller{Name: lookup(uid), UID: uid, Via: "uid"}
+}
+
+// FromConn — пользователь по ту сторону unix-сокета (SO_PEERCRED).
+func FromConn(c *net.UnixConn) (Caller, error) {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return Caller{}, err
+	}
+	var cred *syscall.Ucred
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		cred, serr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return Caller{}, err
+	}
+	if serr != nil {
+		return Caller{}, fmt.Errorf("peer credentials: %w", serr)
+	}
+	return Caller{Name: lookup(int(cred.Uid)), UID: int(cred.Uid), Via: "peer"}, nil
+}
+
+// activated — netfence запущен как сервис для соединения на stdin: от root
+// (не через sudo — у sudo свой пользователь) или с LISTEN_PID/LISTEN_FDS
+// systemd, адресованными этому процессу.
+func activated() bool {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err == nil && pid == os.Getpid() && os.Getenv("LISTEN_FDS") != "" {
+		return true
+	}
+	return os.Getuid() == 0 && os.Getenv("SUDO_UID") == ""
+}
+
+// stdinPeer — stdin это принятое соединение unix-сокета (systemd
+// Accept=yes, inetd): вызвавший — клиент сокета, а не сам процесс.
+func stdinPeer() (Caller, bool) {
+	cred, err := syscall.GetsockoptUcred(0, syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	if err != nil || cred.Pid == 0 {
+		return Caller{}, false
+	}
+	uid := int(cred.Uid)
+	return Caller{Name: lookup(uid), UID: uid, Via: "peer"}, true
+}
+
+func lookup(uid int) string {
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		return u.Username
+	}
+	return "uid:" + strconv.Itoa(uid)
+}
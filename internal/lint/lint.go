@@ -0,0 +1,239 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"netfence/internal/config"
+	"netfence/internal/model"
+	"netfence/internal/render"
+)
+
+// Уровни серьёзности, по возрастанию.
+const (
+	Info    = "info"
+	Warning = "warning"
+	Error   = "error"
+)
+
+// Rank — порядок уровня для сравнения (--fail-on); неизвестный уровень — -1.
+func Rank(sev string) int {
+	switch sev {
+	case Info:
+		return 0
+	case Warning:
+		return 1
+	case Error:
+		return 2
+	}
+	return -1
+}
+
+// Issue — одна находка линтера. RuleID == 0 — находка про ruleset целиком.
+type Issue struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	RuleID   int64  `json:"rule_id,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Env — окружение проверок.
+type Env struct {
+	IfExists func(name string) error // nil — проверка missing-interface пропускается
+	Now      time.Time
+}
+
+// Check — проверка с ID и уровнем по умолчанию.
+type Check struct {
+	ID          string
+	Severity    string
+	Description string
+	run         func(snap model.Snapshot, env Env, cfg config.Lint) []Issue
+}
+
+// Checks — все проверки в порядке вывода.
+var Checks = []Check{
+	{"input-policy-accept", Error, "default input policy is accept", checkInputPolicy},
+	{"ssh-open-world", Error, "SSH (tcp/22) accepted from any source", checkSSHOpen},
+	{"missing-interface", Warning, "rule references an interface that does not exist", checkMissingIfaces},
+	{"icmp-types-non-icmp", Warning, "ICMP types on a non-icmp rule are ignored", checkICMPTypes},
+	{"ports-proto-all", Warning, "ports on a proto all/icmp rule are ignored", checkPortsProtoAll},
+	{"stale-disabled", Info, "rule disabled for longer than stale_disabled_days", checkStaleDisabled},
+	{"missing-comment", Info, "rule has no comment", checkMissingComment},
+}
+
+// Validate проверяет ID и уровни, упомянутые в конфиге.
+func Validate(cfg config.Lint) error {
+	known := map[string]bool{}
+	for _, c := range Checks {
+		known[c.ID] = true
+	}
+	for _, id := range cfg.Disable {
+		if !known[id] {
+			return fmt.Errorf("lint: unknown check %q", id)
+		}
+	}
+	for id, sev := range cfg.Severity {
+		if !known[id] {
+			return fmt.Errorf("lint: unknown check %q", id)
+		}
+		if Rank(sev) < 0 {
+			return fmt.Errorf("lint: bad severity %q for %s", sev, id)
+		}
+	}
+	return nil
+}
+
+// Run выполняет включённые проверки; уровни берутся из конфига, если заданы.
+func Run(snap model.Snapshot, env Env, cfg config.Lint) []Issue {
+	disabled := map[string]bool{}
+	for _, id := range cfg.Disable {
+		disabled[id] = true
+	}
+	var out []Issue
+	for _, c := range Checks {
+		if disabled[c.ID] {
+			continue
+		}
+		sev := c.Severity
+		if s, ok := cfg.Severity[c.ID]; ok {
+			sev = s
+		}
+		for _, is := range c.run(snap, env, cfg) {
+			is.Check, is.Severity = c.ID, sev
+			out = append(out, is)
+		}
+	}
+	return out
+}
+
+func checkInputPolicy(snap model.Snapshot, _ Env, _ config.Lint) []Issue {
+	if snap.Defaults.InputPolicy == "accept" {
+		return []Issue{{Message: "input policy is accept: everything not dropped explicitly is allowed"}}
+	}
+	return nil
+}
+
+func checkSSHOpen(snap model.Snapshot, _ Env, _ config.Lint) []Issue {
+	var out []Issue
+	for _, r := range snap.Rules {
+		if !r.Enabled || r.Chain != "input" || r.Action != "accept" {
+			continue
+		}
+		if r.Proto != "tcp" && r.Proto != "all" {
+			continue
+		}
+		if r.Proto == "tcp" && len(r.Ports) > 0 && !hasInt(r.Ports, 22) {
+			continue
+		}
+		if len(r.SrcCIDRs) > 0 && !hasStr(r.SrcCIDRs, "0.0.0.0/0") {
+			continue
+		}
+		out = append(out, Issue{RuleID: r.ID, Message: "SSH is reachable from 0.0.0.0/0"})
+	}
+	return out
+}
+
+func checkMissingIfaces(snap model.Snapshot, env Env, _ config.Lint) []Issue {
+	if env.IfExists == nil {
+		return nil
+	}
+	var out []Issue
+	for _, r := range snap.Rules {
+		names := map[string]bool{}
+		if r.InIf != nil {
+			names[*r.InIf] = true
+		}
+		if r.OutIf != nil {
+			names[*r.OutIf] = true
+		}
+		for n := range render.IfaceRefs([]model.Rule{r}) {
+			names[n] = true
+		}
+		for _, n := range sortedKeys(names) {
+			if err := env.IfExists(n); err != nil {
+				out = append(out, Issue{RuleID: r.ID, Message: fmt.Sprintf("interface %s not found", n)})
+			}
+		}
+	}
+	return out
+}
+
+func checkICMPTypes(snap model.Snapshot, _ Env, _ config.Lint) []Issue {
+	var out []Issue
+	for _, r := range snap.Rules {
+		if len(r.ICMPTypes) > 0 && r.Proto != "icmp" {
+			out = append(out, Issue{RuleID: r.ID, Message: fmt.Sprintf("icmp types set on proto %s", r.Proto)})
+		}
+	}
+	return out
+}
+
+func checkPortsProtoAll(snap model.Snapshot, _ Env, _ config.Lint) []Issue {
+	var out []Issue
+	for _, r := range snap.Rules {
+		if len(r.Ports) > 0 && r.Proto != "tcp" && r.Proto != "udp" {
+			out = append(out, Issue{RuleID: r.ID, Message: fmt.Sprintf("ports set on proto %s match any port", r.Proto)})
+		}
+	}
+	return out
+}
+
+func checkStaleDisabled(snap model.Snapshot, env Env, cfg config.Lint) []Issue {
+	days := cfg.StaleDisabledDays
+	if days <= 0 {
+		days = 30
+	}
+	var out []Issue
+	for _, r := range snap.Rules {
+		if r.Enabled || r.UpdatedAt.IsZero() {
+			continue
+		}
+		if age := env.Now.Sub(r.UpdatedAt); age > time.Duration(days)*24*time.Hour {
+			out = append(out, Issue{RuleID: r.ID, Message: fmt.Sprintf("disabled for %d days", int(age.Hours()/24))})
+		}
+	}
+	return out
+}
+
+func checkMissingComment(snap model.Snapshot, _ Env, _ config.Lint) []Issue {
+	var out []Issue
+	for _, r := range snap.Rules {
+		if r.Profile != nil {
+			continue // правила профиля описываются самим профилем
+		}
+		if r.Comment == nil || strings.TrimSpace(*r.Comment) == "" {
+			out = append(out, Issue{RuleID: r.ID, Message: "no comment"})
+		}
+	}
+	return out
+}
+
+func hasInt(xs []int, v int) bool {
+	for _, x := range xs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func hasStr(xs []string, v string) bool {
+	for _, x := range xs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// AppliedState — что было загружено в ядро последним успешным apply.
+type AppliedState struct {
+	Hash      string
+	Revision  int64
+	AppliedAt time.Time
+	Actor     string
+	Snapshot  Snapshot
+	Script    string
+}
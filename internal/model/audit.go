@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// AuditEntry — запись журнала аудита; Details — JSON, Hash — звено цепочки
+// (пусто у записей вне цепочки). Actor — от чьего имени сделано изменение,
+// RealActor — пользователь ОС, который его сделал (пусто у старых записей).
+type AuditEntry struct {
+	ID        int64
+	TS        time.Time
+	Actor     string
+	RealActor string
+	Action    string
+	Object    string
+	Details   string
+	Hash      string
+}
+
+// Who — кто сделал изменение: "alice" или "alice as root" при --as.
+func (e AuditEntry) Who() string {
+	if e.RealActor == "" || e.RealActor == e.Actor {
+		return e.Actor
+	}
+	return e.RealActor + " as " + e.Actor
+}
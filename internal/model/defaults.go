@@ -1,8 +1,13 @@
 package model
 
 type Defaults struct {
-	InputPolicy   string
-	ForwardPolicy string
-	OutputPolicy  string
-	LogPrefix     string
+	InputPolicy   string `json:"input_policy" yaml:"input_policy"`
+	ForwardPolicy string `json:"forward_policy" yaml:"forward_policy"`
+	OutputPolicy  string `json:"output_policy" yaml:"output_policy"`
+	LogPrefix     string `json:"log_prefix" yaml:"log_prefix"`
+}
+
+// BuiltinDefaults — политики из схемы БД: с ними netfence стартует до первого apply.
+func BuiltinDefaults() Defaults {
+	return Defaults{InputPolicy: "drop", ForwardPolicy: "drop", OutputPolicy: "accept"}
 }
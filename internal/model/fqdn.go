@@ -0,0 +1,47 @@
+package model
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// FQDNEntry — последний результат резолва имени из DstCIDRs.
+type FQDNEntry struct {
+	Name       string
+	Addrs      []string
+	ResolvedAt time.Time
+	ExpiresAt  time.Time
+	Error      string
+}
+
+// ParseFQDN проверяет, что s — DNS-имя (а не CIDR/IP/iface-ссылка), и
+// возвращает его в каноническом виде: нижний регистр, без завершающей точки.
+func ParseFQDN(s string) (string, bool) {
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "."))
+	if name == "" || len(name) > 253 || !strings.Contains(name, ".") {
+		return "", false
+	}
+	if net.ParseIP(name) != nil || strings.ContainsAny(name, "/:") {
+		return "", false
+	}
+	allDigits := true
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c == '-', c == '_':
+				allDigits = false
+			case c >= '0' && c <= '9':
+			default:
+				return "", false
+			}
+		}
+	}
+	if allDigits {
+		return "", false
+	}
+	return name, true
+}
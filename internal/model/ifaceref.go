@@ -0,0 +1,32 @@
+package model
+
+import "strings"
+
+// IfaceRef — символическая ссылка на адрес интерфейса в SrcCIDRs/DstCIDRs:
+// iface:<name>:address (адреса интерфейса) или iface:<name>:network (его подсети).
+type IfaceRef struct {
+	Iface string
+	Kind  string
+}
+
+const (
+	IfaceAddress = "address"
+	IfaceNetwork = "network"
+)
+
+// ParseIfaceRef разбирает строку вида iface:eth1:network.
+func ParseIfaceRef(s string) (IfaceRef, bool) {
+	if !strings.HasPrefix(s, "iface:") {
+		return IfaceRef{}, false
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 || strings.TrimSpace(parts[1]) == "" {
+		return IfaceRef{}, false
+	}
+	if parts[2] != IfaceAddress && parts[2] != IfaceNetwork {
+		return IfaceRef{}, false
+	}
+	return IfaceRef{Iface: parts[1], Kind: parts[2]}, true
+}
+
+func (r IfaceRef) String() string { return "iface:" + r.Iface + ":" + r.Kind }
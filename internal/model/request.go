@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// Статусы ChangeRequest.
+const (
+	RequestPending  = "pending"
+	RequestApproved = "approved"
+	RequestRejected = "rejected"
+	RequestApplied  = "applied"
+)
+
+// ChangeRequest — предложенное изменение ruleset-а, ждущее одобрения.
+type ChangeRequest struct {
+	ID           int64
+	Author       string
+	Reason       string
+	Snapshot     Snapshot
+	BaseRevision int64
+	Status       string
+	Reviewer     string
+	Note         string
+	RealAuthor   string // пользователь ОС, подавший запрос (автор может быть --as)
+	RealReviewer string
+	CreatedAt    time.Time
+	ReviewedAt   time.Time
+	AppliedAt    time.Time
+}
+
+// AuthorWho — автор для вывода: "alice as root" при --as.
+func (cr ChangeRequest) AuthorWho() string { return who(cr.Author, cr.RealAuthor) }
+
+// ReviewerWho — рецензент для вывода.
+func (cr ChangeRequest) ReviewerWho() string { return who(cr.Reviewer, cr.RealReviewer) }
+
+func who(actor, real string) string {
+	if real == "" || real == actor {
+		return actor
+	}
+	return real + " as " + actor
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Revision — нумерованный снимок ruleset-а namespace-а.
+type Revision struct {
+	Rev      int64
+	TS       time.Time
+	Actor    string
+	Message  string
+	Snapshot Snapshot
+}
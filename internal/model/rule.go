@@ -1,16 +1,21 @@
 package model
 
+import "time"
+
 type Rule struct {
-	ID        int64
-	Chain     string
-	Proto     string
-	Action    string
-	InIf      *string
-	OutIf     *string
-	Ports     []int
-	SrcCIDRs  []string
-	DstCIDRs  []string
-	ICMPTypes []int
-	Comment   *string
-	Enabled   bool
+	ID        int64     `json:"id" yaml:"id"`
+	Chain     string    `json:"chain" yaml:"chain"`
+	Proto     string    `json:"proto" yaml:"proto"`
+	Action    string    `json:"action" yaml:"action"`
+	InIf      *string   `json:"in_if" yaml:"in_if"`
+	OutIf     *string   `json:"out_if" yaml:"out_if"`
+	Ports     []int     `json:"ports" yaml:"ports"`
+	SrcCIDRs  []string  `json:"src" yaml:"src"`
+	DstCIDRs  []string  `json:"dst" yaml:"dst"`
+	ICMPTypes []int     `json:"icmp_types" yaml:"icmp_types"`
+	Comment   *string   `json:"comment" yaml:"comment"`
+	Enabled   bool      `json:"enabled" yaml:"enabled"`
+	Profile   *string   `json:"profile" yaml:"profile"` // профиль приложения, из которого создано правило
+	Origin    *string   `json:"origin" yaml:"origin"`   // файл conf.d, из которого загружено правило
+	UpdatedAt time.Time `json:"-" yaml:"-"`             // из БД; в снапшотах не хранится
 }
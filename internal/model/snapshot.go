@@ -0,0 +1,12 @@
+package model
+
+// Snapshot — ruleset namespace-а в виде YAML (export/import, lint --file).
+// Формат версионирован, читать и писать его нужно через пакет snapshot.
+type Snapshot struct {
+	Version  int      `yaml:"version"`
+	Defaults Defaults `yaml:"defaults"`
+	Rules    []Rule   `yaml:"rules"`
+	// Zones: nil — снапшот зонами не управляет (файл без секции zones,
+	// ревизии до её появления); пустой ZoneSet — зон нет.
+	Zones *ZoneSet `yaml:"zones,omitempty"`
+}
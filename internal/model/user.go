@@ -0,0 +1,25 @@
+package model
+
+// User — пользователь netfence: имя пользователя ОС и роль RBAC.
+type User struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// Роли RBAC, от старшей к младшей.
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+)
+
+var Roles = []string{RoleAdmin, RoleOperator, RoleViewer}
+
+func ValidRole(r string) bool {
+	for _, v := range Roles {
+		if r == v {
+			return true
+		}
+	}
+	return false
+}
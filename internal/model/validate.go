@@ -0,0 +1,127 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidRule — правило не прошло проверку (см. FieldError).
+var ErrInvalidRule = errors.New("invalid rule")
+
+// FieldError — неверное поле правила; errors.Is(err, ErrInvalidRule) истинно.
+type FieldError struct{ Field string }
+
+func (e *FieldError) Error() string        { return ErrInvalidRule.Error() + ": " + e.Field }
+func (e *FieldError) Is(target error) bool { return target == ErrInvalidRule }
+
+// ValidateRule — проверка полей правила (add-rule, профили, sync, снапшоты).
+// Существование интерфейсов не проверяется: это зависит от хоста.
+func ValidateRule(r *Rule) error {
+	if !oneOf(r.Chain, "input", "forward", "output") {
+		return invalid("chain")
+	}
+	if !oneOf(r.Proto, "all", "tcp", "udp", "icmp") {
+		return invalid("proto")
+	}
+	if !oneOf(r.Action, "accept", "drop") {
+		return invalid("action")
+	}
+	for _, p := range r.Ports {
+		if p <= 0 || p > 65535 {
+			return invalid("port")
+		}
+	}
+	for _, c := range r.SrcCIDRs {
+		if !validAddr(c) {
+			return invalid("src_cidr")
+		}
+	}
+	for _, c := range r.DstCIDRs {
+		if _, ok := ParseFQDN(c); !ok && !validAddr(c) {
+			return invalid("dst_cidr")
+		}
+	}
+	for _, t := range r.ICMPTypes {
+		if t < 0 || t > 255 {
+			return invalid("icmp_type")
+		}
+	}
+	if r.InIf != nil && strings.TrimSpace(*r.InIf) == "" {
+		return invalid("in_if")
+	}
+	if r.OutIf != nil && strings.TrimSpace(*r.OutIf) == "" {
+		return invalid("out_if")
+	}
+	return nil
+}
+
+// ValidPolicy: accept или drop (в нижнем регистре, как chain/proto/action
+// правил и CHECK в схеме).
+func ValidPolicy(p string) bool {
+	return p == "accept" || p == "drop"
+}
+
+var zoneName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// ValidZoneName: [a-z][a-z0-9_]*, не длиннее 32 символов.
+func ValidZoneName(name string) bool { return zoneName.MatchString(name) }
+
+// ValidateZones — проверка зон снапшота: имена, политики, интерфейс не более
+// чем в одной зоне, forward-политики только между описанными зонами.
+func ValidateZones(zs ZoneSet) error {
+	names := map[string]bool{}
+	ifaces := map[string]string{}
+	for _, z := range zs.List {
+		switch {
+		case !ValidZoneName(z.Name):
+			return fmt.Errorf("invalid zone name %q", z.Name)
+		case names[z.Name]:
+			return fmt.Errorf("zone %s listed twice", z.Name)
+		case !ValidPolicy(z.InputPolicy):
+			return fmt.Errorf("zone %s: invalid input_policy %q (accept|drop)", z.Name, z.InputPolicy)
+		}
+		names[z.Name] = true
+		for _, i := range z.Ifaces {
+			if prev, ok := ifaces[i]; ok {
+				return fmt.Errorf("interface %s is in zones %s and %s", i, prev, z.Name)
+			}
+			ifaces[i] = z.Name
+		}
+	}
+	pairs := map[string]bool{}
+	for _, p := range zs.Forward {
+		switch {
+		case !names[p.From] || !names[p.To]:
+			return fmt.Errorf("forward %s->%s: unknown zone", p.From, p.To)
+		case !ValidPolicy(p.Action):
+			return fmt.Errorf("forward %s->%s: invalid action %q (accept|drop)", p.From, p.To, p.Action)
+		case pairs[p.From+"->"+p.To]:
+			return fmt.Errorf("forward %s->%s listed twice", p.From, p.To)
+		}
+		pairs[p.From+"->"+p.To] = true
+	}
+	return nil
+}
+
+// validAddr: CIDR или ссылка на адрес интерфейса (iface:eth1:network).
+func validAddr(s string) bool {
+	if _, ok := ParseIfaceRef(s); ok {
+		return true
+	}
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+func oneOf(v string, xs ...string) bool {
+	for _, x := range xs {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+func invalid(field string) error { return &FieldError{Field: field} }
@@ -0,0 +1,21 @@
+package model
+
+// Zone — группа интерфейсов со своей политикой для входящего трафика.
+type Zone struct {
+	Name        string   `json:"name" yaml:"name"`
+	Ifaces      []string `json:"ifaces" yaml:"ifaces"`
+	InputPolicy string   `json:"input_policy" yaml:"input_policy"`
+}
+
+// ZonePolicy — forward-политика для трафика из зоны From в зону To.
+type ZonePolicy struct {
+	From   string `json:"from" yaml:"from"`
+	To     string `json:"to" yaml:"to"`
+	Action string `json:"action" yaml:"action"`
+}
+
+// ZoneSet — все зоны namespace-а и матрица forward-политик (часть снапшота).
+type ZoneSet struct {
+	List    []Zone       `json:"list" yaml:"list"`
+	Forward []ZonePolicy `json:"forward" yaml:"forward"`
+}
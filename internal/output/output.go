@@ -0,0 +1,93 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	"netfence/internal/app"
+)
+
+// Форматы вывода (-o/--output).
+const (
+	Table = "table"
+	JSON  = "json"
+	YAML  = "yaml"
+	CSV   = "csv"
+)
+
+// Formats — допустимые значения -o.
+var Formats = []string{Table, JSON, YAML, CSV}
+
+func Valid(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Tabular — данные, которые можно вывести в CSV.
+type Tabular interface {
+	CSV() (header []string, rows [][]string)
+}
+
+// Print выводит v: table — функцией table, json/yaml — сериализацией v по
+// тегам, csv — через Tabular. Формат, который v не поддерживает, — ошибка
+// входных данных.
+func Print(w io.Writer, format string, v any, table func()) error {
+	switch format {
+	case Table, "":
+		table()
+		return nil
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case YAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	case CSV:
+		t, ok := v.(Tabular)
+		if !ok {
+			return app.Invalidf("-o csv is not supported by this command")
+		}
+		header, rows := t.CSV()
+		cw := csv.NewWriter(w)
+		_ = cw.Write(header)
+		_ = cw.WriteAll(rows) // WriteAll делает Flush
+		return cw.Error()
+	}
+	return app.Invalidf("bad --output %q (table|json|yaml|csv)", format)
+}
+
+// Error — ошибка команды в json/yaml-режиме.
+type Error struct {
+	Code     string `json:"code" yaml:"code"`
+	Message  string `json:"message" yaml:"message"`
+	ExitCode int    `json:"exit_code" yaml:"exit_code"`
+}
+
+// PrintError пишет {"error": {...}}; для table/csv — "Error: message".
+func PrintError(w io.Writer, format string, e Error) {
+	v := map[string]Error{"error": e}
+	switch format {
+	case JSON:
+		b, _ := json.Marshal(v)
+		_, _ = w.Write(append(b, '\n'))
+	case YAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		_ = enc.Encode(v)
+		_ = enc.Close()
+	default:
+		_, _ = io.WriteString(w, "Error: "+e.Message+"\n")
+	}
+}
@@ -0,0 +1,579 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"netfence/internal/analyze"
+	"netfence/internal/auditchain"
+	"netfence/internal/lint"
+	"netfence/internal/model"
+	"netfence/internal/profile"
+	"netfence/internal/service"
+)
+
+// Представления для -o json|yaml|csv. Имена полей — стабильный интерфейс
+// для автоматизации: переименовывать нельзя, только добавлять. Списки всегда
+// массивы (не null), отсутствующие строки и время — null.
+
+type Rule struct {
+	ID        int64      `json:"id" yaml:"id"`
+	Chain     string     `json:"chain" yaml:"chain"`
+	Proto     string     `json:"proto" yaml:"proto"`
+	Action    string     `json:"action" yaml:"action"`
+	Enabled   bool       `json:"enabled" yaml:"enabled"`
+	InIf      *string    `json:"in_if" yaml:"in_if"`
+	OutIf     *string    `json:"out_if" yaml:"out_if"`
+	Ports     []int      `json:"ports" yaml:"ports"`
+	Src       []string   `json:"src" yaml:"src"`
+	Dst       []string   `json:"dst" yaml:"dst"`
+	ICMPTypes []int      `json:"icmp_types" yaml:"icmp_types"`
+	Comment   *string    `json:"comment" yaml:"comment"`
+	Profile   *string    `json:"profile" yaml:"profile"`
+	Origin    *string    `json:"origin" yaml:"origin"`
+	UpdatedAt *time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+type Rules []Rule
+
+func NewRules(rs []model.Rule) Rules {
+	out := make(Rules, 0, len(rs))
+	for _, r := range rs {
+		out = append(out, Rule{
+			ID: r.ID, Chain: r.Chain, Proto: r.Proto, Action: r.Action, Enabled: r.Enabled,
+			InIf: r.InIf, OutIf: r.OutIf,
+			Ports: ints(r.Ports), Src: strs(r.SrcCIDRs), Dst: strs(r.DstCIDRs), ICMPTypes: ints(r.ICMPTypes),
+			Comment: r.Comment, Profile: r.Profile, Origin: r.Origin, UpdatedAt: timePtr(r.UpdatedAt),
+		})
+	}
+	return out
+}
+
+func (rs Rules) CSV() ([]string, [][]string) {
+	header := []string{"id", "chain", "proto", "action", "enabled", "in_if", "out_if", "ports", "src", "dst", "icmp_types", "comment", "profile", "origin", "updated_at"}
+	var rows [][]string
+	for _, r := range rs {
+		rows = append(rows, []string{
+			fmt.Sprint(r.ID), r.Chain, r.Proto, r.Action, fmt.Sprint(r.Enabled), str(r.InIf), str(r.OutIf),
+			joinInts(r.Ports), strings.Join(r.Src, " "), strings.Join(r.Dst, " "), joinInts(r.ICMPTypes),
+			str(r.Comment), str(r.Profile), str(r.Origin), timeStr(r.UpdatedAt),
+		})
+	}
+	return header, rows
+}
+
+type Defaults struct {
+	InputPolicy   string `json:"input_policy" yaml:"input_policy"`
+	ForwardPolicy string `json:"forward_policy" yaml:"forward_policy"`
+	OutputPolicy  string `json:"output_policy" yaml:"output_policy"`
+	LogPrefix     string `json:"log_prefix" yaml:"log_prefix"`
+}
+
+func NewDefaults(d model.Defaults) Defaults {
+	return Defaults{InputPolicy: d.InputPolicy, ForwardPolicy: d.ForwardPolicy, OutputPolicy: d.OutputPolicy, LogPrefix: d.LogPrefix}
+}
+
+func (d Defaults) CSV() ([]string, [][]string) {
+	return []string{"input_policy", "forward_policy", "output_policy", "log_prefix"},
+		[][]string{{d.InputPolicy, d.ForwardPolicy, d.OutputPolicy, d.LogPrefix}}
+}
+
+// Preview — dryrun: политики и включённые правила. В CSV — только правила.
+type Preview struct {
+	Defaults Defaults `json:"defaults" yaml:"defaults"`
+	Rules    Rules    `json:"rules" yaml:"rules"`
+}
+
+func (p Preview) CSV() ([]string, [][]string) { return p.Rules.CSV() }
+
+type Revision struct {
+	Rev     int64     `json:"rev" yaml:"rev"`
+	Time    time.Time `json:"time" yaml:"time"`
+	Actor   string    `json:"actor" yaml:"actor"`
+	Message string    `json:"message" yaml:"message"`
+}
+
+type Revisions []Revision
+
+func NewRevisions(vs []model.Revision) Revisions {
+	out := make(Revisions, 0, len(vs))
+	for _, v := range vs {
+		out = append(out, Revision{Rev: v.Rev, Time: v.TS.UTC(), Actor: v.Actor, Message: v.Message})
+	}
+	return out
+}
+
+func (vs Revisions) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, v := range vs {
+		rows = append(rows, []string{fmt.Sprint(v.Rev), v.Time.Format(time.RFC3339), v.Actor, v.Message})
+	}
+	return []string{"rev", "time", "actor", "message"}, rows
+}
+
+type AuditEntry struct {
+	ID        int64     `json:"id" yaml:"id"`
+	Time      time.Time `json:"time" yaml:"time"`
+	Actor     string    `json:"actor" yaml:"actor"`
+	RealActor *string   `json:"real_actor" yaml:"real_actor"` // пользователь ОС; null у старых записей
+	Action    string    `json:"action" yaml:"action"`
+	Object    string    `json:"object" yaml:"object"`
+	// Details — разобранный JSON; если не разбирается — исходная строка.
+	Details any `json:"details" yaml:"details"`
+}
+
+type AuditEntries []AuditEntry
+
+func NewAuditEntries(es []model.AuditEntry) AuditEntries {
+	out := make(AuditEntries, 0, len(es))
+	for _, e := range es {
+		var d any
+		if json.Unmarshal([]byte(e.Details), &d) != nil {
+			d = e.Details
+		}
+		out = append(out, AuditEntry{ID: e.ID, Time: e.TS.UTC(), Actor: e.Actor, RealActor: strPtr(e.RealActor), Action: e.Action, Object: e.Object, Details: d})
+	}
+	return out
+}
+
+func (es AuditEntries) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, e := range es {
+		d, _ := json.Marshal(e.Details)
+		var real string
+		if e.RealActor != nil {
+			real = *e.RealActor
+		}
+		rows = append(rows, []string{fmt.Sprint(e.ID), e.Time.Format(time.RFC3339), e.Actor, e.Action, e.Object, string(d), real})
+	}
+	return []string{"id", "time", "actor", "action", "object", "details", "real_actor"}, rows
+}
+
+type AuditField struct {
+	Field  string `json:"field" yaml:"field"`
+	Op     string `json:"op" yaml:"op"` // added|removed|changed|unchanged
+	Before any    `json:"before" yaml:"before"`
+	After  any    `json:"after" yaml:"after"`
+}
+
+// AuditShow — запись с разбором изменений; Changes пуст у записей без
+// before/after.
+type AuditShow struct {
+	AuditEntry `yaml:",inline"`
+	Changes    []AuditField `json:"changes" yaml:"changes"`
+	Info       any          `json:"info" yaml:"info"`
+}
+
+func NewAuditShow(e model.AuditEntry, fields []service.FieldDiff, info any) AuditShow {
+	v := AuditShow{AuditEntry: NewAuditEntries([]model.AuditEntry{e})[0], Changes: []AuditField{}, Info: info}
+	for _, f := range fields {
+		v.Changes = append(v.Changes, AuditField{Field: f.Field, Op: f.Op, Before: f.Before, After: f.After})
+	}
+	return v
+}
+
+func (v AuditShow) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, f := range v.Changes {
+		b, _ := json.Marshal(f.Before)
+		a, _ := json.Marshal(f.After)
+		rows = append(rows, []string{f.Field, f.Op, string(b), string(a)})
+	}
+	return []string{"field", "op", "before", "after"}, rows
+}
+
+type AuditProblem struct {
+	ID      int64  `json:"id" yaml:"id"`
+	Message string `json:"message" yaml:"message"`
+}
+
+type AuditVerify struct {
+	OK         bool           `json:"ok" yaml:"ok"`
+	Entries    int            `json:"entries" yaml:"entries"`
+	WithHMAC   int            `json:"with_hmac" yaml:"with_hmac"`
+	Unchecked  int            `json:"hmac_unchecked" yaml:"hmac_unchecked"`
+	PrunedUpTo *int64         `json:"pruned_up_to" yaml:"pruned_up_to"`
+	Problems   []AuditProblem `json:"problems" yaml:"problems"`
+}
+
+func NewAuditVerify(res auditchain.Result) AuditVerify {
+	v := AuditVerify{OK: len(res.Problems) == 0, Entries: res.Entries, WithHMAC: res.WithHMAC, Unchecked: res.Unchecked, Problems: []AuditProblem{}}
+	if res.PrunedUpTo > 0 {
+		v.PrunedUpTo = &res.PrunedUpTo
+	}
+	for _, p := range res.Problems {
+		v.Problems = append(v.Problems, AuditProblem{ID: p.ID, Message: p.Message})
+	}
+	return v
+}
+
+func (v AuditVerify) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, p := range v.Problems {
+		rows = append(rows, []string{fmt.Sprint(p.ID), p.Message})
+	}
+	return []string{"id", "problem"}, rows
+}
+
+type AuditPrune struct {
+	DryRun  bool    `json:"dry_run" yaml:"dry_run"`
+	Deleted int     `json:"deleted" yaml:"deleted"`
+	FirstID *int64  `json:"first_id" yaml:"first_id"`
+	LastID  *int64  `json:"last_id" yaml:"last_id"`
+	Archive *string `json:"archive" yaml:"archive"`
+	Held    int     `json:"held" yaml:"held"`
+}
+
+func NewAuditPrune(res service.PruneResult, dry bool) AuditPrune {
+	v := AuditPrune{DryRun: dry, Deleted: res.Deleted, Held: res.Held}
+	if res.Deleted > 0 {
+		v.FirstID, v.LastID = &res.FirstID, &res.LastID
+	}
+	if res.Archive != "" {
+		v.Archive = &res.Archive
+	}
+	return v
+}
+
+func (v AuditPrune) CSV() ([]string, [][]string) {
+	var first, last, archive string
+	if v.FirstID != nil {
+		first, last = fmt.Sprint(*v.FirstID), fmt.Sprint(*v.LastID)
+	}
+	if v.Archive != nil {
+		archive = *v.Archive
+	}
+	return []string{"dry_run", "deleted", "first_id", "last_id", "archive", "held"},
+		[][]string{{fmt.Sprint(v.DryRun), fmt.Sprint(v.Deleted), first, last, archive, fmt.Sprint(v.Held)}}
+}
+
+type AuditSink struct {
+	Sink    string  `json:"sink" yaml:"sink"`
+	Sent    int     `json:"sent" yaml:"sent"`
+	Pending int     `json:"pending" yaml:"pending"`
+	Error   *string `json:"error" yaml:"error"`
+}
+
+type AuditSinks []AuditSink
+
+func NewAuditSinks(rs []service.ForwardResult) AuditSinks {
+	out := make(AuditSinks, 0, len(rs))
+	for _, r := range rs {
+		v := AuditSink{Sink: r.Sink, Sent: r.Sent, Pending: r.Pending}
+		if r.Err != nil {
+			msg := r.Err.Error()
+			v.Error = &msg
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func (v AuditSinks) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, s := range v {
+		var e string
+		if s.Error != nil {
+			e = *s.Error
+		}
+		rows = append(rows, []string{s.Sink, fmt.Sprint(s.Sent), fmt.Sprint(s.Pending), e})
+	}
+	return []string{"sink", "sent", "pending", "error"}, rows
+}
+
+type Request struct {
+	ID           int64      `json:"id" yaml:"id"`
+	Status       string     `json:"status" yaml:"status"`
+	Author       string     `json:"author" yaml:"author"`
+	Reviewer     *string    `json:"reviewer" yaml:"reviewer"`
+	Reason       string     `json:"reason" yaml:"reason"`
+	Note         *string    `json:"note" yaml:"note"`
+	BaseRevision int64      `json:"base_revision" yaml:"base_revision"`
+	CreatedAt    time.Time  `json:"created_at" yaml:"created_at"`
+	ReviewedAt   *time.Time `json:"reviewed_at" yaml:"reviewed_at"`
+	AppliedAt    *time.Time `json:"applied_at" yaml:"applied_at"`
+	RealAuthor   string     `json:"real_author" yaml:"real_author"`
+	RealReviewer *string    `json:"real_reviewer" yaml:"real_reviewer"`
+}
+
+type Requests []Request
+
+func NewRequests(crs []model.ChangeRequest) Requests {
+	out := make(Requests, 0, len(crs))
+	for _, cr := range crs {
+		out = append(out, Request{
+			ID: cr.ID, Status: cr.Status, Author: cr.Author, Reviewer: strPtr(cr.Reviewer), Reason: cr.Reason,
+			Note: strPtr(cr.Note), BaseRevision: cr.BaseRevision, CreatedAt: cr.CreatedAt.UTC(),
+			ReviewedAt: timePtr(cr.ReviewedAt), AppliedAt: timePtr(cr.AppliedAt),
+			RealAuthor: cr.RealAuthor, RealReviewer: strPtr(cr.RealReviewer),
+		})
+	}
+	return out
+}
+
+func (rs Requests) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, r := range rs {
+		rows = append(rows, []string{fmt.Sprint(r.ID), r.Status, r.Author, str(r.Reviewer), r.Reason, str(r.Note),
+			fmt.Sprint(r.BaseRevision), r.CreatedAt.Format(time.RFC3339), timeStr(r.ReviewedAt), timeStr(r.AppliedAt),
+			r.RealAuthor, str(r.RealReviewer)})
+	}
+	return []string{"id", "status", "author", "reviewer", "reason", "note", "base_revision", "created_at", "reviewed_at", "applied_at", "real_author", "real_reviewer"}, rows
+}
+
+type Finding struct {
+	Kind   string `json:"kind" yaml:"kind"`
+	Chain  string `json:"chain" yaml:"chain"`
+	RuleID int64  `json:"rule_id" yaml:"rule_id"`
+	ByID   int64  `json:"by_id" yaml:"by_id"`
+	Detail string `json:"detail" yaml:"detail"`
+}
+
+type Findings []Finding
+
+func NewFindings(fs []analyze.Finding) Findings {
+	out := make(Findings, 0, len(fs))
+	for _, f := range fs {
+		out = append(out, Finding{Kind: f.Kind, Chain: f.Chain, RuleID: f.RuleID, ByID: f.ByID, Detail: f.Detail})
+	}
+	return out
+}
+
+func (fs Findings) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, f := range fs {
+		rows = append(rows, []string{f.Kind, f.Chain, fmt.Sprint(f.RuleID), fmt.Sprint(f.ByID), f.Detail})
+	}
+	return []string{"kind", "chain", "rule_id", "by_id", "detail"}, rows
+}
+
+type Issue struct {
+	Check    string `json:"check" yaml:"check"`
+	Severity string `json:"severity" yaml:"severity"`
+	RuleID   int64  `json:"rule_id,omitempty" yaml:"rule_id,omitempty"`
+	Message  string `json:"message" yaml:"message"`
+}
+
+type Issues []Issue
+
+func NewIssues(is []lint.Issue) Issues {
+	out := make(Issues, 0, len(is))
+	for _, i := range is {
+		out = append(out, Issue(i))
+	}
+	return out
+}
+
+func (is Issues) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, i := range is {
+		id := ""
+		if i.RuleID != 0 {
+			id = fmt.Sprint(i.RuleID)
+		}
+		rows = append(rows, []string{i.Severity, i.Check, id, i.Message})
+	}
+	return []string{"severity", "check", "rule_id", "message"}, rows
+}
+
+type FQDN struct {
+	Name       string     `json:"name" yaml:"name"`
+	Addrs      []string   `json:"addrs" yaml:"addrs"`
+	ResolvedAt *time.Time `json:"resolved_at" yaml:"resolved_at"`
+	ExpiresAt  *time.Time `json:"expires_at" yaml:"expires_at"`
+	Error      *string    `json:"error" yaml:"error"`
+}
+
+type FQDNs []FQDN
+
+func NewFQDNs(es []model.FQDNEntry) FQDNs {
+	out := make(FQDNs, 0, len(es))
+	for _, e := range es {
+		out = append(out, FQDN{Name: e.Name, Addrs: strs(e.Addrs), ResolvedAt: timePtr(e.ResolvedAt),
+			ExpiresAt: timePtr(e.ExpiresAt), Error: strPtr(e.Error)})
+	}
+	return out
+}
+
+func (es FQDNs) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, e := range es {
+		rows = append(rows, []string{e.Name, strings.Join(e.Addrs, " "), timeStr(e.ResolvedAt), timeStr(e.ExpiresAt), str(e.Error)})
+	}
+	return []string{"name", "addrs", "resolved_at", "expires_at", "error"}, rows
+}
+
+type Zone struct {
+	Name        string   `json:"name" yaml:"name"`
+	InputPolicy string   `json:"input_policy" yaml:"input_policy"`
+	Ifaces      []string `json:"ifaces" yaml:"ifaces"`
+}
+
+type User struct {
+	Name string `json:"name" yaml:"name"`
+	Role string `json:"role" yaml:"role"`
+}
+
+type Users []User
+
+func NewUsers(us []model.User) Users {
+	out := make(Users, 0, len(us))
+	for _, u := range us {
+		out = append(out, User(u))
+	}
+	return out
+}
+
+func (v Users) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, u := range v {
+		rows = append(rows, []string{u.Name, u.Role})
+	}
+	return []string{"name", "role"}, rows
+}
+
+type ZonePolicy struct {
+	From   string `json:"from" yaml:"from"`
+	To     string `json:"to" yaml:"to"`
+	Action string `json:"action" yaml:"action"`
+}
+
+// Zones — зоны и forward-политики между ними. В CSV — только зоны.
+type Zones struct {
+	Zones   []Zone       `json:"zones" yaml:"zones"`
+	Forward []ZonePolicy `json:"forward" yaml:"forward"`
+}
+
+func NewZones(zs []model.Zone, ps []model.ZonePolicy) Zones {
+	out := Zones{Zones: []Zone{}, Forward: []ZonePolicy{}}
+	for _, z := range zs {
+		out.Zones = append(out.Zones, Zone{Name: z.Name, InputPolicy: z.InputPolicy, Ifaces: strs(z.Ifaces)})
+	}
+	for _, p := range ps {
+		out.Forward = append(out.Forward, ZonePolicy(p))
+	}
+	return out
+}
+
+func (z Zones) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, x := range z.Zones {
+		rows = append(rows, []string{x.Name, x.InputPolicy, strings.Join(x.Ifaces, " ")})
+	}
+	return []string{"name", "input_policy", "ifaces"}, rows
+}
+
+type ProfileEntry struct {
+	Proto string `json:"proto" yaml:"proto"`
+	Ports []int  `json:"ports" yaml:"ports"`
+}
+
+type Profile struct {
+	Name        string         `json:"name" yaml:"name"`
+	Description string         `json:"description" yaml:"description"`
+	Source      string         `json:"source" yaml:"source"`
+	Rules       []ProfileEntry `json:"rules" yaml:"rules"`
+}
+
+type Profiles []Profile
+
+func NewProfiles(cat profile.Catalog) Profiles {
+	out := Profiles{}
+	for _, n := range cat.Names() {
+		p := cat[n]
+		v := Profile{Name: p.Name, Description: p.Description, Source: p.Source, Rules: []ProfileEntry{}}
+		for _, e := range p.Rules {
+			v.Rules = append(v.Rules, ProfileEntry{Proto: e.Proto, Ports: ints(e.Ports)})
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func (ps Profiles) CSV() ([]string, [][]string) {
+	var rows [][]string
+	for _, p := range ps {
+		var es []string
+		for _, e := range p.Rules {
+			es = append(es, e.Proto+"/"+strings.ReplaceAll(joinInts(e.Ports), " ", ","))
+		}
+		rows = append(rows, []string{p.Name, strings.Join(es, " "), p.Source, p.Description})
+	}
+	return []string{"name", "rules", "source", "description"}, rows
+}
+
+type Applied struct {
+	Revision  int64     `json:"revision" yaml:"revision"`
+	AppliedAt time.Time `json:"applied_at" yaml:"applied_at"`
+	Actor     string    `json:"actor" yaml:"actor"`
+	Hash      string    `json:"sha256" yaml:"sha256"`
+}
+
+// Status — состояние apply (status). CSV не поддерживается.
+type Status struct {
+	Applied        *Applied `json:"applied" yaml:"applied"` // null — apply не выполнялся
+	Revision       int64    `json:"revision" yaml:"revision"`
+	PendingChanges int      `json:"pending_changes" yaml:"pending_changes"`
+	ScriptChanged  bool     `json:"script_changed" yaml:"script_changed"`
+}
+
+func NewStatus(st service.Status) Status {
+	out := Status{Revision: st.Revision, PendingChanges: st.PendingCount(), ScriptChanged: !st.Unchanged}
+	if st.Ever {
+		out.Applied = &Applied{Revision: st.Applied.Revision, AppliedAt: st.Applied.AppliedAt.UTC(), Actor: st.Applied.Actor, Hash: st.Applied.Hash}
+	}
+	return out
+}
+
+func ints(xs []int) []int {
+	if xs == nil {
+		return []int{}
+	}
+	return xs
+}
+
+func strs(xs []string) []string {
+	if xs == nil {
+		return []string{}
+	}
+	return xs
+}
+
+func joinInts(xs []int) string {
+	parts := make([]string, len(xs))
+	for i, x := range xs {
+		parts[i] = fmt.Sprint(x)
+	}
+	return strings.Join(parts, " ")
+}
+
+func strPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func str(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func timeStr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
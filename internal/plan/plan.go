@@ -0,0 +1,462 @@
+package plan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"netfence/internal/model"
+)
+
+// Операции над правилами.
+const (
+	Create = "create"
+	Update = "update"
+	Delete = "delete"
+)
+
+// FieldChange — изменение одного атрибута.
+type FieldChange struct {
+	Name, Old, New string
+}
+
+// Change — изменение одного правила. Old задан для update/delete, New — для
+// create/update (при update New.ID == Old.ID).
+type Change struct {
+	Op     string
+	Key    string
+	Old    *model.Rule
+	New    *model.Rule
+	Fields []FieldChange
+}
+
+// DefaultsChange — изменение политик по умолчанию.
+type DefaultsChange struct {
+	Old, New model.Defaults
+	Fields   []FieldChange
+}
+
+// ZonesChange — изменение зон и forward-матрицы. Fields — по элементу на
+// зону ("zone lan") или ячейку матрицы ("forward lan->wan"); пустой Old —
+// добавление, пустой New — удаление.
+type ZonesChange struct {
+	Old, New model.ZoneSet
+	Fields   []FieldChange
+}
+
+// Plan — разница между текущим и желаемым состоянием.
+type Plan struct {
+	Defaults *DefaultsChange
+	Zones    *ZonesChange
+	Rules    []Change
+	// Order — итоговый набор правил по порядку; у новых правил ID
+	// заполняется при выполнении плана (те же указатели, что в Change.New).
+	Order []*model.Rule
+}
+
+func (p Plan) Empty() bool { return p.Defaults == nil && p.Zones == nil && len(p.Rules) == 0 }
+
+// Counts — число правил к созданию, изменению и удалению (defaults — изменение,
+// зоны и ячейки forward-матрицы считаются как правила).
+func (p Plan) Counts() (add, change, del int) {
+	if p.Defaults != nil {
+		change++
+	}
+	if p.Zones != nil {
+		for _, f := range p.Zones.Fields {
+			switch {
+			case f.Old == "":
+				add++
+			case f.New == "":
+				del++
+			default:
+				change++
+			}
+		}
+	}
+	for _, c := range p.Rules {
+		switch c.Op {
+		case Create:
+			add++
+		case Update:
+			change++
+		case Delete:
+			del++
+		}
+	}
+	return
+}
+
+// Key — стабильный ключ правила: всё, что влияет на совпадение пакета и
+// вердикт. ID, comment, enabled, profile, origin и позиция в ключ не входят —
+// их изменение даёт update, а не пересоздание.
+func Key(r model.Rule) string {
+	parts := []string{r.Chain, r.Proto, r.Action}
+	if r.InIf != nil {
+		parts = append(parts, "in="+*r.InIf)
+	}
+	if r.OutIf != nil {
+		parts = append(parts, "out="+*r.OutIf)
+	}
+	if len(r.Ports) > 0 {
+		parts = append(parts, "ports="+ints(r.Ports))
+	}
+	if len(r.SrcCIDRs) > 0 {
+		parts = append(parts, "src="+strs(r.SrcCIDRs))
+	}
+	if len(r.DstCIDRs) > 0 {
+		parts = append(parts, "dst="+strs(r.DstCIDRs))
+	}
+	if len(r.ICMPTypes) > 0 {
+		parts = append(parts, "icmp="+ints(r.ICMPTypes))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Diff сравнивает правила по Key. Правила с одинаковым ключом сопоставляются
+// по порядку; итоговый порядок — порядок желаемого файла (см. order). Нулевые
+// want.Defaults и want.Zones означают, что defaults и зоны файлом не
+// управляются.
+func Diff(cur, want model.Snapshot) Plan {
+	var p Plan
+	if want.Defaults != (model.Defaults{}) {
+		if f := defaultsFields(cur.Defaults, want.Defaults); len(f) > 0 {
+			p.Defaults = &DefaultsChange{Old: cur.Defaults, New: want.Defaults, Fields: f}
+		}
+	}
+	p.Zones = zonesChange(cur.Zones, want.Zones)
+
+	byKey := map[string][]int{}
+	for i, r := range cur.Rules {
+		k := Key(r)
+		byKey[k] = append(byKey[k], i)
+	}
+	used := map[int]bool{}
+	seq := make([]slot, 0, len(want.Rules))
+	for _, w := range want.Rules {
+		k := Key(w)
+		if len(byKey[k]) == 0 {
+			seq = append(seq, slot{cur: -1, rule: w})
+			continue
+		}
+		i := byKey[k][0]
+		byKey[k] = byKey[k][1:]
+		used[i] = true
+		w.ID = cur.Rules[i].ID
+		seq = append(seq, slot{cur: i, rule: w, fields: ruleFields(cur.Rules[i], w)})
+	}
+	p.order(cur.Rules, seq)
+	for i := range cur.Rules {
+		if r := cur.Rules[i]; !used[i] {
+			p.Rules = append(p.Rules, Change{Op: Delete, Key: Key(r), Old: &r})
+		}
+	}
+	return p
+}
+
+// Режимы import.
+const (
+	ModeReplace = "replace" // привести набор к файлу; правила с известным ID обновляются на месте
+	ModeMerge   = "merge"   // обновить совпавшие на месте, добавить новые
+	ModeAppend  = "append"  // добавить все правила файла в конец
+)
+
+// Import — план импорта want в режиме mode. Правило файла с ID, который
+// есть в текущем наборе, обновляется на месте целиком (replace и merge), так
+// что ID не меняются. В merge остальные правила сопоставляются по Key и у
+// совпавших меняются только comment/enabled/profile/origin. replace удаляет
+// всё, что не совпало по ID, prune (только merge) — всё, что не совпало
+// вообще. Defaults меняются в replace и merge, если заданы в файле; append
+// их не трогает; так же и зоны. Порядок правил файла сохраняется; правила, которых в файле
+// нет, но которые остаются (merge без prune), стоят за тем же правилом, что
+// и раньше; append добавляет правила файла в конец.
+func Import(cur, want model.Snapshot, mode string, prune bool) (Plan, error) {
+	switch {
+	case mode != ModeReplace && mode != ModeMerge && mode != ModeAppend:
+		return Plan{}, fmt.Errorf("bad import mode %q (%s|%s|%s)", mode, ModeReplace, ModeMerge, ModeAppend)
+	case prune && mode != ModeMerge:
+		return Plan{}, fmt.Errorf("prune works only with mode %s", ModeMerge)
+	}
+	var p Plan
+	if mode != ModeAppend && want.Defaults != (model.Defaults{}) {
+		if f := defaultsFields(cur.Defaults, want.Defaults); len(f) > 0 {
+			p.Defaults = &DefaultsChange{Old: cur.Defaults, New: want.Defaults, Fields: f}
+		}
+	}
+	if mode != ModeAppend {
+		p.Zones = zonesChange(cur.Zones, want.Zones)
+	}
+
+	// match[j] — индекс в cur правила, с которым сопоставлено want.Rules[j]
+	match := make([]int, len(want.Rules))
+	used := map[int]bool{}
+	byID := map[int64]int{}
+	for i, r := range cur.Rules {
+		byID[r.ID] = i
+	}
+	for j, w := range want.Rules {
+		match[j] = -1
+		if i, ok := byID[w.ID]; ok && w.ID != 0 && mode != ModeAppend && !used[i] {
+			match[j] = i
+			used[i] = true
+		}
+	}
+	if mode == ModeMerge {
+		for j, w := range want.Rules {
+			if match[j] >= 0 {
+				continue
+			}
+			for i := range cur.Rules {
+				if !used[i] && Key(cur.Rules[i]) == Key(w) {
+					match[j] = i
+					used[i] = true
+					break
+				}
+			}
+		}
+	}
+
+	var seq []slot
+	for j, w := range want.Rules {
+		i := match[j]
+		if i < 0 {
+			seq = append(seq, slot{cur: -1, rule: w})
+			continue
+		}
+		f := ruleFields(cur.Rules[i], w)
+		if w.ID == cur.Rules[i].ID {
+			f = allFields(cur.Rules[i], w)
+		}
+		w.ID = cur.Rules[i].ID
+		seq = append(seq, slot{cur: i, rule: w, fields: f})
+	}
+	var deleted []int
+	for i := range cur.Rules {
+		switch {
+		case used[i]:
+		case mode == ModeAppend || (mode == ModeMerge && !prune):
+			seq = keep(seq, i, cur.Rules[i])
+		default:
+			deleted = append(deleted, i)
+		}
+	}
+	p.order(cur.Rules, seq)
+	for _, i := range deleted {
+		r := cur.Rules[i]
+		p.Rules = append(p.Rules, Change{Op: Delete, Key: Key(r), Old: &r})
+	}
+	return p, nil
+}
+
+// slot — правило итогового набора: cur — индекс совпавшего текущего правила
+// (-1 — новое), fields — изменения атрибутов без учёта позиции.
+type slot struct {
+	cur    int
+	rule   model.Rule
+	fields []FieldChange
+}
+
+// keep ставит оставшееся без изменений текущее правило i за ближайшим
+// предшествующим ему текущим правилом из seq (нет такого — в начало, а в
+// append все текущие правила идут раньше правил файла).
+func keep(seq []slot, i int, r model.Rule) []slot {
+	at := 0
+	for k, s := range seq {
+		if s.cur >= 0 && s.cur < i {
+			at = k + 1
+		}
+	}
+	seq = append(seq, slot{})
+	copy(seq[at+1:], seq[at:])
+	seq[at] = slot{cur: i, rule: r}
+	return seq
+}
+
+// order заполняет Rules (create/update в итоговом порядке) и Order. Из
+// совпавших правил на месте остаётся наибольшая цепочка, уже идущая в нужном
+// порядке; остальные получают изменение position (старый и новый номер в
+// наборе, с 1) — так удаление или вставка правила не двигает соседей.
+func (p *Plan) order(cur []model.Rule, seq []slot) {
+	var idx []int
+	for _, s := range seq {
+		if s.cur >= 0 {
+			idx = append(idx, s.cur)
+		}
+	}
+	stay := increasing(idx)
+	for n, s := range seq {
+		r := s.rule
+		if s.cur < 0 {
+			r.ID = 0
+			p.Rules = append(p.Rules, Change{Op: Create, Key: Key(r), New: &r})
+			p.Order = append(p.Order, &r)
+			continue
+		}
+		f := s.fields
+		if !stay[s.cur] {
+			f = append(f, FieldChange{"position", fmt.Sprint(s.cur + 1), fmt.Sprint(n + 1)})
+		}
+		if len(f) > 0 {
+			old := cur[s.cur]
+			p.Rules = append(p.Rules, Change{Op: Update, Key: Key(r), Old: &old, New: &r, Fields: f})
+		}
+		p.Order = append(p.Order, &r)
+	}
+}
+
+// increasing — элементы наибольшей возрастающей подпоследовательности xs.
+func increasing(xs []int) map[int]bool {
+	var tails []int // tails[k] — индекс в xs последнего элемента цепочки длины k+1
+	prev := make([]int, len(xs))
+	for i, x := range xs {
+		k := sort.Search(len(tails), func(k int) bool { return xs[tails[k]] >= x })
+		prev[i] = -1
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	out := map[int]bool{}
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			out[xs[i]] = true
+		}
+	}
+	return out
+}
+
+// allFields — ruleFields плюс поля, входящие в Key.
+func allFields(a, b model.Rule) []FieldChange {
+	var out []FieldChange
+	add := func(name, x, y string) {
+		if x != y {
+			out = append(out, FieldChange{name, x, y})
+		}
+	}
+	add("chain", a.Chain, b.Chain)
+	add("proto", a.Proto, b.Proto)
+	add("action", a.Action, b.Action)
+	add("in_if", optStr(a.InIf), optStr(b.InIf))
+	add("out_if", optStr(a.OutIf), optStr(b.OutIf))
+	add("ports", ints(a.Ports), ints(b.Ports))
+	add("src", strs(a.SrcCIDRs), strs(b.SrcCIDRs))
+	add("dst", strs(a.DstCIDRs), strs(b.DstCIDRs))
+	add("icmp_types", ints(a.ICMPTypes), ints(b.ICMPTypes))
+	return append(out, ruleFields(a, b)...)
+}
+
+func ruleFields(a, b model.Rule) []FieldChange {
+	var out []FieldChange
+	if s1, s2 := optStr(a.Comment), optStr(b.Comment); s1 != s2 {
+		out = append(out, FieldChange{"comment", s1, s2})
+	}
+	if a.Enabled != b.Enabled {
+		out = append(out, FieldChange{"enabled", fmt.Sprint(a.Enabled), fmt.Sprint(b.Enabled)})
+	}
+	if s1, s2 := optStr(a.Profile), optStr(b.Profile); s1 != s2 {
+		out = append(out, FieldChange{"profile", s1, s2})
+	}
+	if s1, s2 := optStr(a.Origin), optStr(b.Origin); s1 != s2 {
+		out = append(out, FieldChange{"origin", s1, s2})
+	}
+	return out
+}
+
+func defaultsFields(a, b model.Defaults) []FieldChange {
+	var out []FieldChange
+	add := func(name, x, y string) {
+		if x != y {
+			out = append(out, FieldChange{name, x, y})
+		}
+	}
+	add("input_policy", a.InputPolicy, b.InputPolicy)
+	add("forward_policy", a.ForwardPolicy, b.ForwardPolicy)
+	add("output_policy", a.OutputPolicy, b.OutputPolicy)
+	add("log_prefix", a.LogPrefix, b.LogPrefix)
+	return out
+}
+
+// zonesChange: want nil — зоны не трогаем; cur nil — зон не было.
+func zonesChange(cur, want *model.ZoneSet) *ZonesChange {
+	if want == nil {
+		return nil
+	}
+	var old model.ZoneSet
+	if cur != nil {
+		old = *cur
+	}
+	var f []FieldChange
+	add := func(name, x, y string) {
+		if x != y {
+			f = append(f, FieldChange{name, x, y})
+		}
+	}
+	zones := func(zs model.ZoneSet) map[string]string {
+		m := map[string]string{}
+		for _, z := range zs.List {
+			ifs := append([]string(nil), z.Ifaces...)
+			sort.Strings(ifs)
+			m[z.Name] = "input=" + z.InputPolicy + " ifaces=" + strings.Join(ifs, ",")
+		}
+		return m
+	}
+	forward := func(zs model.ZoneSet) map[string]string {
+		m := map[string]string{}
+		for _, p := range zs.Forward {
+			m[p.From+"->"+p.To] = p.Action
+		}
+		return m
+	}
+	for _, pair := range []struct {
+		kind     string
+		old, new map[string]string
+	}{{"zone", zones(old), zones(*want)}, {"forward", forward(old), forward(*want)}} {
+		var names []string
+		for n := range pair.old {
+			names = append(names, n)
+		}
+		for n := range pair.new {
+			if _, ok := pair.old[n]; !ok {
+				names = append(names, n)
+			}
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			add(pair.kind+" "+n, pair.old[n], pair.new[n])
+		}
+	}
+	if len(f) == 0 {
+		return nil
+	}
+	return &ZonesChange{Old: old, New: *want, Fields: f}
+}
+
+func optStr(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+func ints(xs []int) string {
+	s := append([]int(nil), xs...)
+	sort.Ints(s)
+	out := make([]string, len(s))
+	for i, x := range s {
+		out[i] = fmt.Sprint(x)
+	}
+	return strings.Join(out, ",")
+}
+
+func strs(xs []string) string {
+	s := make([]string, len(xs))
+	for i, x := range xs {
+		s[i] = strings.ToLower(x)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,115 @@
+// Package profile — каталог профилей приложений (ufw-style "allow ssh"):
+// встроенные профили плюс YAML-файлы из /etc/netfence/profiles.d.
+package profile
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"netfence/internal/model"
+	"netfence/internal/util"
+)
+
+const DefaultDir = "/etc/netfence/profiles.d"
+
+// Entry — протокол и порты, которые открывает профиль.
+type Entry struct {
+	Proto string `yaml:"proto"`
+	Ports []int  `yaml:"ports"`
+}
+
+type Profile struct {
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description"`
+	Rules       []Entry `yaml:"rules"`
+	Source      string  `yaml:"-"` // "builtin" или путь к файлу
+}
+
+var builtin = []Profile{
+	{Name: "ssh", Description: "OpenSSH server", Rules: []Entry{{"tcp", []int{22}}}},
+	{Name: "http", Description: "Web server (HTTP)", Rules: []Entry{{"tcp", []int{80}}}},
+	{Name: "https", Description: "Web server (HTTPS)", Rules: []Entry{{"tcp", []int{443}}}},
+	{Name: "dns", Description: "DNS server", Rules: []Entry{{"tcp", []int{53}}, {"udp", []int{53}}}},
+	{Name: "postgres", Description: "PostgreSQL server", Rules: []Entry{{"tcp", []int{5432}}}},
+}
+
+// Catalog — профили по имени.
+type Catalog map[string]Profile
+
+// Load возвращает встроенные профили, дополненные (и переопределённые)
+// файлами *.yaml из dir. Отсутствующий каталог — не ошибка.
+func Load(dir string) (Catalog, error) {
+	c := Catalog{}
+	for _, p := range builtin {
+		p.Source = "builtin"
+		c[p.Name] = p
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		var p Profile
+		if err := util.ReadYAML(f, &p); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", f, err)
+		}
+		if p.Name == "" {
+			p.Name = trimExt(filepath.Base(f))
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", f, err)
+		}
+		p.Source = f
+		c[p.Name] = p
+	}
+	return c, nil
+}
+
+func (c Catalog) Names() []string {
+	out := make([]string, 0, len(c))
+	for n := range c {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Expand превращает профиль в правила по шаблону tmpl (chain, action,
+// интерфейсы, адреса, комментарий); proto и ports берутся из профиля.
+func (c Catalog) Expand(name string, tmpl model.Rule) ([]model.Rule, error) {
+	p, ok := c[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found", name)
+	}
+	out := make([]model.Rule, 0, len(p.Rules))
+	for _, e := range p.Rules {
+		r := tmpl
+		r.ID = 0
+		r.Proto = e.Proto
+		r.Ports = append([]int(nil), e.Ports...)
+		n := p.Name
+		r.Profile = &n
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (p Profile) validate() error {
+	if len(p.Rules) == 0 {
+		return fmt.Errorf("no rules")
+	}
+	for _, e := range p.Rules {
+		if e.Proto != "tcp" && e.Proto != "udp" {
+			return fmt.Errorf("proto %q: profiles open tcp/udp ports", e.Proto)
+		}
+		if len(e.Ports) == 0 {
+			return fmt.Errorf("%s entry without ports", e.Proto)
+		}
+	}
+	return nil
+}
+
+func trimExt(s string) string { return s[:len(s)-len(filepath.Ext(s))] }
+
@@ -0,0 +1,68 @@
+package render
+
+import (
+	"fmt"
+
+	"netfence/internal/model"
+)
+
+// AddrResolver возвращает CIDR-ы для ссылки iface:<if>:<kind>.
+type AddrResolver func(iface, kind string) ([]string, error)
+
+// ExpandIfaceRefs подставляет вместо ссылок на интерфейсы их текущие адреса.
+// Правило, у которого ссылка ничего не дала (интерфейс без адреса), не может
+// совпасть ни с одним пакетом, поэтому в результат не попадает.
+func ExpandIfaceRefs(rules []model.Rule, resolve AddrResolver) ([]model.Rule, error) {
+	out := make([]model.Rule, 0, len(rules))
+	for _, r := range rules {
+		src, okS, err := expandList(r.SrcCIDRs, resolve)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", r.ID, err)
+		}
+		dst, okD, err := expandList(r.DstCIDRs, resolve)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", r.ID, err)
+		}
+		if !okS || !okD {
+			continue
+		}
+		r.SrcCIDRs, r.DstCIDRs = src, dst
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// IfaceRefs возвращает множество интерфейсов, на которые ссылаются правила.
+func IfaceRefs(rules []model.Rule) map[string]bool {
+	out := map[string]bool{}
+	for _, r := range rules {
+		for _, lst := range [][]string{r.SrcCIDRs, r.DstCIDRs} {
+			for _, s := range lst {
+				if ref, ok := model.ParseIfaceRef(s); ok {
+					out[ref.Iface] = true
+				}
+			}
+		}
+	}
+	return out
+}
+
+func expandList(in []string, resolve AddrResolver) ([]string, bool, error) {
+	if len(in) == 0 {
+		return in, true, nil
+	}
+	var out []string
+	for _, s := range in {
+		ref, ok := model.ParseIfaceRef(s)
+		if !ok {
+			out = append(out, s)
+			continue
+		}
+		addrs, err := resolve(ref.Iface, ref.Kind)
+		if err != nil {
+			return nil, false, err
+		}
+		out = append(out, addrs...)
+	}
+	return out, len(out) > 0, nil
+}
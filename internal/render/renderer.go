@@ -7,23 +7,47 @@ import (
 	"netfence/internal/model"
 )
 
+// Ruleset — всё, что нужно рендеру помимо правил: текущие адреса DNS-имён,
+// зоны и матрица forward-политик между ними.
+type Ruleset struct {
+	Defaults     model.Defaults
+	Rules        []model.Rule
+	FQDNs        map[string][]string
+	Zones        []model.Zone
+	ZonePolicies []model.ZonePolicy
+}
+
 // Render собирает ruleset в правильный синтаксис nftables
 func Render(def model.Defaults, rules []model.Rule) string {
+	return RenderRuleset(Ruleset{Defaults: def, Rules: rules})
+}
+
+func RenderRuleset(rs Ruleset) string {
 	var b strings.Builder
+	def, rules := rs.Defaults, rs.Rules
 
 	b.WriteString("flush ruleset\n\n")
 	b.WriteString("table inet netfence {\n")
 
+	// именованные множества для DNS-имён (обновляются на месте резолвером)
+	for _, name := range FQDNNames(rules) {
+		b.WriteString(setBlock(name, rs.FQDNs[name]) + "\n")
+	}
+
+	// цепочки зон объявляем до базовых, которые на них прыгают
+	inDispatch, fwdDispatch := renderZones(&b, rs.Zones, rs.ZonePolicies)
+
 	// цепочки
-	renderChain(&b, "input", def.InputPolicy, rules)
-	renderChain(&b, "forward", def.ForwardPolicy, rules)
-	renderChain(&b, "output", def.OutputPolicy, rules)
+	renderChain(&b, "input", def.InputPolicy, rules, inDispatch)
+	renderChain(&b, "forward", def.ForwardPolicy, rules, fwdDispatch)
+	renderChain(&b, "output", def.OutputPolicy, rules, "")
 
 	b.WriteString("}\n")
 	return b.String()
 }
 
-func renderChain(b *strings.Builder, name, policy string, rules []model.Rule) {
+// renderChain: dispatch — переход в цепочки зон после правил из БД (может быть пустым).
+func renderChain(b *strings.Builder, name, policy string, rules []model.Rule, dispatch string) {
 	fmt.Fprintf(b, "  chain %s {\n", name)
 	fmt.Fprintf(b, "    type filter hook %s priority 0; policy %s;\n", name, policy)
 
@@ -35,15 +59,45 @@ func renderChain(b *strings.Builder, name, policy string, rules []model.Rule) {
 		if r.Chain != name || !r.Enabled {
 			continue
 		}
-		line := renderRule(r)
-		if line != "" {
+		for _, line := range renderRuleLines(r) {
 			fmt.Fprintf(b, "    %s\n", line)
 		}
 	}
+	if dispatch != "" {
+		fmt.Fprintf(b, "    %s\n", dispatch)
+	}
 
 	b.WriteString("  }\n\n")
 }
 
+// renderRuleLines: DNS-имена в dst превращаются в ссылки на множества; правило
+// с несколькими именами (или именами и CIDR-ами) разворачивается в несколько строк.
+func renderRuleLines(r model.Rule) []string {
+	var cidrs, names []string
+	for _, d := range r.DstCIDRs {
+		if name, ok := model.ParseFQDN(d); ok {
+			names = append(names, name)
+		} else {
+			cidrs = append(cidrs, d)
+		}
+	}
+	if len(names) == 0 {
+		return []string{renderRule(r)}
+	}
+	var out []string
+	if len(cidrs) > 0 {
+		x := r
+		x.DstCIDRs = cidrs
+		out = append(out, renderRule(x))
+	}
+	for _, n := range names {
+		x := r
+		x.DstCIDRs = []string{"@" + SetName(n)}
+		out = append(out, renderRule(x))
+	}
+	return out
+}
+
 // renderRule превращает Rule в строку nft
 func renderRule(r model.Rule) string {
 	var parts []string
@@ -69,11 +123,11 @@ func renderRule(r model.Rule) string {
 		}
 		parts = append(parts, fmt.Sprintf("%s dport { %s }", r.Proto, strings.Join(s, ",")))
 	}
-	for _, cidr := range r.SrcCIDRs {
-		parts = append(parts, fmt.Sprintf("ip saddr %s", cidr))
+	if len(r.SrcCIDRs) > 0 {
+		parts = append(parts, "ip saddr "+addrMatch(r.SrcCIDRs))
 	}
-	for _, cidr := range r.DstCIDRs {
-		parts = append(parts, fmt.Sprintf("ip daddr %s", cidr))
+	if len(r.DstCIDRs) > 0 {
+		parts = append(parts, "ip daddr "+addrMatch(r.DstCIDRs))
 	}
 	if len(r.ICMPTypes) > 0 && r.Proto == "icmp" {
 		var s []string
@@ -86,3 +140,12 @@ func renderRule(r model.Rule) string {
 	parts = append(parts, r.Action)
 	return strings.Join(parts, " ")
 }
+
+// addrMatch: один адрес — как есть, несколько — анонимным множеством
+// (пакет должен совпасть с любым из них, а не со всеми сразу).
+func addrMatch(cidrs []string) string {
+	if len(cidrs) == 1 {
+		return cidrs[0]
+	}
+	return "{ " + strings.Join(cidrs, ", ") + " }"
+}
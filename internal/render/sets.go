@@ -0,0 +1,82 @@
+package render
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"netfence/internal/model"
+)
+
+// FQDNNames — отсортированный список DNS-имён из dst правил.
+func FQDNNames(rules []model.Rule) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, r := range rules {
+		for _, d := range r.DstCIDRs {
+			if name, ok := model.ParseFQDN(d); ok && !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SetName — имя nft-множества для DNS-имени. Суффикс-хеш нужен, чтобы
+// a-b.example и a.b.example не схлопнулись в одно множество.
+func SetName(fqdn string) string {
+	var b strings.Builder
+	for _, c := range fqdn {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(fqdn))
+	return fmt.Sprintf("fqdn_%s_%08x", b.String(), h.Sum32())
+}
+
+// SetUpdate — nft-скрипт, атомарно заменяющий содержимое множества имени
+// без перерендера всего ruleset.
+func SetUpdate(fqdn string, addrs []string) string {
+	set := SetName(fqdn)
+	var b strings.Builder
+	fmt.Fprintf(&b, "flush set inet netfence %s\n", set)
+	if len(addrs) > 0 {
+		fmt.Fprintf(&b, "add element inet netfence %s { %s }\n", set, strings.Join(addrs, ", "))
+	}
+	return b.String()
+}
+
+// setBlock — объявление множества имени в скрипте ruleset-а.
+func setBlock(fqdn string, addrs []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  set %s {\n", SetName(fqdn))
+	b.WriteString("    type ipv4_addr\n")
+	if len(addrs) > 0 {
+		fmt.Fprintf(&b, "    elements = { %s }\n", strings.Join(addrs, ", "))
+	}
+	b.WriteString("  }\n")
+	return b.String()
+}
+
+// PatchSet — скрипт ruleset-а, в котором множество имени уже содержит addrs
+// (то же, что даёт SetUpdate поверх загруженного script). Нет такого
+// множества — script без изменений.
+func PatchSet(script, fqdn string, addrs []string) string {
+	start := strings.Index(script, fmt.Sprintf("  set %s {\n", SetName(fqdn)))
+	if start < 0 {
+		return script
+	}
+	end := strings.Index(script[start:], "\n  }\n")
+	if end < 0 {
+		return script
+	}
+	end += start + len("\n  }\n")
+	return script[:start] + setBlock(fqdn, addrs) + script[end:]
+}
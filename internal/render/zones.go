@@ -0,0 +1,65 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"netfence/internal/model"
+)
+
+// ZoneChain — имена цепочек зоны для input и forward.
+func ZoneChain(zone, hook string) string { return "zone_" + zone + "_" + hook }
+
+// renderZones пишет цепочки зон и возвращает строки диспетчеризации (vmap по
+// входному интерфейсу) для базовых цепочек input и forward.
+//
+// input: zone_<z>_input завершается input-политикой зоны.
+// forward: zone_<z>_forward выбирает вердикт по выходному интерфейсу согласно
+// матрице; пары без записи возвращаются в forward и получают её политику.
+func renderZones(b *strings.Builder, zones []model.Zone, policies []model.ZonePolicy) (string, string) {
+	ifaces := map[string][]string{}
+	for _, z := range zones {
+		ifaces[z.Name] = z.Ifaces
+	}
+	var inMap, fwdMap []string
+	for _, z := range zones {
+		if len(z.Ifaces) == 0 {
+			continue
+		}
+		chain := ZoneChain(z.Name, "input")
+		fmt.Fprintf(b, "  chain %s {\n", chain)
+		fmt.Fprintf(b, "    %s\n", z.InputPolicy)
+		b.WriteString("  }\n\n")
+		for _, ifname := range z.Ifaces {
+			inMap = append(inMap, fmt.Sprintf(`"%s" : jump %s`, ifname, chain))
+		}
+
+		var verdicts []string
+		for _, p := range policies {
+			if p.From != z.Name {
+				continue
+			}
+			for _, out := range ifaces[p.To] {
+				verdicts = append(verdicts, fmt.Sprintf(`"%s" : %s`, out, p.Action))
+			}
+		}
+		if len(verdicts) == 0 {
+			continue
+		}
+		chain = ZoneChain(z.Name, "forward")
+		fmt.Fprintf(b, "  chain %s {\n", chain)
+		fmt.Fprintf(b, "    oifname vmap { %s }\n", strings.Join(verdicts, ", "))
+		b.WriteString("  }\n\n")
+		for _, ifname := range z.Ifaces {
+			fwdMap = append(fwdMap, fmt.Sprintf(`"%s" : jump %s`, ifname, chain))
+		}
+	}
+	return vmap(inMap), vmap(fwdMap)
+}
+
+func vmap(entries []string) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	return "iifname vmap { " + strings.Join(entries, ", ") + " }"
+}
@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"netfence/internal/model"
+	"netfence/internal/snapshot"
+)
+
+// AppliedRepo — последнее применённое состояние namespace NS.
+type AppliedRepo struct {
+	DB *sql.DB
+	NS string
+}
+
+// Get возвращает ok=false, если в namespace ещё ни разу не делали apply.
+func (r AppliedRepo) Get(ctx context.Context) (model.AppliedState, bool, error) {
+	var st model.AppliedState
+	var snap string
+	err := r.DB.QueryRowContext(ctx, `SELECT hash,revision,applied_at,actor,snapshot,script FROM applied_state WHERE netns=?`, r.NS).Scan(
+		&st.Hash, &st.Revision, &st.AppliedAt, &st.Actor, &snap, &st.Script)
+	if err == sql.ErrNoRows {
+		return st, false, nil
+	}
+	if err != nil {
+		return st, false, err
+	}
+	if st.Snapshot, err = snapshot.Unmarshal([]byte(snap)); err != nil {
+		return st, false, fmt.Errorf("applied state: %w", err)
+	}
+	return st, true, nil
+}
+
+func (r AppliedRepo) Save(ctx context.Context, st model.AppliedState) error {
+	b, err := snapshot.Marshal(st.Snapshot)
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.ExecContext(ctx, `INSERT INTO applied_state(netns,hash,revision,applied_at,actor,snapshot,script) VALUES(?,?,?,CURRENT_TIMESTAMP,?,?,?)
+		ON CONFLICT(netns) DO UPDATE SET hash=excluded.hash,revision=excluded.revision,applied_at=excluded.applied_at,
+		actor=excluded.actor,snapshot=excluded.snapshot,script=excluded.script`,
+		r.NS, st.Hash, st.Revision, st.Actor, string(b), st.Script)
+	return err
+}
+
+// SetScript меняет только загруженный скрипт и его хеш (множества DNS-имён,
+// обновлённые на месте); ревизия, снимок и время apply остаются.
+func (r AppliedRepo) SetScript(ctx context.Context, script, hash string) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE applied_state SET script=?, hash=? WHERE netns=?`, script, hash, r.NS)
+	return err
+}
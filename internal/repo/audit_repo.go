@@ -3,12 +3,319 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"netfence/internal/auditchain"
+	"netfence/internal/model"
 )
 
 type AuditRepo struct{ DB *sql.DB }
 
+var ErrNoAuditEntry = errors.New("no such audit entry")
+
+// auditKey — ключ HMAC записей (config audit.hmac_key_file); nil — без HMAC.
+var auditKey []byte
+
+// SetAuditKey задаёт ключ HMAC для всех последующих записей и Verify.
+func SetAuditKey(key []byte) { auditKey = key }
+
+// realActor, realUID — настоящий пользователь ОС, который вызвал netfence;
+// пишется в каждую запись рядом с actor.
+var (
+	realActor string
+	realUID   int64
+)
+
+// SetRealActor задаёт настоящего пользователя для всех последующих записей.
+func SetRealActor(name string, uid int) { realActor, realUID = name, int64(uid) }
+
+// RealActor — настоящий пользователь, заданный SetRealActor ("" — не задан).
+func RealActor() string { return realActor }
+
+// execer — общее у *sql.DB и *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 func (r AuditRepo) Write(ctx context.Context, actor, action, object, details string) error {
-	_, err := r.DB.ExecContext(ctx, `INSERT INTO audit_log(actor,action,object,details) VALUES(?,?,?,?)`,
-		actor, action, object, details)
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = r.write(ctx, tx, actor, action, object, details); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// WriteTx пишет запись в той же транзакции, что и само изменение.
+func (r AuditRepo) WriteTx(ctx context.Context, tx *sql.Tx, actor, action, object, details string) error {
+	return r.write(ctx, tx, actor, action, object, details)
+}
+
+// write добавляет запись в конец цепочки: prev_hash — хеш последней записи.
+func (r AuditRepo) write(ctx context.Context, db execer, actor, action, object, details string) error {
+	row := auditchain.Row{TS: time.Now().UTC().Format(auditchain.TSLayout), Actor: actor, Action: action, Object: object, Details: details,
+		RealActor: realActor, RealUID: realUID}
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(hash,'') FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&row.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	var ra, ru any
+	if realActor != "" {
+		ra, ru = realActor, realUID
+	}
+	res, err := db.ExecContext(ctx, `INSERT INTO audit_log(ts,actor,action,object,details,real_actor,real_uid,prev_hash) VALUES(?,?,?,?,?,?,?,?)`,
+		row.TS, actor, action, object, details, ra, ru, row.PrevHash)
+	if err != nil {
+		return err
+	}
+	if row.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	row.Hash = auditchain.Sum(row)
+	var mac any
+	if auditKey != nil {
+		mac = auditchain.MAC(auditKey, row.Hash)
+	}
+	_, err = db.ExecContext(ctx, `UPDATE audit_log SET hash=?,hmac=? WHERE id=?`, row.Hash, mac, row.ID)
+	return err
+}
+
+func (r AuditRepo) Get(ctx context.Context, id int64) (model.AuditEntry, error) {
+	var e model.AuditEntry
+	err := r.DB.QueryRowContext(ctx, `SELECT id,ts,actor,action,object,details,COALESCE(real_actor,''),COALESCE(hash,'') FROM audit_log WHERE id=?`, id).
+		Scan(&e.ID, &e.TS, &e.Actor, &e.Action, &e.Object, &e.Details, &e.RealActor, &e.Hash)
+	if err == sql.ErrNoRows {
+		return e, ErrNoAuditEntry
+	}
+	return e, err
+}
+
+// Verify проверяет цепочку всего журнала (см. auditchain.Verify). Если
+// начало журнала удалено через Prune, цепочка должна начинаться с хеша,
+// записанного в последней записи prune_audit.
+func (r AuditRepo) Verify(ctx context.Context) (auditchain.Result, error) {
+	all, err := chainRows(ctx, r.DB, 0)
+	if err != nil {
+		return auditchain.Result{}, err
+	}
+	var start string
+	var upTo int64
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Action == PruneAction {
+			var d struct {
+				Info struct {
+					UpTo      int64  `json:"up_to_id"`
+					ChainHash string `json:"chain_hash"`
+				} `json:"info"`
+			}
+			if json.Unmarshal([]byte(all[i].Details), &d) == nil {
+				start, upTo = d.Info.ChainHash, d.Info.UpTo
+			}
+			break
+		}
+	}
+	res := auditchain.Verify(all, start, auditKey)
+	res.PrunedUpTo = upTo
+	return res, nil
+}
+
+// PruneAction — действие записи об очистке журнала.
+const PruneAction = "prune_audit"
+
+// querier — общее у *sql.DB и *sql.Tx для выборок.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// chainRows — записи с полями цепочки по возрастанию ID; upTo > 0 — только
+// с ID не больше upTo.
+func chainRows(ctx context.Context, db querier, upTo int64) ([]auditchain.Row, error) {
+	q := `SELECT id,strftime('%Y-%m-%d %H:%M:%S',ts),actor,action,object,details,COALESCE(real_actor,''),COALESCE(real_uid,0),
+		COALESCE(prev_hash,''),COALESCE(hash,''),COALESCE(hmac,'') FROM audit_log`
+	var args []any
+	if upTo > 0 {
+		q += ` WHERE id<=?`
+		args = append(args, upTo)
+	}
+	rows, err := db.QueryContext(ctx, q+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var all []auditchain.Row
+	for rows.Next() {
+		var v auditchain.Row
+		if err := rows.Scan(&v.ID, &v.TS, &v.Actor, &v.Action, &v.Object, &v.Details, &v.RealActor, &v.RealUID, &v.PrevHash, &v.Hash, &v.HMAC); err != nil {
+			return nil, err
+		}
+		all = append(all, v)
+	}
+	return all, rows.Err()
+}
+
+// PruneBound — ID последней записи, которую удаляет политика хранения:
+// записи старше before (нулевое — без ограничения) и все, кроме последних
+// keep-1 (keep <= 0 — без ограничения; одно место — под запись о самой
+// очистке). 0 — удалять нечего.
+func (r AuditRepo) PruneBound(ctx context.Context, tx *sql.Tx, before time.Time, keep int) (int64, error) {
+	var bound int64
+	if !before.IsZero() {
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(id),0) FROM audit_log WHERE ts<?`,
+			before.UTC().Format(auditchain.TSLayout)).Scan(&bound)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if keep > 0 {
+		var id int64
+		err := tx.QueryRowContext(ctx, `SELECT id FROM audit_log ORDER BY id DESC LIMIT 1 OFFSET ?`, keep-1).Scan(&id)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, err
+		}
+		bound = max(bound, id)
+	}
+	return bound, nil
+}
+
+// PruneRows — записи с ID не больше upTo, для архива перед удалением.
+func (r AuditRepo) PruneRows(ctx context.Context, tx *sql.Tx, upTo int64) ([]auditchain.Row, error) {
+	return chainRows(ctx, tx, upTo)
+}
+
+// DeleteUpTo удаляет записи с ID не больше upTo.
+func (r AuditRepo) DeleteUpTo(ctx context.Context, tx *sql.Tx, upTo int64) (int64, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM audit_log WHERE id<=?`, upTo)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Count — число записей с ID больше after и ID первой из них (0 — таких нет).
+func (r AuditRepo) Count(ctx context.Context, tx *sql.Tx, after int64) (n int, first int64, err error) {
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*),COALESCE(MIN(id),0) FROM audit_log WHERE id>?`, after).Scan(&n, &first)
+	return n, first, err
+}
+
+// AuditFilter — условия выборки; пустые поля не ограничивают.
+type AuditFilter struct {
+	Actor  string // actor или настоящий пользователь ОС
+	Action string
+	Object string // "rule:42" — точно; "rule" — все rule:*
+	Since  time.Time
+	Until  time.Time
+	Limit  int // <= 0 — все
+}
+
+// List — записи от новых к старым.
+func (r AuditRepo) List(ctx context.Context, f AuditFilter) ([]model.AuditEntry, error) {
+	var where []string
+	var args []any
+	if f.Actor != "" {
+		where, args = append(where, `(actor=? OR real_actor=?)`), append(args, f.Actor, f.Actor)
+	}
+	if f.Action != "" {
+		where, args = append(where, `action=?`), append(args, f.Action)
+	}
+	if f.Object != "" {
+		if strings.Contains(f.Object, ":") {
+			where, args = append(where, `object=?`), append(args, f.Object)
+		} else {
+			where, args = append(where, `(object=? OR substr(object,1,?)=?)`), append(args, f.Object, len(f.Object)+1, f.Object+":")
+		}
+	}
+	// ts хранится как CURRENT_TIMESTAMP: UTC, "YYYY-MM-DD HH:MM:SS"
+	if !f.Since.IsZero() {
+		where, args = append(where, `ts>=?`), append(args, f.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !f.Until.IsZero() {
+		where, args = append(where, `ts<=?`), append(args, f.Until.UTC().Format("2006-01-02 15:04:05"))
+	}
+	q := `SELECT id,ts,actor,action,object,details,COALESCE(real_actor,''),COALESCE(hash,'') FROM audit_log`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	q += ` ORDER BY id DESC`
+	if f.Limit > 0 {
+		q += fmt.Sprintf(` LIMIT %d`, f.Limit)
+	}
+	rows, err := r.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.AuditEntry
+	for rows.Next() {
+		var e model.AuditEntry
+		if err := rows.Scan(&e.ID, &e.TS, &e.Actor, &e.Action, &e.Object, &e.Details, &e.RealActor, &e.Hash); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// After — до limit записей с ID больше id, по возрастанию.
+func (r AuditRepo) After(ctx context.Context, id int64, limit int) ([]model.AuditEntry, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT id,ts,actor,action,object,details,COALESCE(real_actor,''),COALESCE(hash,'') FROM audit_log WHERE id>? ORDER BY id LIMIT ?`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.AuditEntry
+	for rows.Next() {
+		var e model.AuditEntry
+		if err := rows.Scan(&e.ID, &e.TS, &e.Actor, &e.Action, &e.Object, &e.Details, &e.RealActor, &e.Hash); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// SinkState — позиция внешнего приёмника аудита.
+type SinkState struct {
+	Sink      string
+	LastID    int64
+	LastError string
+	UpdatedAt time.Time
+}
+
+// SinkState — позиция приёмника name; ok == false — приёмник ещё не запускался.
+func (r AuditRepo) SinkState(ctx context.Context, name string) (st SinkState, ok bool, err error) {
+	var lastErr sql.NullString
+	err = r.DB.QueryRowContext(ctx, `SELECT sink,last_id,last_error,updated_at FROM audit_sink_state WHERE sink=?`, name).
+		Scan(&st.Sink, &st.LastID, &lastErr, &st.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return st, false, nil
+	}
+	st.LastError = lastErr.String
+	return st, err == nil, err
+}
+
+// SaveSinkState запоминает позицию приёмника и ошибку последней попытки
+// ("" — удачно).
+func (r AuditRepo) SaveSinkState(ctx context.Context, name string, lastID int64, lastErr string) error {
+	var e any
+	if lastErr != "" {
+		e = lastErr
+	}
+	_, err := r.DB.ExecContext(ctx, `INSERT INTO audit_sink_state(sink,last_id,last_error,updated_at) VALUES(?,?,?,CURRENT_TIMESTAMP)
+		ON CONFLICT(sink) DO UPDATE SET last_id=excluded.last_id,last_error=excluded.last_error,updated_at=excluded.updated_at`, name, lastID, e)
 	return err
 }
+
+// CountAfter — число записей с ID больше id.
+func (r AuditRepo) CountAfter(ctx context.Context, id int64) (int, error) {
+	var n int
+	err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_log WHERE id>?`, id).Scan(&n)
+	return n, err
+}
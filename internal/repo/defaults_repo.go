@@ -6,16 +6,32 @@ import (
 	"netfence/internal/model"
 )
 
-type DefaultsRepo struct{ DB *sql.DB }
+// DefaultsRepo — политики по умолчанию ruleset-а namespace NS ("" — хост).
+type DefaultsRepo struct {
+	DB *sql.DB
+	NS string
+}
 
 func (r DefaultsRepo) Get(ctx context.Context) (model.Defaults, error) {
 	var d model.Defaults
-	err := r.DB.QueryRowContext(ctx, `SELECT input_policy,forward_policy,output_policy,log_prefix FROM defaults WHERE id=1`).Scan(
+	err := r.DB.QueryRowContext(ctx, `SELECT input_policy,forward_policy,output_policy,log_prefix FROM defaults WHERE netns=?`, r.NS).Scan(
 		&d.InputPolicy, &d.ForwardPolicy, &d.OutputPolicy, &d.LogPrefix)
+	if err == sql.ErrNoRows {
+		// для нового namespace — те же значения, что и DEFAULT-ы в схеме
+		return model.BuiltinDefaults(), nil
+	}
 	return d, err
 }
 func (r DefaultsRepo) Set(ctx context.Context, d model.Defaults) error {
-	_, err := r.DB.ExecContext(ctx, `UPDATE defaults SET input_policy=?,forward_policy=?,output_policy=?,log_prefix=? WHERE id=1`,
-		d.InputPolicy, d.ForwardPolicy, d.OutputPolicy, d.LogPrefix)
+	return r.set(ctx, r.DB, d)
+}
+func (r DefaultsRepo) SetTx(ctx context.Context, tx *sql.Tx, d model.Defaults) error {
+	return r.set(ctx, tx, d)
+}
+func (r DefaultsRepo) set(ctx context.Context, db execer, d model.Defaults) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO defaults(netns,input_policy,forward_policy,output_policy,log_prefix) VALUES(?,?,?,?,?)
+		ON CONFLICT(netns) DO UPDATE SET input_policy=excluded.input_policy,forward_policy=excluded.forward_policy,
+		output_policy=excluded.output_policy,log_prefix=excluded.log_prefix`,
+		r.NS, d.InputPolicy, d.ForwardPolicy, d.OutputPolicy, d.LogPrefix)
 	return err
 }
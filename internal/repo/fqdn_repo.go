@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"netfence/internal/model"
+)
+
+// FQDNRepo — кеш резолва DNS-имён namespace-а NS ('' — хост).
+type FQDNRepo struct {
+	DB *sql.DB
+	NS string
+}
+
+func (r FQDNRepo) List(ctx context.Context) ([]model.FQDNEntry, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT name,resolved_at,expires_at,error FROM fqdn_names WHERE netns=? ORDER BY name`, r.NS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.FQDNEntry
+	for rows.Next() {
+		var e model.FQDNEntry
+		var res, exp sql.NullTime
+		if err := rows.Scan(&e.Name, &res, &exp, &e.Error); err != nil {
+			return nil, err
+		}
+		e.ResolvedAt, e.ExpiresAt = res.Time, exp.Time
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range out {
+		if out[i].Addrs, err = r.addrs(ctx, out[i].Name); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Addrs — текущие адреса всех имён из кеша (для рендера множеств).
+func (r FQDNRepo) Addrs(ctx context.Context) (map[string][]string, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT name,addr FROM fqdn_addr WHERE netns=? ORDER BY name,addr`, r.NS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string][]string{}
+	for rows.Next() {
+		var n, a string
+		if err := rows.Scan(&n, &a); err != nil {
+			return nil, err
+		}
+		out[n] = append(out[n], a)
+	}
+	return out, rows.Err()
+}
+
+// Save заменяет запись имени целиком. Пустой Addrs при ошибке резолва
+// не затирает старые адреса — см. FQDNService.
+func (r FQDNRepo) Save(ctx context.Context, e model.FQDNEntry) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	_, err = tx.ExecContext(ctx, `INSERT INTO fqdn_names(netns,name,resolved_at,expires_at,error) VALUES(?,?,?,?,?)
+		ON CONFLICT(netns,name) DO UPDATE SET resolved_at=excluded.resolved_at, expires_at=excluded.expires_at, error=excluded.error`,
+		r.NS, e.Name, nullTime(e.ResolvedAt), nullTime(e.ExpiresAt), e.Error)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM fqdn_addr WHERE netns=? AND name=?`, r.NS, e.Name); err != nil {
+		return err
+	}
+	for _, a := range e.Addrs {
+		if _, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO fqdn_addr(netns,name,addr) VALUES(?,?,?)`, r.NS, e.Name, a); err != nil {
+			return err
+		}
+	}
+	err = tx.Commit()
+	return err
+}
+
+// DeleteUnused убирает из кеша namespace-а имена, на которые больше не
+// ссылается ни одно его правило.
+func (r FQDNRepo) DeleteUnused(ctx context.Context) error {
+	if _, err := r.DB.ExecContext(ctx, `DELETE FROM fqdn_names WHERE netns=? AND name NOT IN (
+		SELECT lower(rtrim(d.cidr,'.')) FROM rule_dst_cidr d JOIN rules r ON r.id=d.rule_id WHERE r.netns=?)`, r.NS, r.NS); err != nil {
+		return err
+	}
+	_, err := r.DB.ExecContext(ctx, `DELETE FROM fqdn_addr WHERE netns=? AND name NOT IN (SELECT name FROM fqdn_names WHERE netns=?)`, r.NS, r.NS)
+	return err
+}
+
+func (r FQDNRepo) addrs(ctx context.Context, name string) ([]string, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT addr FROM fqdn_addr WHERE netns=? AND name=? ORDER BY addr`, r.NS, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
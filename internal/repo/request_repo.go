@@ -0,0 +1,119 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"netfence/internal/model"
+	"netfence/internal/snapshot"
+)
+
+// RequestRepo — change request-ы namespace NS.
+type RequestRepo struct {
+	DB *sql.DB
+	NS string
+}
+
+var ErrNoRequest = errors.New("no such change request")
+
+const requestCols = `id,author,reason,snapshot,base_revision,status,reviewer,note,created_at,reviewed_at,applied_at,real_author,real_reviewer`
+
+// List — запросы от новых к старым; status "" — все.
+func (r RequestRepo) List(ctx context.Context, status string) ([]model.ChangeRequest, error) {
+	q := `SELECT ` + requestCols + ` FROM change_requests WHERE netns=?`
+	args := []any{r.NS}
+	if status != "" {
+		q += ` AND status=?`
+		args = append(args, status)
+	}
+	rows, err := r.DB.QueryContext(ctx, q+` ORDER BY id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.ChangeRequest
+	for rows.Next() {
+		cr, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, cr)
+	}
+	return out, rows.Err()
+}
+
+func (r RequestRepo) Get(ctx context.Context, id int64) (model.ChangeRequest, error) {
+	row := r.DB.QueryRowContext(ctx, `SELECT `+requestCols+` FROM change_requests WHERE netns=? AND id=?`, r.NS, id)
+	cr, err := scanRequest(row)
+	if err == sql.ErrNoRows {
+		return cr, fmt.Errorf("request %d: %w", id, ErrNoRequest)
+	}
+	return cr, err
+}
+
+func (r RequestRepo) Create(ctx context.Context, cr model.ChangeRequest) (int64, error) {
+	b, err := snapshot.Marshal(cr.Snapshot)
+	if err != nil {
+		return 0, err
+	}
+	res, err := r.DB.ExecContext(ctx, `INSERT INTO change_requests(netns,author,reason,snapshot,base_revision,real_author) VALUES(?,?,?,?,?,?)`,
+		r.NS, cr.Author, cr.Reason, string(b), cr.BaseRevision, orActor(realActor, cr.Author))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Review переводит pending-запрос в approved/rejected.
+func (r RequestRepo) Review(ctx context.Context, id int64, status, reviewer, note string) error {
+	res, err := r.DB.ExecContext(ctx, `UPDATE change_requests SET status=?,reviewer=?,note=?,real_reviewer=?,reviewed_at=CURRENT_TIMESTAMP
+		WHERE netns=? AND id=? AND status='pending'`, status, reviewer, note, orActor(realActor, reviewer), r.NS, id)
+	return expectOne(res, err, fmt.Errorf("request %d is not pending", id))
+}
+
+// MarkAppliedTx — в транзакции самого изменения, чтобы запрос не применился дважды.
+func (r RequestRepo) MarkAppliedTx(ctx context.Context, tx *sql.Tx, id int64) error {
+	res, err := tx.ExecContext(ctx, `UPDATE change_requests SET status='applied',applied_at=CURRENT_TIMESTAMP
+		WHERE netns=? AND id=? AND status='approved'`, r.NS, id)
+	return expectOne(res, err, fmt.Errorf("request %d is not approved", id))
+}
+
+func expectOne(res sql.Result, err error, none error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return none
+	}
+	return nil
+}
+
+// orActor — настоящий пользователь, если он известен, иначе actor.
+func orActor(real, actor string) string {
+	if real == "" {
+		return actor
+	}
+	return real
+}
+
+type scanner interface{ Scan(dest ...any) error }
+
+func scanRequest(s scanner) (model.ChangeRequest, error) {
+	var cr model.ChangeRequest
+	var snap string
+	var reviewed, applied sql.NullTime
+	if err := s.Scan(&cr.ID, &cr.Author, &cr.Reason, &snap, &cr.BaseRevision, &cr.Status, &cr.Reviewer, &cr.Note,
+		&cr.CreatedAt, &reviewed, &applied, &cr.RealAuthor, &cr.RealReviewer); err != nil {
+		return cr, err
+	}
+	cr.ReviewedAt, cr.AppliedAt = reviewed.Time, applied.Time
+	var err error
+	if cr.Snapshot, err = snapshot.Unmarshal([]byte(snap)); err != nil {
+		return cr, fmt.Errorf("request %d: %w", cr.ID, err)
+	}
+	return cr, nil
+}
@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"netfence/internal/model"
+	"netfence/internal/snapshot"
+)
+
+// RevisionRepo — история ruleset-а namespace NS.
+type RevisionRepo struct {
+	DB *sql.DB
+	NS string
+}
+
+var ErrNoRevision = errors.New("no such revision")
+
+// List — ревизии от новых к старым; limit <= 0 — все. Снимки не загружаются.
+func (r RevisionRepo) List(ctx context.Context, limit int) ([]model.Revision, error) {
+	q := `SELECT rev,ts,actor,message FROM revisions WHERE netns=? ORDER BY rev DESC`
+	if limit > 0 {
+		q += fmt.Sprintf(` LIMIT %d`, limit)
+	}
+	rows, err := r.DB.QueryContext(ctx, q, r.NS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.Revision
+	for rows.Next() {
+		var v model.Revision
+		if err := rows.Scan(&v.Rev, &v.TS, &v.Actor, &v.Message); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// Get — ревизия со снимком; rev <= 0 — последняя.
+func (r RevisionRepo) Get(ctx context.Context, rev int64) (model.Revision, error) {
+	q := `SELECT rev,ts,actor,message,snapshot FROM revisions WHERE netns=? AND rev=?`
+	args := []any{r.NS, rev}
+	if rev <= 0 {
+		q = `SELECT rev,ts,actor,message,snapshot FROM revisions WHERE netns=? ORDER BY rev DESC LIMIT 1`
+		args = args[:1]
+	}
+	var v model.Revision
+	var snap string
+	err := r.DB.QueryRowContext(ctx, q, args...).Scan(&v.Rev, &v.TS, &v.Actor, &v.Message, &snap)
+	if err == sql.ErrNoRows {
+		return v, ErrNoRevision
+	}
+	if err != nil {
+		return v, err
+	}
+	if v.Snapshot, err = snapshot.Unmarshal([]byte(snap)); err != nil {
+		return v, fmt.Errorf("revision %d: %w", v.Rev, err)
+	}
+	return v, nil
+}
+
+// Add сохраняет снимок следующим номером и возвращает его.
+func (r RevisionRepo) Add(ctx context.Context, actor, message string, snap model.Snapshot) (int64, error) {
+	b, err := snapshot.Marshal(snap)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	var rev int64
+	if err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(rev),0)+1 FROM revisions WHERE netns=?`, r.NS).Scan(&rev); err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO revisions(netns,rev,actor,message,snapshot) VALUES(?,?,?,?,?)`,
+		r.NS, rev, actor, message, string(b)); err != nil {
+		return 0, err
+	}
+	err = tx.Commit()
+	return rev, err
+}
@@ -3,29 +3,38 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"netfence/internal/model"
 )
 
-type RuleRepo struct{ DB *sql.DB }
+var ErrNoRule = errors.New("no such rule")
+
+// RuleRepo — правила ruleset-а namespace NS ("" — хост).
+type RuleRepo struct {
+	DB *sql.DB
+	NS string
+}
 
 func (r RuleRepo) List(ctx context.Context, onlyEnabled bool) ([]model.Rule, error) {
-	q := `SELECT id,chain,proto,action,in_if,out_if,comment,enabled FROM rules`
-	if onlyEnabled { q += ` WHERE enabled=1` }
-	q += ` ORDER BY id`
-	rows, err := r.DB.QueryContext(ctx, q)
+	q := `SELECT id,chain,proto,action,in_if,out_if,comment,enabled,profile,origin,updated_at FROM rules WHERE netns=?`
+	if onlyEnabled { q += ` AND enabled=1` }
+	q += ` ORDER BY position, id`
+	rows, err := r.DB.QueryContext(ctx, q, r.NS)
 	if err != nil { return nil, err }
 	defer rows.Close()
 	var out []model.Rule
 	for rows.Next() {
 		var m model.Rule
-		var inif, outif, comment sql.NullString
+		var inif, outif, comment, profile, origin sql.NullString
 		var enabled int
-		if err := rows.Scan(&m.ID, &m.Chain, &m.Proto, &m.Action, &inif, &outif, &comment, &enabled); err != nil {
+		if err := rows.Scan(&m.ID, &m.Chain, &m.Proto, &m.Action, &inif, &outif, &comment, &enabled, &profile, &origin, &m.UpdatedAt); err != nil {
 			return nil, err
 		}
 		if inif.Valid { m.InIf = &inif.String }
 		if outif.Valid { m.OutIf = &outif.String }
 		if comment.Valid { m.Comment = &comment.String }
+		if profile.Valid { m.Profile = &profile.String }
+		if origin.Valid { m.Origin = &origin.String }
 		m.Enabled = enabled == 1
 		m.Ports, _ = selectInts(r.DB, `SELECT port FROM rule_port WHERE rule_id=?`, m.ID)
 		m.SrcCIDRs, _ = selectStrs(r.DB, `SELECT cidr FROM rule_src_cidr WHERE rule_id=?`, m.ID)
@@ -36,23 +45,71 @@ func (r RuleRepo) List(ctx context.Context, onlyEnabled bool) ([]model.Rule, err
 	return out, nil
 }
 
+// Get — правило id; nil, если его нет.
+func (r RuleRepo) Get(ctx context.Context, id int64) (*model.Rule, error) {
+	rules, err := r.List(ctx, false)
+	if err != nil { return nil, err }
+	for i := range rules {
+		if rules[i].ID == id { return &rules[i], nil }
+	}
+	return nil, nil
+}
+
 func (r RuleRepo) Create(ctx context.Context, m *model.Rule) (int64, error) {
 	tx, err := r.DB.BeginTx(ctx, nil); if err != nil { return 0, err }
 	defer func(){ if err!=nil { _=tx.Rollback() } }()
-	res, err := tx.ExecContext(ctx, `INSERT INTO rules(chain,proto,action,in_if,out_if,comment,enabled) VALUES(?,?,?,?,?,?,?)`,
-		m.Chain, m.Proto, m.Action, nullable(m.InIf), nullable(m.OutIf), nullable(m.Comment), boolToInt(m.Enabled))
+	id, err := r.CreateTx(ctx, tx, m); if err != nil { return 0, err }
+	err = tx.Commit(); if err != nil { return 0, err }
+	return id, nil
+}
+
+// CreateTx — Create внутри внешней транзакции (sync). Новое правило встаёт в
+// конец набора.
+func (r RuleRepo) CreateTx(ctx context.Context, tx *sql.Tx, m *model.Rule) (int64, error) {
+	res, err := tx.ExecContext(ctx, `INSERT INTO rules(chain,proto,action,in_if,out_if,comment,enabled,netns,profile,origin,position)
+		VALUES(?,?,?,?,?,?,?,?,?,?,(SELECT COALESCE(MAX(position),0)+1 FROM rules WHERE netns=?))`,
+		m.Chain, m.Proto, m.Action, nullable(m.InIf), nullable(m.OutIf), nullable(m.Comment), boolToInt(m.Enabled), r.NS, nullable(m.Profile), nullable(m.Origin), r.NS)
 	if err != nil { return 0, err }
 	id, err := res.LastInsertId(); if err != nil { return 0, err }
 	if err = insertInts(tx, `INSERT INTO rule_port(rule_id,port) VALUES(?,?)`, id, m.Ports); err != nil { return 0, err }
 	if err = insertStrs(tx, `INSERT INTO rule_src_cidr(rule_id,cidr) VALUES(?,?)`, id, m.SrcCIDRs); err != nil { return 0, err }
 	if err = insertStrs(tx, `INSERT INTO rule_dst_cidr(rule_id,cidr) VALUES(?,?)`, id, m.DstCIDRs); err != nil { return 0, err }
 	if err = insertInts(tx, `INSERT INTO rule_icmp_type(rule_id,itype) VALUES(?,?)`, id, m.ICMPTypes); err != nil { return 0, err }
-	err = tx.Commit(); if err != nil { return 0, err }
 	return id, nil
 }
 
+// UpdateTx перезаписывает правило m.ID целиком (ID сохраняется).
+func (r RuleRepo) UpdateTx(ctx context.Context, tx *sql.Tx, m model.Rule) error {
+	_, err := tx.ExecContext(ctx, `UPDATE rules SET chain=?,proto=?,action=?,in_if=?,out_if=?,comment=?,enabled=?,profile=?,origin=? WHERE id=? AND netns=?`,
+		m.Chain, m.Proto, m.Action, nullable(m.InIf), nullable(m.OutIf), nullable(m.Comment), boolToInt(m.Enabled), nullable(m.Profile), nullable(m.Origin), m.ID, r.NS)
+	if err != nil { return err }
+	for _, t := range []string{"rule_port", "rule_src_cidr", "rule_dst_cidr", "rule_icmp_type"} {
+		if _, err = tx.ExecContext(ctx, `DELETE FROM `+t+` WHERE rule_id=?`, m.ID); err != nil { return err }
+	}
+	if err = insertInts(tx, `INSERT INTO rule_port(rule_id,port) VALUES(?,?)`, m.ID, m.Ports); err != nil { return err }
+	if err = insertStrs(tx, `INSERT INTO rule_src_cidr(rule_id,cidr) VALUES(?,?)`, m.ID, m.SrcCIDRs); err != nil { return err }
+	if err = insertStrs(tx, `INSERT INTO rule_dst_cidr(rule_id,cidr) VALUES(?,?)`, m.ID, m.DstCIDRs); err != nil { return err }
+	return insertInts(tx, `INSERT INTO rule_icmp_type(rule_id,itype) VALUES(?,?)`, m.ID, m.ICMPTypes)
+}
+
+// ReorderTx ставит правила ids в этом порядке (position 1..n); строки, чья
+// позиция не меняется, не трогает.
+func (r RuleRepo) ReorderTx(ctx context.Context, tx *sql.Tx, ids []int64) error {
+	st, err := tx.PrepareContext(ctx, `UPDATE rules SET position=? WHERE id=? AND netns=? AND position<>?`)
+	if err != nil { return err }
+	defer st.Close()
+	for i, id := range ids {
+		if _, err := st.ExecContext(ctx, i+1, id, r.NS, i+1); err != nil { return err }
+	}
+	return nil
+}
+
 func (r RuleRepo) Delete(ctx context.Context, id int64) error {
-	_, err := r.DB.ExecContext(ctx, `DELETE FROM rules WHERE id=?`, id)
+	_, err := r.DB.ExecContext(ctx, `DELETE FROM rules WHERE id=? AND netns=?`, id, r.NS)
+	return err
+}
+func (r RuleRepo) DeleteTx(ctx context.Context, tx *sql.Tx, id int64) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM rules WHERE id=? AND netns=?`, id, r.NS)
 	return err
 }
 
@@ -3,14 +3,72 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+
+	"netfence/internal/app"
+	"netfence/internal/model"
 )
 
 type UserRepo struct{ DB *sql.DB }
 
+var ErrNoUser = errors.New("no such user")
+
 func (r UserRepo) RoleOf(ctx context.Context, name string) (string, error) {
 	var role string
 	err := r.DB.QueryRowContext(ctx, `SELECT role FROM users WHERE name=?`, name).Scan(&role)
-	if err == sql.ErrNoRows { return "", fmt.Errorf("user %q not found", name) }
+	if err == sql.ErrNoRows { return "", fmt.Errorf("%w %q", app.ErrUnknownUser, name) }
 	return role, err
 }
+
+func (r UserRepo) List(ctx context.Context) ([]model.User, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT name,role FROM users ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.User
+	for rows.Next() {
+		var u model.User
+		if err := rows.Scan(&u.Name, &u.Role); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// GetTx — пользователь name; nil, если его нет.
+func (r UserRepo) GetTx(ctx context.Context, tx *sql.Tx, name string) (*model.User, error) {
+	u := model.User{Name: name}
+	err := tx.QueryRowContext(ctx, `SELECT role FROM users WHERE name=?`, name).Scan(&u.Role)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r UserRepo) AddTx(ctx context.Context, tx *sql.Tx, u model.User) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO users(name,role) VALUES(?,?)`, u.Name, u.Role)
+	return err
+}
+
+func (r UserRepo) SetRoleTx(ctx context.Context, tx *sql.Tx, name, role string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE users SET role=? WHERE name=?`, role, name)
+	return err
+}
+
+func (r UserRepo) DeleteTx(ctx context.Context, tx *sql.Tx, name string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM users WHERE name=?`, name)
+	return err
+}
+
+// AdminsTx — число пользователей с ролью admin.
+func (r UserRepo) AdminsTx(ctx context.Context, tx *sql.Tx) (int, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE role=?`, model.RoleAdmin).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"netfence/internal/model"
+)
+
+// ZoneRepo — зоны и матрица forward-политик namespace NS.
+type ZoneRepo struct {
+	DB *sql.DB
+	NS string
+}
+
+func (r ZoneRepo) List(ctx context.Context) ([]model.Zone, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT name,input_policy FROM zones WHERE netns=? ORDER BY name`, r.NS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.Zone
+	for rows.Next() {
+		var z model.Zone
+		if err := rows.Scan(&z.Name, &z.InputPolicy); err != nil {
+			return nil, err
+		}
+		out = append(out, z)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range out {
+		rows, err := r.DB.QueryContext(ctx, `SELECT iface FROM zone_iface WHERE netns=? AND zone=? ORDER BY iface`, r.NS, out[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var s string
+			if err := rows.Scan(&s); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			out[i].Ifaces = append(out[i].Ifaces, s)
+		}
+		rows.Close()
+	}
+	return out, nil
+}
+
+// Set — зоны и forward-матрица целиком (для снапшота); зон нет — пустой набор.
+func (r ZoneRepo) Set(ctx context.Context) (model.ZoneSet, error) {
+	zs, err := r.List(ctx)
+	if err != nil {
+		return model.ZoneSet{}, err
+	}
+	ps, err := r.Policies(ctx)
+	return model.ZoneSet{List: zs, Forward: ps}, err
+}
+
+// ReplaceTx заменяет зоны, их интерфейсы и forward-матрицу на zs (sync,
+// rollback, import).
+func (r ZoneRepo) ReplaceTx(ctx context.Context, tx *sql.Tx, zs model.ZoneSet) error {
+	for _, q := range []string{`DELETE FROM zone_iface WHERE netns=?`, `DELETE FROM zone_forward WHERE netns=?`, `DELETE FROM zones WHERE netns=?`} {
+		if _, err := tx.ExecContext(ctx, q, r.NS); err != nil {
+			return err
+		}
+	}
+	for _, z := range zs.List {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO zones(netns,name,input_policy) VALUES(?,?,?)`, r.NS, z.Name, z.InputPolicy); err != nil {
+			return err
+		}
+		for _, i := range z.Ifaces {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO zone_iface(netns,zone,iface) VALUES(?,?,?)`, r.NS, z.Name, i); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range zs.Forward {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO zone_forward(netns,from_zone,to_zone,action) VALUES(?,?,?,?)`, r.NS, p.From, p.To, p.Action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r ZoneRepo) Get(ctx context.Context, name string) (model.Zone, error) {
+	zs, err := r.List(ctx)
+	if err != nil {
+		return model.Zone{}, err
+	}
+	for _, z := range zs {
+		if z.Name == name {
+			return z, nil
+		}
+	}
+	return model.Zone{}, fmt.Errorf("zone %q not found", name)
+}
+
+// Save создаёт зону или меняет её input-политику.
+func (r ZoneRepo) Save(ctx context.Context, name, inputPolicy string) error {
+	_, err := r.DB.ExecContext(ctx, `INSERT INTO zones(netns,name,input_policy) VALUES(?,?,?)
+		ON CONFLICT(netns,name) DO UPDATE SET input_policy=excluded.input_policy`, r.NS, name, inputPolicy)
+	return err
+}
+
+// Delete удаляет зону вместе с её интерфейсами и forward-политиками.
+func (r ZoneRepo) Delete(ctx context.Context, name string) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, q := range []string{
+		`DELETE FROM zone_iface WHERE netns=? AND zone=?`,
+		`DELETE FROM zone_forward WHERE netns=?1 AND (from_zone=?2 OR to_zone=?2)`,
+		`DELETE FROM zones WHERE netns=? AND name=?`,
+	} {
+		if _, err := tx.ExecContext(ctx, q, r.NS, name); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AddIface переносит интерфейс в зону (из прежней, если был).
+func (r ZoneRepo) AddIface(ctx context.Context, zone, iface string) error {
+	_, err := r.DB.ExecContext(ctx, `INSERT INTO zone_iface(netns,zone,iface) VALUES(?,?,?)
+		ON CONFLICT(netns,iface) DO UPDATE SET zone=excluded.zone`, r.NS, zone, iface)
+	return err
+}
+
+func (r ZoneRepo) DelIface(ctx context.Context, zone, iface string) error {
+	_, err := r.DB.ExecContext(ctx, `DELETE FROM zone_iface WHERE netns=? AND zone=? AND iface=?`, r.NS, zone, iface)
+	return err
+}
+
+func (r ZoneRepo) Policies(ctx context.Context) ([]model.ZonePolicy, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT from_zone,to_zone,action FROM zone_forward WHERE netns=? ORDER BY from_zone,to_zone`, r.NS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.ZonePolicy
+	for rows.Next() {
+		var p model.ZonePolicy
+		if err := rows.Scan(&p.From, &p.To, &p.Action); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// SetPolicy задаёт forward-политику from→to; пустой action удаляет ячейку
+// матрицы (трафик уходит в политику цепочки forward).
+func (r ZoneRepo) SetPolicy(ctx context.Context, from, to, action string) error {
+	if action == "" {
+		_, err := r.DB.ExecContext(ctx, `DELETE FROM zone_forward WHERE netns=? AND from_zone=? AND to_zone=?`, r.NS, from, to)
+		return err
+	}
+	_, err := r.DB.ExecContext(ctx, `INSERT INTO zone_forward(netns,from_zone,to_zone,action) VALUES(?,?,?,?)
+		ON CONFLICT(netns,from_zone,to_zone) DO UPDATE SET action=excluded.action`, r.NS, from, to, action)
+	return err
+}
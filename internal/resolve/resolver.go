@@ -0,0 +1,217 @@
+package resolve
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Answer — IPv4-адреса имени и TTL, с которым их можно кешировать.
+type Answer struct {
+	IPs []net.IP
+	TTL time.Duration
+}
+
+// Resolver резолвит имя в A-записи. Реализация подменяемая: в тестах и
+// изолированных окружениях можно указать DNSResolver{Server: "127.0.0.1:5353"}
+// со stub-сервером или передать собственную реализацию.
+type Resolver interface {
+	Resolve(ctx context.Context, name string) (Answer, error)
+}
+
+var ErrNoRecords = errors.New("no A records")
+
+// DNSResolver делает прямой A-запрос к DNS-серверу, чтобы получить TTL
+// (стандартный net.Resolver его не отдаёт).
+type DNSResolver struct {
+	Server  string // host:port; пусто — первый nameserver из /etc/resolv.conf
+	Timeout time.Duration
+}
+
+func (r DNSResolver) Resolve(ctx context.Context, name string) (Answer, error) {
+	server := r.Server
+	if server == "" {
+		server = systemServer()
+	}
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	q, id, err := buildQuery(name)
+	if err != nil {
+		return Answer{}, err
+	}
+	resp, err := exchange(ctx, "udp", server, q)
+	if err != nil {
+		return Answer{}, err
+	}
+	if len(resp) > 2 && resp[2]&0x02 != 0 { // TC: ответ обрезан — повторяем по TCP
+		if resp, err = exchange(ctx, "tcp", server, q); err != nil {
+			return Answer{}, err
+		}
+	}
+	return parseAnswer(resp, id, name)
+}
+
+func systemServer() string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err == nil {
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			fs := strings.Fields(sc.Text())
+			if len(fs) >= 2 && fs[0] == "nameserver" {
+				return net.JoinHostPort(fs[1], "53")
+			}
+		}
+	}
+	return "127.0.0.1:53"
+}
+
+func exchange(ctx context.Context, network, server string, q []byte) ([]byte, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		_ = c.SetDeadline(dl)
+	}
+	if network == "tcp" {
+		msg := make([]byte, 2+len(q))
+		binary.BigEndian.PutUint16(msg, uint16(len(q)))
+		copy(msg[2:], q)
+		if _, err := c.Write(msg); err != nil {
+			return nil, err
+		}
+		var l [2]byte
+		if _, err := io.ReadFull(c, l[:]); err != nil {
+			return nil, err
+		}
+		resp := make([]byte, binary.BigEndian.Uint16(l[:]))
+		_, err := io.ReadFull(c, resp)
+		return resp, err
+	}
+	if _, err := c.Write(q); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4096)
+	n, err := c.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func buildQuery(name string) ([]byte, uint16, error) {
+	var idb [2]byte
+	if _, err := rand.Read(idb[:]); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(idb[:])
+	q := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(q[0:], id)
+	binary.BigEndian.PutUint16(q[2:], 0x0100) // RD
+	binary.BigEndian.PutUint16(q[4:], 1)      // QDCOUNT
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return nil, 0, fmt.Errorf("bad name %q", name)
+		}
+		q = append(q, byte(len(label)))
+		q = append(q, label...)
+	}
+	q = append(q, 0, 0, 1, 0, 1) // QTYPE=A, QCLASS=IN
+	return q, id, nil
+}
+
+var errMalformed = errors.New("malformed DNS response")
+
+func parseAnswer(msg []byte, id uint16, name string) (Answer, error) {
+	if len(msg) < 12 {
+		return Answer{}, errMalformed
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return Answer{}, errors.New("DNS response id mismatch")
+	}
+	switch rcode := msg[3] & 0x0f; rcode {
+	case 0:
+	case 3:
+		return Answer{}, fmt.Errorf("%s: no such host", name)
+	default:
+		return Answer{}, fmt.Errorf("%s: DNS rcode %d", name, rcode)
+	}
+	qd := int(binary.BigEndian.Uint16(msg[4:]))
+	an := int(binary.BigEndian.Uint16(msg[6:]))
+	off := 12
+	var err error
+	for i := 0; i < qd; i++ {
+		if off, err = skipName(msg, off); err != nil {
+			return Answer{}, err
+		}
+		off += 4
+	}
+	var out Answer
+	var minTTL uint32
+	seen := false
+	for i := 0; i < an; i++ {
+		if off, err = skipName(msg, off); err != nil {
+			return Answer{}, err
+		}
+		if off+10 > len(msg) {
+			return Answer{}, errMalformed
+		}
+		typ := binary.BigEndian.Uint16(msg[off:])
+		ttl := binary.BigEndian.Uint32(msg[off+4:])
+		rdlen := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+rdlen > len(msg) {
+			return Answer{}, errMalformed
+		}
+		switch {
+		case typ == 1 && rdlen == 4:
+			out.IPs = append(out.IPs, net.IPv4(msg[off], msg[off+1], msg[off+2], msg[off+3]).To4())
+		case typ == 5: // CNAME: адреса цели живут не дольше самой ссылки
+		default:
+			off += rdlen
+			continue
+		}
+		if !seen || ttl < minTTL {
+			minTTL, seen = ttl, true
+		}
+		off += rdlen
+	}
+	if len(out.IPs) == 0 {
+		return Answer{}, fmt.Errorf("%s: %w", name, ErrNoRecords)
+	}
+	out.TTL = time.Duration(minTTL) * time.Second
+	return out, nil
+}
+
+func skipName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, errMalformed
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1, nil
+		case l&0xc0 == 0xc0: // указатель сжатия
+			return off + 2, nil
+		default:
+			off += 1 + l
+		}
+	}
+}
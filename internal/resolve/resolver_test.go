@@ -0,0 +1,140 @@
+package resolve_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"netfence/internal/resolve"
+	"netfence/internal/resolve/resolvetest"
+)
+
+func resolveWith(t *testing.T, h resolvetest.Handler, name string) (resolve.Answer, *resolvetest.Server, error) {
+	t.Helper()
+	srv := resolvetest.NewServer(t, h)
+	r := resolve.DNSResolver{Server: srv.Addr, Timeout: 2 * time.Second}
+	ans, err := r.Resolve(context.Background(), name)
+	return ans, srv, err
+}
+
+func ips(a resolve.Answer) string {
+	var out []string
+	for _, ip := range a.IPs {
+		out = append(out, ip.String())
+	}
+	return strings.Join(out, ",")
+}
+
+func TestResolveCompressedAnswer(t *testing.T) {
+	// имена ответов сжаты указателем на вопрос; TTL — наименьший из записей
+	ans, _, err := resolveWith(t, func(name, proto string) resolvetest.Reply {
+		return resolvetest.Reply{Answers: []resolvetest.RR{
+			resolvetest.A(name, "192.0.2.1", 300),
+			resolvetest.A(name, "192.0.2.2", 120),
+		}}
+	}, "api.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ips(ans); got != "192.0.2.1,192.0.2.2" {
+		t.Errorf("ips = %s", got)
+	}
+	if ans.TTL != 120*time.Second {
+		t.Errorf("ttl = %s, want 2m0s", ans.TTL)
+	}
+}
+
+func TestResolveTruncatedRetriesTCP(t *testing.T) {
+	ans, srv, err := resolveWith(t, func(name, proto string) resolvetest.Reply {
+		return resolvetest.Reply{Truncated: true, Answers: []resolvetest.RR{resolvetest.A(name, "192.0.2.7", 60)}}
+	}, "big.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ips(ans); got != "192.0.2.7" {
+		t.Errorf("ips = %s", got)
+	}
+	if n := srv.Queries("big.example.com"); n != 2 {
+		t.Errorf("queries = %d, want 2 (udp, then tcp)", n)
+	}
+}
+
+func TestResolveCNAMEChain(t *testing.T) {
+	// www → edge → node; цель второго CNAME сжата указателем в rdata первого
+	ans, _, err := resolveWith(t, func(name, proto string) resolvetest.Reply {
+		return resolvetest.Reply{Answers: []resolvetest.RR{
+			resolvetest.CNAME(name, "edge.cdn.example.net", 600),
+			resolvetest.CNAME("edge.cdn.example.net", "node1.cdn.example.net", 45),
+			resolvetest.A("node1.cdn.example.net", "198.51.100.10", 300),
+			resolvetest.A("node1.cdn.example.net", "198.51.100.11", 300),
+		}}
+	}, "www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ips(ans); got != "198.51.100.10,198.51.100.11" {
+		t.Errorf("ips = %s", got)
+	}
+	if ans.TTL != 45*time.Second {
+		t.Errorf("ttl = %s, want the shortest link of the chain (45s)", ans.TTL)
+	}
+}
+
+func TestResolveCNAMEWithoutAddresses(t *testing.T) {
+	_, _, err := resolveWith(t, func(name, proto string) resolvetest.Reply {
+		return resolvetest.Reply{Answers: []resolvetest.RR{resolvetest.CNAME(name, "gone.example.net", 60)}}
+	}, "alias.example.com")
+	if !errors.Is(err, resolve.ErrNoRecords) {
+		t.Fatalf("err = %v, want ErrNoRecords", err)
+	}
+}
+
+func TestResolveNXDOMAIN(t *testing.T) {
+	_, _, err := resolveWith(t, func(name, proto string) resolvetest.Reply {
+		return resolvetest.Reply{Rcode: 3}
+	}, "missing.example.com")
+	if err == nil || !strings.Contains(err.Error(), "no such host") {
+		t.Fatalf("err = %v, want no such host", err)
+	}
+}
+
+func TestResolveServerFailure(t *testing.T) {
+	_, _, err := resolveWith(t, func(name, proto string) resolvetest.Reply {
+		return resolvetest.Reply{Rcode: 2}
+	}, "broken.example.com")
+	if err == nil || !strings.Contains(err.Error(), "rcode 2") {
+		t.Fatalf("err = %v, want rcode 2", err)
+	}
+}
+
+func TestResolveMalformed(t *testing.T) {
+	for name, raw := range map[string][]byte{
+		"short header": {0, 0, 0x81},
+		// заголовок: 1 ответ, но после вопроса запись обрывается
+		"cut record": {0, 0, 0x81, 0x80, 0, 1, 0, 1, 0, 0, 0, 0,
+			1, 'x', 3, 'c', 'o', 'm', 0, 0, 1, 0, 1,
+			0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 192, 0},
+		// длина метки указывает за конец сообщения
+		"cut name": {0, 0, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0, 9, 'x'},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := resolveWith(t, func(string, string) resolvetest.Reply {
+				return resolvetest.Reply{Raw: raw}
+			}, "x.com")
+			if err == nil {
+				t.Fatal("no error for a malformed response")
+			}
+		})
+	}
+}
+
+func TestResolveIDMismatch(t *testing.T) {
+	_, _, err := resolveWith(t, func(name, proto string) resolvetest.Reply {
+		return resolvetest.Reply{ForeignID: true, Answers: []resolvetest.RR{resolvetest.A(name, "192.0.2.1", 60)}}
+	}, "spoof.example.com")
+	if err == nil || !strings.Contains(err.Error(), "id mismatch") {
+		t.Fatalf("err = %v, want id mismatch", err)
+	}
+}
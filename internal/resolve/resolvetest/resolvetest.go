@@ -0,0 +1,228 @@
+// Package resolvetest — DNS-stub на 127.0.0.1 для тестов resolve.DNSResolver
+// и всего, что резолвит имена (по образцу net/http/httptest).
+package resolvetest
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Типы записей.
+const (
+	TypeA     = 1
+	TypeCNAME = 5
+)
+
+// RR — запись ответа. Для A Data — 4 байта адреса, для CNAME — Target.
+type RR struct {
+	Name   string
+	Type   uint16
+	TTL    uint32
+	Data   []byte
+	Target string
+}
+
+// A — A-запись name → ip.
+func A(name, ip string, ttl uint32) RR {
+	return RR{Name: name, Type: TypeA, TTL: ttl, Data: net.ParseIP(ip).To4()}
+}
+
+// CNAME — запись name → target.
+func CNAME(name, target string, ttl uint32) RR {
+	return RR{Name: name, Type: TypeCNAME, TTL: ttl, Target: target}
+}
+
+// Reply — ответ на запрос. Truncated: по UDP уходит только заголовок с
+// флагом TC, полный ответ — по TCP.
+type Reply struct {
+	Rcode     int
+	Truncated bool
+	Answers   []RR
+	Raw       []byte // если задан — отправляется как есть (битые ответы)
+	ForeignID bool   // ответ с чужим ID (подделанный ответ)
+}
+
+// Handler отвечает на запрос имени name, пришедший по proto (udp|tcp).
+type Handler func(name, proto string) Reply
+
+// Server — stub-сервер: UDP и TCP на одном порту.
+type Server struct {
+	Addr string // host:port для resolve.DNSResolver.Server
+
+	udp *net.UDPConn
+	tcp *net.TCPListener
+	h   Handler
+
+	mu      sync.Mutex
+	queries map[string]int
+}
+
+// NewServer запускает stub; он останавливается в t.Cleanup.
+func NewServer(t testing.TB, h Handler) *Server {
+	t.Helper()
+	s := &Server{h: h, queries: map[string]int{}}
+	// UDP и TCP на одном порту: свободный UDP-порт может быть занят по TCP
+	for i := 0; ; i++ {
+		u, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: u.LocalAddr().(*net.UDPAddr).Port})
+		if err == nil {
+			s.udp, s.tcp, s.Addr = u, l, u.LocalAddr().String()
+			break
+		}
+		_ = u.Close()
+		if i == 10 {
+			t.Fatal(err)
+		}
+	}
+	go s.serveUDP()
+	go s.serveTCP()
+	t.Cleanup(func() {
+		_ = s.udp.Close()
+		_ = s.tcp.Close()
+	})
+	return s
+}
+
+// Queries — сколько запросов имени name получил сервер (UDP и TCP).
+func (s *Server) Queries(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries[strings.ToLower(name)]
+}
+
+func (s *Server) serveUDP() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.udp.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if resp := s.answer(buf[:n], "udp"); resp != nil {
+			_, _ = s.udp.WriteToUDP(resp, addr)
+		}
+	}
+}
+
+func (s *Server) serveTCP() {
+	for {
+		c, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			var l [2]byte
+			if _, err := io.ReadFull(c, l[:]); err != nil {
+				return
+			}
+			q := make([]byte, binary.BigEndian.Uint16(l[:]))
+			if _, err := io.ReadFull(c, q); err != nil {
+				return
+			}
+			resp := s.answer(q, "tcp")
+			if resp == nil {
+				return
+			}
+			binary.BigEndian.PutUint16(l[:], uint16(len(resp)))
+			_, _ = c.Write(append(l[:], resp...))
+		}()
+	}
+}
+
+// answer разбирает запрос (одно имя) и собирает ответ со сжатием имён.
+func (s *Server) answer(q []byte, proto string) []byte {
+	if len(q) < 12 {
+		return nil
+	}
+	var labels []string
+	off := 12
+	for off < len(q) && q[off] != 0 {
+		l := int(q[off])
+		if off+1+l > len(q) {
+			return nil
+		}
+		labels = append(labels, string(q[off+1:off+1+l]))
+		off += 1 + l
+	}
+	if off+5 > len(q) {
+		return nil
+	}
+	name := strings.Join(labels, ".")
+	s.mu.Lock()
+	s.queries[strings.ToLower(name)]++
+	s.mu.Unlock()
+
+	r := s.h(name, proto)
+	if r.Raw != nil {
+		out := append([]byte(nil), r.Raw...)
+		if len(out) >= 2 {
+			copy(out, q[:2])
+		}
+		return out
+	}
+	m := msg{names: map[string]int{}}
+	m.b = append(m.b, q[0], q[1])
+	if r.ForeignID {
+		m.b[0] ^= 0xff
+	}
+	flags := uint16(0x8180) | uint16(r.Rcode&0x0f) // QR, RD, RA
+	an := r.Answers
+	if r.Truncated && proto == "udp" {
+		flags |= 0x0200
+		an = nil
+	}
+	m.u16(flags)
+	m.u16(1)
+	m.u16(uint16(len(an)))
+	m.u16(0)
+	m.u16(0)
+	m.name(name)
+	m.b = append(m.b, q[off+1:off+5]...)
+	for _, rr := range an {
+		m.name(rr.Name)
+		m.u16(rr.Type)
+		m.u16(1)
+		m.b = binary.BigEndian.AppendUint32(m.b, rr.TTL)
+		at := len(m.b)
+		m.u16(0)
+		if rr.Type == TypeCNAME {
+			m.name(rr.Target)
+		} else {
+			m.b = append(m.b, rr.Data...)
+		}
+		binary.BigEndian.PutUint16(m.b[at:], uint16(len(m.b)-at-2))
+	}
+	return m.b
+}
+
+// msg — DNS-сообщение с таблицей сжатия имён (RFC 1035, 4.1.4).
+type msg struct {
+	b     []byte
+	names map[string]int
+}
+
+func (m *msg) u16(v uint16) { m.b = binary.BigEndian.AppendUint16(m.b, v) }
+
+func (m *msg) name(n string) {
+	labels := strings.Split(strings.TrimSuffix(n, "."), ".")
+	for i := range labels {
+		suffix := strings.ToLower(strings.Join(labels[i:], "."))
+		if at, ok := m.names[suffix]; ok {
+			m.u16(0xc000 | uint16(at))
+			return
+		}
+		if len(m.b) < 0x3fff {
+			m.names[suffix] = len(m.b)
+		}
+		m.b = append(m.b, byte(len(labels[i])))
+		m.b = append(m.b, labels[i]...)
+	}
+	m.b = append(m.b, 0)
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"netfence/internal/model"
+	"netfence/internal/plan"
+	"netfence/internal/render"
+	"netfence/internal/repo"
+	"netfence/internal/util"
+)
+
+// ApplyService собирает ruleset из БД и загружает его в nftables.
+type ApplyService struct {
+	Rules    repo.RuleRepo
+	Defaults repo.DefaultsRepo
+	Zones    repo.ZoneRepo
+	FQDN     FQDNService
+	Audit    AuditService
+	Runner   util.Runner
+	// необязательно: учёт применённого состояния (status, diff --pending)
+	Applied   repo.AppliedRepo
+	Revisions repo.RevisionRepo
+	Export    HistoryExporter // необязательно: коммит снимка на каждый apply
+}
+
+// Status — применённое состояние против текущего содержимого БД.
+type Status struct {
+	Applied   model.AppliedState
+	Ever      bool      // apply уже выполнялся
+	Revision  int64     // последняя ревизия в БД
+	Pending   plan.Plan // изменения БД после apply (до первого apply — от встроенной политики)
+	Script    string    // nft-скрипт, который загрузит apply
+	Unchanged bool      // Script совпадает с применённым
+}
+
+// PendingCount — число неприменённых изменений правил и defaults.
+func (st Status) PendingCount() int {
+	add, change, del := st.Pending.Counts()
+	return add + change + del
+}
+
+// Ruleset собирает из БД всё, что уходит в рендер: включённые правила (ссылки
+// iface:<if>:... раскрыты в текущие адреса), адреса DNS-имён из кеша и зоны.
+func (s ApplyService) Ruleset(ctx context.Context) (render.Ruleset, error) {
+	def, err := s.Defaults.Get(ctx)
+	if err != nil {
+		return render.Ruleset{}, err
+	}
+	rules, err := s.Rules.List(ctx, true)
+	if err != nil {
+		return render.Ruleset{}, err
+	}
+	rules, err = render.ExpandIfaceRefs(rules, util.ResolveIfaceRef)
+	if err != nil {
+		return render.Ruleset{}, err
+	}
+	fqdns, err := s.FQDN.Repo.Addrs(ctx)
+	if err != nil {
+		return render.Ruleset{}, err
+	}
+	zones, err := s.Zones.List(ctx)
+	if err != nil {
+		return render.Ruleset{}, err
+	}
+	policies, err := s.Zones.Policies(ctx)
+	if err != nil {
+		return render.Ruleset{}, err
+	}
+	return render.Ruleset{Defaults: def, Rules: rules, FQDNs: fqdns, Zones: zones, ZonePolicies: policies}, nil
+}
+
+// Script рендерит Ruleset в nft-скрипт.
+func (s ApplyService) Script(ctx context.Context) (string, []model.Rule, error) {
+	rs, err := s.Ruleset(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return render.RenderRuleset(rs), rs.Rules, nil
+}
+
+func (s ApplyService) Apply(ctx context.Context, actor string) error {
+	// устаревшие имена дорезолвим перед рендером; ошибки остаются в кеше
+	// (видны в `netfence fqdn list`), адреса берутся последние известные
+	if s.FQDN.Resolver != nil {
+		cache := s.FQDN
+		cache.Runner = nil
+		_, _ = cache.Refresh(ctx, false)
+	}
+	script, rules, err := s.Script(ctx)
+	if err != nil {
+		return err
+	}
+	var before any // что было загружено до этого apply
+	if s.Applied.DB != nil {
+		if st, ever, err := s.Applied.Get(ctx); err == nil && ever {
+			before = map[string]any{"hash": st.Hash, "revision": st.Revision}
+		}
+	}
+	runner := s.Runner
+	if runner == nil {
+		runner = util.ShellRunner{}
+	}
+	_, stderr, err := runner.Run("nft", []byte(script), "-f", "-")
+	if err != nil {
+		return fmt.Errorf("nft failed: %v\n%s", err, stderr)
+	}
+	rev, _ := s.revision(ctx)
+	_ = s.Audit.LogChange(ctx, actor, "apply", "ruleset", before,
+		map[string]any{"hash": scriptHash(script), "revision": rev, "rules": len(rules)})
+	if s.Applied.DB != nil {
+		if err := s.saveApplied(ctx, actor, script); err != nil {
+			return fmt.Errorf("applied, but state not recorded: %w", err)
+		}
+	}
+	if s.Export != nil {
+		snap, err := s.snapshot(ctx)
+		if err == nil {
+			err = s.Export.Commit(ctx, actor, "apply", snap, true)
+		}
+		if err != nil {
+			return fmt.Errorf("applied, but git history: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s ApplyService) saveApplied(ctx context.Context, actor, script string) error {
+	snap, err := s.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	rev, err := s.revision(ctx)
+	if err != nil {
+		return err
+	}
+	return s.Applied.Save(ctx, model.AppliedState{Hash: scriptHash(script), Revision: rev, Actor: actor, Snapshot: snap, Script: script})
+}
+
+// Status сравнивает последнее применённое состояние с БД и с тем скриптом,
+// который сейчас загрузил бы apply (с текущими адресами интерфейсов и DNS).
+func (s ApplyService) Status(ctx context.Context) (Status, error) {
+	var st Status
+	var err error
+	if st.Applied, st.Ever, err = s.Applied.Get(ctx); err != nil {
+		return st, err
+	}
+	if v, err := s.Revisions.Get(ctx, 0); err == nil {
+		st.Revision = v.Rev
+	} else if !errors.Is(err, repo.ErrNoRevision) {
+		return st, err
+	}
+	cur, err := s.snapshot(ctx)
+	if err != nil {
+		return st, err
+	}
+	base := st.Applied.Snapshot
+	if !st.Ever {
+		// до первого apply сравниваем со встроенной политикой, а не с пустыми defaults
+		base = model.Snapshot{Defaults: model.BuiltinDefaults()}
+	}
+	st.Pending = plan.Diff(base, cur)
+	if st.Script, _, err = s.Script(ctx); err != nil {
+		return st, err
+	}
+	st.Unchanged = st.Ever && scriptHash(st.Script) == st.Applied.Hash
+	return st, nil
+}
+
+func (s ApplyService) snapshot(ctx context.Context) (model.Snapshot, error) {
+	def, err := s.Defaults.Get(ctx)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	rules, err := s.Rules.List(ctx, false)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	zs, err := s.Zones.Set(ctx)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	return model.Snapshot{Defaults: def, Rules: rules, Zones: &zs}, nil
+}
+
+// revision — номер последней ревизии; 0 — ревизий нет (или история выключена).
+func (s ApplyService) revision(ctx context.Context) (int64, error) {
+	if s.Revisions.DB == nil {
+		return 0, nil
+	}
+	v, err := s.Revisions.Get(ctx, 0)
+	if errors.Is(err, repo.ErrNoRevision) {
+		return 0, nil
+	}
+	return v.Rev, err
+}
+
+func scriptHash(script string) string {
+	h := sha256.Sum256([]byte(script))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Операции FieldDiff.
+const (
+	FieldAdded   = "added"
+	FieldRemoved = "removed"
+	FieldChanged = "changed"
+	FieldSame    = "unchanged"
+)
+
+// FieldDiff — поле объекта до и после изменения. Вложенные объекты
+// разворачиваются в пути через точку, списки сравниваются целиком.
+type FieldDiff struct {
+	Field  string
+	Op     string
+	Before any
+	After  any
+}
+
+// ChangeDiff разбирает details записи аудита в формате Change. ok == false —
+// запись без before/after (сделана до их появления или не изменение).
+func ChangeDiff(details string) (fields []FieldDiff, info any, ok bool) {
+	var c map[string]json.RawMessage
+	if json.Unmarshal([]byte(details), &c) != nil {
+		return nil, nil, false
+	}
+	rb, hasB := c["before"]
+	ra, hasA := c["after"]
+	if !hasB || !hasA {
+		return nil, nil, false
+	}
+	var before, after any
+	_ = json.Unmarshal(rb, &before)
+	_ = json.Unmarshal(ra, &after)
+	if ri, has := c["info"]; has {
+		_ = json.Unmarshal(ri, &info)
+	}
+	b, a := map[string]any{}, map[string]any{}
+	flatten("", before, b)
+	flatten("", after, a)
+	keys := map[string]bool{}
+	for k := range b {
+		keys[k] = true
+	}
+	for k := range a {
+		keys[k] = true
+	}
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		bv, inB := b[k]
+		av, inA := a[k]
+		d := FieldDiff{Field: k, Before: bv, After: av}
+		switch {
+		case !inB:
+			d.Op = FieldAdded
+		case !inA:
+			d.Op = FieldRemoved
+		case jsonText(bv) != jsonText(av):
+			d.Op = FieldChanged
+		default:
+			d.Op = FieldSame
+		}
+		fields = append(fields, d)
+	}
+	return fields, info, true
+}
+
+// flatten: объект -> пути "a.b"; прочие значения верхнего уровня — поле
+// "value". null и пустые списки считаются отсутствующими полями (у правила
+// ports: null и ports: [] — одно и то же).
+func flatten(prefix string, v any, out map[string]any) {
+	m, isMap := v.(map[string]any)
+	l, isList := v.([]any)
+	switch {
+	case v == nil, isList && len(l) == 0:
+	case isMap:
+		for k, x := range m {
+			if prefix != "" {
+				k = prefix + "." + k
+			}
+			flatten(k, x, out)
+		}
+	case prefix == "":
+		out["value"] = v
+	default:
+		out[prefix] = v
+	}
+}
+
+func jsonText(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// String — строка diff: "+ f: v", "- f: v", "~ f: a -> b", "  f: v".
+func (d FieldDiff) String() string {
+	switch d.Op {
+	case FieldAdded:
+		return "+ " + d.Field + ": " + valueText(d.After)
+	case FieldRemoved:
+		return "- " + d.Field + ": " + valueText(d.Before)
+	case FieldChanged:
+		return "~ " + d.Field + ": " + valueText(d.Before) + " -> " + valueText(d.After)
+	}
+	return "  " + d.Field + ": " + valueText(d.After)
+}
+
+// valueText: строки без кавычек (кроме пустой), остальное — компактный JSON.
+func valueText(v any) string {
+	if s, ok := v.(string); ok && s != "" {
+		return s
+	}
+	return jsonText(v)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"netfence/internal/auditsink"
+	"netfence/internal/model"
+)
+
+// ForwardResult — итог Forward для одного приёмника.
+type ForwardResult struct {
+	Sink    string
+	Sent    int
+	Pending int   // осталось недоставленным
+	Err     error // последняя ошибка; доставка продолжится при следующем Forward
+}
+
+// forwardBatch — сколько записей за один Forward получает приёмник.
+const forwardBatch = 500
+
+// forwardRetry — паузы между повторами одной записи.
+var forwardRetry = []time.Duration{100 * time.Millisecond, 400 * time.Millisecond}
+
+// Forward доставляет приёмникам записи журнала после их сохранённой позиции
+// (новый приёмник получает журнал с начала). Записи уходят по порядку ID;
+// запись, которая не ушла и после повторов, останавливает приёмник до
+// следующего вызова — позиция и ошибка сохраняются в БД, так что ничего не
+// теряется. Доставка «хотя бы один раз»: при сбое запись может прийти
+// повторно, ID в событии позволяет отбросить дубликат. Приёмники работают
+// параллельно, сбой одного не задерживает другие.
+func (s AuditService) Forward(ctx context.Context, sinks []auditsink.Sink) []ForwardResult {
+	out := make([]ForwardResult, len(sinks))
+	host := auditsink.Hostname()
+	var wg sync.WaitGroup
+	for i, sk := range sinks {
+		wg.Add(1)
+		go func(i int, sk auditsink.Sink) {
+			defer wg.Done()
+			out[i] = s.forward(ctx, sk, host)
+		}(i, sk)
+	}
+	wg.Wait()
+	return out
+}
+
+func (s AuditService) forward(ctx context.Context, sk auditsink.Sink, host string) ForwardResult {
+	res := ForwardResult{Sink: sk.Name()}
+	defer sk.Close()
+	st, _, err := s.Repo.SinkState(ctx, res.Sink)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	es, err := s.Repo.After(ctx, st.LastID, forwardBatch)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	last := st.LastID
+	for _, e := range es {
+		if res.Err = send(ctx, sk, event(e, host)); res.Err != nil {
+			break
+		}
+		last = e.ID
+		res.Sent++
+	}
+	var msg string
+	if res.Err != nil {
+		msg = res.Err.Error()
+	}
+	if res.Sent > 0 || res.Err != nil || st.LastError != "" {
+		if err := s.Repo.SaveSinkState(context.WithoutCancel(ctx), res.Sink, last, msg); err != nil && res.Err == nil {
+			res.Err = err
+		}
+	}
+	res.Pending, _ = s.Repo.CountAfter(context.WithoutCancel(ctx), last)
+	return res
+}
+
+func send(ctx context.Context, sk auditsink.Sink, e auditsink.Event) error {
+	err := sk.Send(ctx, e)
+	for _, d := range forwardRetry {
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(d):
+		}
+		err = sk.Send(ctx, e)
+	}
+	return err
+}
+
+func event(e model.AuditEntry, host string) auditsink.Event {
+	d := json.RawMessage(e.Details)
+	if !json.Valid(d) {
+		d, _ = json.Marshal(e.Details)
+	}
+	return auditsink.Event{ID: e.ID, Time: e.TS.UTC(), Host: host, Actor: e.Actor, RealActor: e.RealActor, Action: e.Action, Object: e.Object, Details: d, Hash: e.Hash}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"netfence/internal/auditsink"
+	"netfence/internal/config"
+	"netfence/internal/repo"
+)
+
+// flakySink — приёмник, который отказывает, пока fail возвращает true.
+type flakySink struct {
+	mu    sync.Mutex
+	fail  func(e auditsink.Event, attempt int) bool
+	tries map[int64]int
+	got   []int64
+	at    []time.Time
+}
+
+func (f *flakySink) Name() string { return "test:flaky" }
+func (f *flakySink) Close() error { return nil }
+
+func (f *flakySink) Send(_ context.Context, e auditsink.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.tries == nil {
+		f.tries = map[int64]int{}
+	}
+	f.tries[e.ID]++
+	f.at = append(f.at, time.Now())
+	if f.fail != nil && f.fail(e, f.tries[e.ID]) {
+		return errors.New("sink is down")
+	}
+	f.got = append(f.got, e.ID)
+	return nil
+}
+
+func auditWith(t *testing.T, n int) AuditService {
+	t.Helper()
+	s := AuditService{Repo: repo.AuditRepo{DB: testDB(t)}}
+	logN(t, s, n)
+	return s
+}
+
+func logN(t *testing.T, s AuditService, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := s.Log(context.Background(), "root", "add_rule", fmt.Sprintf("rule:%d", i+1), Change{After: map[string]int{"n": i}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func fastRetry(t *testing.T) {
+	old := forwardRetry
+	forwardRetry = []time.Duration{20 * time.Millisecond, 80 * time.Millisecond}
+	t.Cleanup(func() { forwardRetry = old })
+}
+
+func TestForwardRetriesWithBackoff(t *testing.T) {
+	fastRetry(t)
+	s := auditWith(t, 2)
+	// первая запись проходит с третьей попытки
+	sk := &flakySink{fail: func(e auditsink.Event, attempt int) bool { return e.ID == 1 && attempt < 3 }}
+	res := s.Forward(context.Background(), []auditsink.Sink{sk})[0]
+	if res.Err != nil || res.Sent != 2 || res.Pending != 0 {
+		t.Fatalf("result = %+v, want 2 sent", res)
+	}
+	if sk.tries[1] != 3 || fmt.Sprint(sk.got) != "[1 2]" {
+		t.Errorf("tries = %v, delivered = %v", sk.tries, sk.got)
+	}
+	// паузы между попытками — по forwardRetry
+	if d := sk.at[1].Sub(sk.at[0]); d < 20*time.Millisecond {
+		t.Errorf("first retry after %s, want >= 20ms", d)
+	}
+	if d := sk.at[2].Sub(sk.at[1]); d < 80*time.Millisecond {
+		t.Errorf("second retry after %s, want >= 80ms", d)
+	}
+}
+
+func TestForwardKeepsUndeliveredUntilRecovery(t *testing.T) {
+	fastRetry(t)
+	ctx := context.Background()
+	s := auditWith(t, 3)
+	down := true
+	sk := &flakySink{fail: func(e auditsink.Event, _ int) bool { return down && e.ID >= 2 }}
+
+	// запись 2 не уходит и после повторов: 3 за ней не отправляется
+	res := s.Forward(ctx, []auditsink.Sink{sk})[0]
+	if res.Err == nil || res.Sent != 1 || res.Pending != 2 {
+		t.Fatalf("result = %+v, want 1 sent and 2 pending with an error", res)
+	}
+	if sk.tries[2] != 1+len(forwardRetry) || sk.tries[3] != 0 {
+		t.Errorf("tries = %v", sk.tries)
+	}
+	st, _, err := s.Repo.SinkState(ctx, sk.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.LastID != 1 || st.LastError == "" {
+		t.Errorf("saved state = %+v, want last id 1 with the error", st)
+	}
+
+	// приёмник вернулся: досылаются только 2 и 3, ошибка сброшена
+	down = false
+	res = s.Forward(ctx, []auditsink.Sink{sk})[0]
+	if res.Err != nil || res.Sent != 2 || res.Pending != 0 {
+		t.Fatalf("after recovery = %+v", res)
+	}
+	if fmt.Sprint(sk.got) != "[1 2 3]" {
+		t.Errorf("delivered = %v, want each entry once, in order", sk.got)
+	}
+	if st, _, _ := s.Repo.SinkState(ctx, sk.Name()); st.LastID != 3 || st.LastError != "" {
+		t.Errorf("saved state = %+v", st)
+	}
+}
+
+func TestForwardSyslogListenerComesBack(t *testing.T) {
+	fastRetry(t)
+	ctx := context.Background()
+	s := auditWith(t, 3)
+	path := filepath.Join(t.TempDir(), "log.sock")
+	sink := func() []auditsink.Sink {
+		sk, err := auditsink.New(config.AuditSink{Type: "syslog", Network: "unixgram", Address: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []auditsink.Sink{sk}
+	}
+
+	// сокета ещё нет: всё остаётся в журнале
+	if res := s.Forward(ctx, sink())[0]; res.Err == nil || res.Sent != 0 || res.Pending != 3 {
+		t.Fatalf("without a listener = %+v", res)
+	}
+
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	logN(t, s, 1)
+	if res := s.Forward(ctx, sink())[0]; res.Err != nil || res.Sent != 4 || res.Pending != 0 {
+		t.Fatalf("after the listener came up = %+v", res)
+	}
+	buf := make([]byte, 8192)
+	_ = l.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for want := int64(1); want <= 4; want++ {
+		n, err := l.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// MSG — JSON после structured data
+		var e auditsink.Event
+		_, body, _ := strings.Cut(string(buf[:n]), `"] `)
+		if err := json.Unmarshal([]byte(body), &e); err != nil {
+			t.Fatalf("message %q: %v", buf[:n], err)
+		}
+		if e.ID != want {
+			t.Errorf("got entry %d, want %d", e.ID, want)
+		}
+	}
+
+	// уже доставленное не повторяется
+	if res := s.Forward(ctx, sink())[0]; res.Sent != 0 || res.Err != nil {
+		t.Errorf("second forward = %+v, want nothing to send", res)
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"netfence/internal/auditchain"
+	"netfence/internal/repo"
+)
+
+// Retention — политика хранения журнала аудита.
+type Retention struct {
+	MaxAge     time.Duration // 0 — без ограничения по возрасту
+	MaxRows    int           // 0 — без ограничения по числу
+	ArchiveDir string        // "" — без архива
+}
+
+// Set — задано хоть одно ограничение.
+func (r Retention) Set() bool { return r.MaxAge > 0 || r.MaxRows > 0 }
+
+// PruneResult — итог Prune.
+type PruneResult struct {
+	Deleted int
+	FirstID int64 // удалённые записи: FirstID..LastID
+	LastID  int64
+	Archive string // файл архива; "" — без архива
+	Held    int    // записи, которые удалились бы, но ещё не доставлены приёмникам
+}
+
+// Prune удаляет записи журнала сверх политики r. Записи, ещё не
+// доставленные приёмникам hold (имена auditsink.Sink), остаются. С
+// ArchiveDir удаляемые записи сначала пишутся в сжатый JSON lines со всеми
+// полями цепочки; ошибка архива отменяет очистку. Сама очистка — запись
+// prune_audit с хешем последней удалённой записи: с него начинается цепочка
+// для audit verify. dryRun — только подсчёт.
+func (s AuditService) Prune(ctx context.Context, actor string, r Retention, hold []string, now time.Time, dryRun bool) (PruneResult, error) {
+	var res PruneResult
+	if !r.Set() {
+		return res, fmt.Errorf("no audit retention policy: set max age or max rows")
+	}
+	tx, err := s.Repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return res, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var before time.Time
+	if r.MaxAge > 0 {
+		before = now.Add(-r.MaxAge)
+	}
+	full, err := s.Repo.PruneBound(ctx, tx, before, r.MaxRows)
+	if err != nil || full == 0 {
+		return res, err
+	}
+	upTo := full
+	for _, name := range hold {
+		st, _, err := s.Repo.SinkState(ctx, name)
+		if err != nil {
+			return res, err
+		}
+		upTo = min(upTo, st.LastID)
+	}
+	if full > upTo {
+		n, err := s.Repo.CountAfter(ctx, upTo)
+		if err != nil {
+			return res, err
+		}
+		m, err := s.Repo.CountAfter(ctx, full)
+		if err != nil {
+			return res, err
+		}
+		res.Held = n - m
+	}
+	if upTo == 0 {
+		return res, nil
+	}
+	rows, err := s.Repo.PruneRows(ctx, tx, upTo)
+	if err != nil || len(rows) == 0 {
+		return res, err
+	}
+	res.Deleted, res.FirstID, res.LastID = len(rows), rows[0].ID, rows[len(rows)-1].ID
+	if dryRun {
+		return res, nil
+	}
+
+	total, first, err := s.Repo.Count(ctx, tx, 0)
+	if err != nil {
+		return res, err
+	}
+	left, leftFirst, err := s.Repo.Count(ctx, tx, upTo)
+	if err != nil {
+		return res, err
+	}
+	if r.ArchiveDir != "" {
+		if res.Archive, err = writeArchive(r.ArchiveDir, rows); err != nil {
+			return res, fmt.Errorf("audit archive: %w", err)
+		}
+	}
+	fail := func(err error) (PruneResult, error) {
+		if res.Archive != "" {
+			_ = os.Remove(res.Archive)
+		}
+		return PruneResult{}, err
+	}
+	info := map[string]any{"deleted": res.Deleted, "up_to_id": res.LastID, "chain_hash": rows[len(rows)-1].Hash}
+	if r.MaxAge > 0 {
+		info["max_age"] = ageText(r.MaxAge)
+	}
+	if r.MaxRows > 0 {
+		info["max_rows"] = r.MaxRows
+	}
+	if res.Archive != "" {
+		info["archive"] = res.Archive
+	}
+	details := Change{
+		Before: map[string]any{"entries": total, "first_id": first},
+		After:  map[string]any{"entries": left, "first_id": leftFirst},
+		Info:   info,
+	}
+	// запись об очистке — до удаления: она продолжает цепочку за последней
+	// удалённой записью, даже если удаляется весь журнал
+	if err := s.LogTx(ctx, tx, actor, repo.PruneAction, "audit_log", details); err != nil {
+		return fail(err)
+	}
+	if _, err := s.Repo.DeleteUpTo(ctx, tx, upTo); err != nil {
+		return fail(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return res, nil
+}
+
+// archiveEntry — строка архива: запись со всеми полями цепочки, так что
+// архив можно проверить тем же хешем, что и журнал.
+type archiveEntry struct {
+	ID        int64           `json:"id"`
+	TS        string          `json:"ts"`
+	Actor     string          `json:"actor"`
+	RealActor string          `json:"real_actor,omitempty"`
+	RealUID   *int64          `json:"real_uid,omitempty"`
+	Action    string          `json:"action"`
+	Object    string          `json:"object"`
+	Details   json.RawMessage `json:"details"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+	HMAC      string          `json:"hmac,omitempty"`
+}
+
+// writeArchive пишет rows в dir/audit-<first>-<last>.jsonl.gz (0600) через
+// временный файл, чтобы в каталоге не оставалось недописанных архивов.
+func writeArchive(dir string, rows []auditchain.Row) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	name := filepath.Join(dir, fmt.Sprintf("audit-%d-%d.jsonl.gz", rows[0].ID, rows[len(rows)-1].ID))
+	f, err := os.CreateTemp(dir, ".audit-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	ok := false
+	defer func() {
+		if !ok {
+			_ = f.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	for _, r := range rows {
+		d := json.RawMessage(r.Details)
+		if !json.Valid(d) {
+			d, _ = json.Marshal(r.Details)
+		}
+		e := archiveEntry{ID: r.ID, TS: r.TS, Actor: r.Actor, Action: r.Action, Object: r.Object, Details: d, PrevHash: r.PrevHash, Hash: r.Hash, HMAC: r.HMAC}
+		if r.RealActor != "" {
+			e.RealActor, e.RealUID = r.RealActor, &r.RealUID
+		}
+		if err := enc.Encode(e); err != nil {
+			return "", err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	if err := f.Chmod(0o600); err != nil {
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return "", err
+	}
+	ok = true
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+	return name, nil
+}
+
+// ageText — длительность политики в записи аудита: 90d, 36h.
+func ageText(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
@@ -2,17 +2,53 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"netfence/internal/auditchain"
+	"netfence/internal/model"
 	"netfence/internal/repo"
 )
 
 type AuditService struct{ Repo repo.AuditRepo }
 
+// Change — details изменения: состояние объекта до и после (nil — объекта
+// не было или больше нет) и необязательный контекст (режим import, note ...).
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+	Info   any `json:"info,omitempty"`
+}
+
 func (s AuditService) Log(ctx context.Context, actor, action, object string, details any) error {
-	var dstr string
-	if details != nil {
-		b, _ := json.Marshal(details); dstr = string(b)
-	} else { dstr = "{}" }
-	return s.Repo.Write(ctx, actor, action, object, dstr)
+	return s.Repo.Write(ctx, actor, action, object, detailsJSON(details))
+}
+// LogTx — Log внутри транзакции изменения.
+func (s AuditService) LogTx(ctx context.Context, tx *sql.Tx, actor, action, object string, details any) error {
+	return s.Repo.WriteTx(ctx, tx, actor, action, object, detailsJSON(details))
+}
+// LogChange — Log с details Change{before, after}.
+func (s AuditService) LogChange(ctx context.Context, actor, action, object string, before, after any) error {
+	return s.Log(ctx, actor, action, object, Change{Before: before, After: after})
+}
+// LogChangeTx — LogChange внутри транзакции изменения.
+func (s AuditService) LogChangeTx(ctx context.Context, tx *sql.Tx, actor, action, object string, before, after any) error {
+	return s.LogTx(ctx, tx, actor, action, object, Change{Before: before, After: after})
+}
+// Get — запись журнала по ID.
+func (s AuditService) Get(ctx context.Context, id int64) (model.AuditEntry, error) {
+	return s.Repo.Get(ctx, id)
+}
+// List — журнал по фильтру, новые записи первыми.
+func (s AuditService) List(ctx context.Context, f repo.AuditFilter) ([]model.AuditEntry, error) {
+	return s.Repo.List(ctx, f)
+}
+// Verify проверяет цепочку хешей (и HMAC, если задан ключ) всего журнала.
+func (s AuditService) Verify(ctx context.Context) (auditchain.Result, error) {
+	return s.Repo.Verify(ctx)
+}
+func detailsJSON(details any) string {
+	if details == nil { return "{}" }
+	b, _ := json.Marshal(details)
+	return string(b)
 }
 
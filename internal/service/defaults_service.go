@@ -2,19 +2,25 @@ package service
 
 import (
 	"context"
-	"errors"
+	"netfence/internal/app"
 	"netfence/internal/model"
 	"netfence/internal/repo"
-	"strings"
 )
 
-type DefaultsService struct{ Repo repo.DefaultsRepo }
+type DefaultsService struct {
+	Repo  repo.DefaultsRepo
+	Audit AuditService
+}
 
 func (s DefaultsService) Get(ctx context.Context) (model.Defaults, error) { return s.Repo.Get(ctx) }
-func (s DefaultsService) Set(ctx context.Context, d model.Defaults) error {
-	if !validPolicy(d.InputPolicy)||!validPolicy(d.ForwardPolicy)||!validPolicy(d.OutputPolicy) {
-		return errors.New("invalid policy")
+// Set меняет политики по умолчанию; в аудит пишутся прежние и новые значения.
+func (s DefaultsService) Set(ctx context.Context, actor string, d model.Defaults) error {
+	if !model.ValidPolicy(d.InputPolicy)||!model.ValidPolicy(d.ForwardPolicy)||!model.ValidPolicy(d.OutputPolicy) {
+		return app.Invalidf("invalid policy")
 	}
-	return s.Repo.Set(ctx, d)
+	before, err := s.Repo.Get(ctx)
+	if err != nil { return err }
+	if err := s.Repo.Set(ctx, d); err != nil { return err }
+	_ = s.Audit.LogChange(ctx, actor, "set_defaults", "defaults:1", before, d)
+	return nil
 }
-func validPolicy(p string) bool { p=strings.ToLower(p); return p=="accept"||p=="drop" }
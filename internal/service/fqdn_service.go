@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"netfence/internal/model"
+	"netfence/internal/render"
+	"netfence/internal/repo"
+	"netfence/internal/resolve"
+	"netfence/internal/util"
+)
+
+const (
+	minFQDNTTL = 30 * time.Second
+	maxFQDNTTL = time.Hour
+	fqdnRetry  = 30 * time.Second
+)
+
+// FQDNService резолвит DNS-имена из dst правил в кеш и (если задан Runner)
+// обновляет соответствующие nft-множества на месте.
+type FQDNService struct {
+	Repo     repo.FQDNRepo
+	Rules    repo.RuleRepo
+	Resolver resolve.Resolver
+	Runner   util.Runner // nil — только кеш, ядро не трогаем
+	// необязательно: после обновления множества на месте применённый скрипт
+	// (status, diff --pending) правится так же
+	Applied repo.AppliedRepo
+}
+
+// Entries — все имена, на которые ссылаются включённые правила, с текущим
+// состоянием кеша (ещё не резолвленные имена — с пустыми адресами).
+func (s FQDNService) Entries(ctx context.Context) ([]model.FQDNEntry, error) {
+	names, err := s.names(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cached, err := s.Repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]model.FQDNEntry{}
+	for _, e := range cached {
+		byName[e.Name] = e
+	}
+	out := make([]model.FQDNEntry, 0, len(names))
+	for _, n := range names {
+		e, ok := byName[n]
+		if !ok {
+			e = model.FQDNEntry{Name: n}
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// Refresh резолвит имена с истёкшим TTL (все — при force) и возвращает момент
+// следующего обновления. Ошибка резолва одного имени не мешает остальным:
+// старые адреса сохраняются, повтор через fqdnRetry.
+func (s FQDNService) Refresh(ctx context.Context, force bool) (time.Time, error) {
+	entries, err := s.Entries(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := s.Repo.DeleteUnused(ctx); err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now()
+	next := now.Add(maxFQDNTTL)
+	var errs []error
+	for _, e := range entries {
+		if !force && !e.ExpiresAt.IsZero() && e.ExpiresAt.After(now) {
+			if e.ExpiresAt.Before(next) {
+				next = e.ExpiresAt
+			}
+			continue
+		}
+		upd, changed, rerr := s.resolveOne(ctx, e, now)
+		if rerr != nil {
+			errs = append(errs, rerr)
+		}
+		if err := s.Repo.Save(ctx, upd); err != nil {
+			return time.Time{}, err
+		}
+		if changed && s.Runner != nil {
+			if _, stderr, err := s.Runner.Run("nft", []byte(render.SetUpdate(upd.Name, upd.Addrs)), "-f", "-"); err != nil {
+				errs = append(errs, fmt.Errorf("update set for %s: %v: %s", upd.Name, err, stderr))
+			} else if err := s.patchApplied(ctx, upd); err != nil {
+				errs = append(errs, fmt.Errorf("applied state for %s: %w", upd.Name, err))
+			}
+		}
+		if upd.ExpiresAt.Before(next) {
+			next = upd.ExpiresAt
+		}
+	}
+	return next, errors.Join(errs...)
+}
+
+// patchApplied отражает обновление множества e.Name в применённом скрипте.
+func (s FQDNService) patchApplied(ctx context.Context, e model.FQDNEntry) error {
+	if s.Applied.DB == nil {
+		return nil
+	}
+	st, ever, err := s.Applied.Get(ctx)
+	if err != nil || !ever {
+		return err
+	}
+	script := render.PatchSet(st.Script, e.Name, e.Addrs)
+	if script == st.Script {
+		return nil
+	}
+	return s.Applied.SetScript(ctx, script, scriptHash(script))
+}
+
+func (s FQDNService) resolveOne(ctx context.Context, e model.FQDNEntry, now time.Time) (model.FQDNEntry, bool, error) {
+	ans, err := s.Resolver.Resolve(ctx, e.Name)
+	if err != nil {
+		e.Error = err.Error()
+		e.ExpiresAt = now.Add(fqdnRetry)
+		return e, false, err
+	}
+	ttl := ans.TTL
+	if ttl < minFQDNTTL {
+		ttl = minFQDNTTL
+	}
+	if ttl > maxFQDNTTL {
+		ttl = maxFQDNTTL
+	}
+	addrs := make([]string, 0, len(ans.IPs))
+	seen := map[string]bool{}
+	for _, ip := range ans.IPs {
+		if a := ip.String(); !seen[a] {
+			seen[a] = true
+			addrs = append(addrs, a)
+		}
+	}
+	sort.Strings(addrs)
+	changed := !equalStrs(addrs, e.Addrs)
+	e.Addrs, e.Error = addrs, ""
+	e.ResolvedAt, e.ExpiresAt = now, now.Add(ttl)
+	return e, changed, nil
+}
+
+func (s FQDNService) names(ctx context.Context) ([]string, error) {
+	rules, err := s.Rules.List(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	return render.FQDNNames(rules), nil
+}
+
+func equalStrs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dbpkg "netfence/internal/db"
+	"netfence/internal/model"
+	"netfence/internal/render"
+	"netfence/internal/repo"
+	"netfence/internal/resolve"
+	"netfence/internal/resolve/resolvetest"
+
+	_ "modernc.org/sqlite"
+)
+
+// dnsZone — изменяемые ответы stub-сервера: имя → A-адрес и TTL; нет
+// имени — NXDOMAIN.
+type dnsZone struct {
+	mu   sync.Mutex
+	recs map[string]resolvetest.RR
+}
+
+func (z *dnsZone) set(name, ip string, ttl uint32) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.recs[name] = resolvetest.A(name, ip, ttl)
+}
+
+func (z *dnsZone) drop(name string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	delete(z.recs, name)
+}
+
+func (z *dnsZone) handle(name, proto string) resolvetest.Reply {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	rr, ok := z.recs[name]
+	if !ok {
+		return resolvetest.Reply{Rcode: 3}
+	}
+	return resolvetest.Reply{Answers: []resolvetest.RR{rr}}
+}
+
+// testDB — БД во временном каталоге со всеми миграциями.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "fw.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := dbpkg.ApplyAll(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newFQDNTest(t *testing.T, names ...string) (FQDNService, *dnsZone, *resolvetest.Server) {
+	t.Helper()
+	ctx := context.Background()
+	db := testDB(t)
+	rules := repo.RuleRepo{DB: db}
+	if _, err := rules.Create(ctx, &model.Rule{Chain: "output", Proto: "tcp", Action: "accept", Ports: []int{443}, DstCIDRs: names, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	zone := &dnsZone{recs: map[string]resolvetest.RR{}}
+	srv := resolvetest.NewServer(t, zone.handle)
+	svc := FQDNService{Repo: repo.FQDNRepo{DB: db}, Rules: rules, Resolver: resolve.DNSResolver{Server: srv.Addr, Timeout: 2 * time.Second}}
+	return svc, zone, srv
+}
+
+func entry(t *testing.T, svc FQDNService, name string) model.FQDNEntry {
+	t.Helper()
+	es, err := svc.Entries(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range es {
+		if e.Name == name {
+			return e
+		}
+	}
+	t.Fatalf("no cache entry for %s", name)
+	return model.FQDNEntry{}
+}
+
+// near: t примерно равно want (время в БД хранится с точностью до секунды).
+func near(t, want time.Time) bool {
+	d := t.Sub(want)
+	return d > -2*time.Second && d < 2*time.Second
+}
+
+func TestFQDNRefreshTTL(t *testing.T) {
+	ctx := context.Background()
+	svc, zone, srv := newFQDNTest(t, "api.example.com", "short.example.com", "long.example.com", "missing.example.com")
+	zone.set("api.example.com", "192.0.2.1", 120)
+	zone.set("short.example.com", "192.0.2.2", 5)    // меньше minFQDNTTL
+	zone.set("long.example.com", "192.0.2.3", 86400) // больше maxFQDNTTL
+
+	start := time.Now()
+	next, err := svc.Refresh(ctx, false)
+	if err == nil || !strings.Contains(err.Error(), "missing.example.com") {
+		t.Fatalf("err = %v, want the NXDOMAIN of missing.example.com", err)
+	}
+	for name, ttl := range map[string]time.Duration{
+		"api.example.com":     120 * time.Second,
+		"short.example.com":   minFQDNTTL,
+		"long.example.com":    maxFQDNTTL,
+		"missing.example.com": fqdnRetry,
+	} {
+		if e := entry(t, svc, name); !near(e.ExpiresAt, start.Add(ttl)) {
+			t.Errorf("%s expires at %s, want about %s", name, e.ExpiresAt, start.Add(ttl))
+		}
+	}
+	if e := entry(t, svc, "api.example.com"); strings.Join(e.Addrs, ",") != "192.0.2.1" || e.Error != "" {
+		t.Errorf("api.example.com = %+v", e)
+	}
+	if e := entry(t, svc, "missing.example.com"); e.Error == "" || len(e.Addrs) != 0 {
+		t.Errorf("missing.example.com = %+v", e)
+	}
+	if !near(next, start.Add(minFQDNTTL)) {
+		t.Errorf("next refresh at %s, want about %s", next, start.Add(minFQDNTTL))
+	}
+
+	// ничего не истекло — сервер не спрашивают
+	if _, err := svc.Refresh(ctx, false); err != nil && !strings.Contains(err.Error(), "missing") {
+		t.Fatal(err)
+	}
+	if n := srv.Queries("api.example.com"); n != 1 {
+		t.Errorf("api.example.com queried %d times before expiry, want 1", n)
+	}
+}
+
+func TestFQDNRefreshExpired(t *testing.T) {
+	ctx := context.Background()
+	svc, zone, srv := newFQDNTest(t, "api.example.com", "web.example.com")
+	zone.set("api.example.com", "192.0.2.1", 300)
+	zone.set("web.example.com", "192.0.2.9", 300)
+	if _, err := svc.Refresh(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// TTL api.example.com истёк, адрес сменился
+	e := entry(t, svc, "api.example.com")
+	e.ExpiresAt = time.Now().Add(-time.Second)
+	if err := svc.Repo.Save(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	zone.set("api.example.com", "192.0.2.5", 300)
+	if _, err := svc.Refresh(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if e := entry(t, svc, "api.example.com"); strings.Join(e.Addrs, ",") != "192.0.2.5" {
+		t.Errorf("api.example.com addrs = %v after expiry", e.Addrs)
+	}
+	if n, m := srv.Queries("api.example.com"), srv.Queries("web.example.com"); n != 2 || m != 1 {
+		t.Errorf("queries: api %d, web %d; want 2 and 1", n, m)
+	}
+
+	// имя пропало: старые адреса остаются, ошибка в кеше, повтор через fqdnRetry
+	zone.drop("api.example.com")
+	start := time.Now()
+	if _, err := svc.Refresh(ctx, true); err == nil {
+		t.Fatal("no error for NXDOMAIN")
+	}
+	e = entry(t, svc, "api.example.com")
+	if strings.Join(e.Addrs, ",") != "192.0.2.5" || !strings.Contains(e.Error, "no such host") || !near(e.ExpiresAt, start.Add(fqdnRetry)) {
+		t.Errorf("api.example.com after NXDOMAIN = %+v", e)
+	}
+}
+
+// nftLog запоминает скрипты, переданные nft.
+type nftLog struct{ scripts []string }
+
+func (l *nftLog) Run(name string, stdin []byte, args ...string) (string, string, error) {
+	l.scripts = append(l.scripts, string(stdin))
+	return "", "", nil
+}
+
+func TestFQDNRefreshPerNetns(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+	zone := &dnsZone{recs: map[string]resolvetest.RR{}}
+	srv := resolvetest.NewServer(t, zone.handle)
+	svcs := map[string]FQDNService{}
+	logs := map[string]*nftLog{}
+	for _, ns := range []string{"", "blue"} {
+		rules := repo.RuleRepo{DB: db, NS: ns}
+		if _, err := rules.Create(ctx, &model.Rule{Chain: "output", Proto: "tcp", Action: "accept", Ports: []int{443}, DstCIDRs: []string{"api.example.com"}, Enabled: true}); err != nil {
+			t.Fatal(err)
+		}
+		logs[ns] = &nftLog{}
+		svcs[ns] = FQDNService{Repo: repo.FQDNRepo{DB: db, NS: ns}, Rules: rules, Runner: logs[ns],
+			Resolver: resolve.DNSResolver{Server: srv.Addr, Timeout: 2 * time.Second}}
+	}
+	zone.set("api.example.com", "192.0.2.1", 300)
+	for _, ns := range []string{"", "blue"} {
+		if _, err := svcs[ns].Refresh(ctx, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// адрес сменился; namespace blue обновился первым — хост всё равно
+	// должен обновить своё множество
+	zone.set("api.example.com", "192.0.2.7", 300)
+	for _, ns := range []string{"blue", ""} {
+		if _, err := svcs[ns].Refresh(ctx, true); err != nil {
+			t.Fatal(err)
+		}
+		l := logs[ns]
+		if len(l.scripts) != 2 || !strings.Contains(l.scripts[1], "192.0.2.7") {
+			t.Errorf("netns %q: nft scripts %q, want an update to 192.0.2.7", ns, l.scripts)
+		}
+		if e := entry(t, svcs[ns], "api.example.com"); strings.Join(e.Addrs, ",") != "192.0.2.7" {
+			t.Errorf("netns %q: addrs = %v", ns, e.Addrs)
+		}
+	}
+
+	// правило blue удалено — из кеша уходит только имя blue
+	if err := (repo.RuleRepo{DB: db, NS: "blue"}).Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svcs["blue"].Refresh(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if es, _ := svcs["blue"].Repo.List(ctx); len(es) != 0 {
+		t.Errorf("blue cache = %+v, want empty", es)
+	}
+	if es, _ := svcs[""].Repo.List(ctx); len(es) != 1 {
+		t.Errorf("host cache = %+v, want api.example.com", es)
+	}
+}
+
+func TestFQDNRefreshPatchesApplied(t *testing.T) {
+	ctx := context.Background()
+	svc, zone, _ := newFQDNTest(t, "api.example.com")
+	svc.Runner = &nftLog{}
+	svc.Applied = repo.AppliedRepo{DB: svc.Repo.DB}
+	zone.set("api.example.com", "192.0.2.1", 300)
+	if _, err := svc.Refresh(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := svc.Rules.List(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := render.RenderRuleset(render.Ruleset{Defaults: model.BuiltinDefaults(), Rules: rules,
+		FQDNs: map[string][]string{"api.example.com": {"192.0.2.1"}}})
+	if err := svc.Applied.Save(ctx, model.AppliedState{Hash: scriptHash(script), Script: script, Actor: "root"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// демон обновил множество на месте — применённый скрипт тот же, что
+	// отрендерил бы apply с новыми адресами
+	zone.set("api.example.com", "192.0.2.7", 300)
+	if _, err := svc.Refresh(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	want := render.RenderRuleset(render.Ruleset{Defaults: model.BuiltinDefaults(), Rules: rules,
+		FQDNs: map[string][]string{"api.example.com": {"192.0.2.7"}}})
+	st, _, err := svc.Applied.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Script != want || st.Hash != scriptHash(want) {
+		t.Errorf("applied script after the set update:\n%s\nwant:\n%s", st.Script, want)
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"netfence/internal/model"
+	"netfence/internal/profile"
+)
+
+// ProfileService создаёт правила из профилей приложений и поддерживает их
+// в соответствии с каталогом.
+type ProfileService struct {
+	Rules   RulesService
+	Catalog profile.Catalog
+}
+
+// Allow разворачивает профиль по каждому шаблону tmpls (несколько — для
+// --from <зона>) и добавляет получившиеся правила одной транзакцией, чтобы не
+// оставить профиль применённым наполовину.
+func (s ProfileService) Allow(ctx context.Context, actor, name string, tmpls ...model.Rule) (ids []int64, err error) {
+	var rs []model.Rule
+	for _, t := range tmpls {
+		exp, err := s.Catalog.Expand(name, t)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, exp...)
+	}
+	for i := range rs {
+		if err := check(&rs[i]); err != nil {
+			return nil, err
+		}
+	}
+	tx, err := s.Rules.Repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	for i := range rs {
+		if rs[i].ID, err = s.Rules.Repo.CreateTx(ctx, tx, &rs[i]); err != nil {
+			return nil, err
+		}
+		if err = s.Rules.Audit.LogChangeTx(ctx, tx, actor, "add_rule", fmt.Sprintf("rule:%d", rs[i].ID), nil, rs[i]); err != nil {
+			return nil, err
+		}
+		ids = append(ids, rs[i].ID)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	for _, r := range rs {
+		if r.Enabled {
+			s.Rules.warnShadowed(ctx, r.ID, r)
+		}
+	}
+	return ids, nil
+}
+
+// Remove удаляет все правила, созданные из профиля name.
+func (s ProfileService) Remove(ctx context.Context, actor, name string) (int, error) {
+	rules, err := s.Rules.List(ctx, false)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, r := range rules {
+		if r.Profile != nil && *r.Profile == name {
+			if err := s.Rules.Delete(ctx, actor, r.ID); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Sync приводит помеченные профилями правила к текущему каталогу: правила
+// изменённых профилей пересоздаются на прежнем месте, правила удалённых
+// профилей удаляются. Всё делается в одной транзакции: если новое правило не
+// проходит проверку (например, интерфейс пропал), старые остаются как были.
+// Возвращает число затронутых групп правил.
+func (s ProfileService) Sync(ctx context.Context, actor string) (n int, err error) {
+	rules, err := s.Rules.List(ctx, false)
+	if err != nil {
+		return 0, err
+	}
+	groups := map[string][]model.Rule{}
+	var keys []string
+	for _, r := range rules {
+		if r.Profile == nil {
+			continue
+		}
+		k := profileGroupKey(r)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], r)
+	}
+	wants := map[string][]model.Rule{} // только изменённые группы
+	for _, k := range keys {
+		cur := groups[k]
+		name := *cur[0].Profile
+		var want []model.Rule
+		if _, ok := s.Catalog[name]; ok {
+			if want, err = s.Catalog.Expand(name, cur[0]); err != nil {
+				return 0, err
+			}
+			if sameEntries(cur, want) {
+				continue
+			}
+			keepEntryState(cur, want)
+		}
+		for i := range want {
+			if err := check(&want[i]); err != nil {
+				return 0, fmt.Errorf("profile %s: %w", name, err)
+			}
+		}
+		wants[k] = want
+	}
+	if len(wants) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.Rules.Repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	var order []int64
+	done := map[string]bool{}
+	for _, r := range rules {
+		k := ""
+		if r.Profile != nil {
+			k = profileGroupKey(r)
+		}
+		want, ok := wants[k]
+		if !ok {
+			order = append(order, r.ID)
+			continue
+		}
+		if err = s.Rules.Repo.DeleteTx(ctx, tx, r.ID); err != nil {
+			return 0, err
+		}
+		if err = s.Rules.Audit.LogChangeTx(ctx, tx, actor, "del_rule", fmt.Sprintf("rule:%d", r.ID), r, nil); err != nil {
+			return 0, err
+		}
+		if done[k] {
+			continue
+		}
+		// новые правила группы встают на место её первого правила
+		done[k] = true
+		for i := range want {
+			if want[i].ID, err = s.Rules.Repo.CreateTx(ctx, tx, &want[i]); err != nil {
+				return 0, err
+			}
+			if err = s.Rules.Audit.LogChangeTx(ctx, tx, actor, "add_rule", fmt.Sprintf("rule:%d", want[i].ID), nil, want[i]); err != nil {
+				return 0, err
+			}
+			order = append(order, want[i].ID)
+		}
+	}
+	if err = s.Rules.Repo.ReorderTx(ctx, tx, order); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(wants), nil
+}
+
+// profileGroupKey — правила одного вызова allow: профиль и параметры шаблона.
+// proto/ports задаёт профиль, а comment/enabled правят у отдельных правил,
+// поэтому в ключ они не входят.
+func profileGroupKey(r model.Rule) string {
+	deref := func(p *string) string {
+		if p == nil {
+			return ""
+		}
+		return *p
+	}
+	return strings.Join([]string{
+		deref(r.Profile), r.Chain, r.Action, deref(r.InIf), deref(r.OutIf),
+		strings.Join(r.SrcCIDRs, ","), strings.Join(r.DstCIDRs, ","),
+	}, "|")
+}
+
+// keepEntryState переносит comment/enabled оставшихся в профиле записей
+// (те же proto и порты) на пересоздаваемые правила.
+func keepEntryState(cur, want []model.Rule) {
+	for i := range want {
+		for _, r := range cur {
+			if entrySig(r) == entrySig(want[i]) {
+				want[i].Comment, want[i].Enabled = r.Comment, r.Enabled
+				break
+			}
+		}
+	}
+}
+
+func entrySig(r model.Rule) string {
+	ports := append([]int(nil), r.Ports...)
+	sort.Ints(ports)
+	return r.Proto + fmt.Sprint(ports)
+}
+
+func sameEntries(cur, want []model.Rule) bool {
+	sig := func(rs []model.Rule) []string {
+		out := make([]string, 0, len(rs))
+		for _, r := range rs {
+			out = append(out, entrySig(r))
+		}
+		sort.Strings(out)
+		return out
+	}
+	return equalStrs(sig(cur), sig(want))
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"netfence/internal/app"
+	"netfence/internal/model"
+	"netfence/internal/plan"
+	"netfence/internal/repo"
+)
+
+// ErrApprovalRequired — прямые изменения запрещены конфигом (approval.required).
+var ErrApprovalRequired = errors.New("changes require approval: submit them with `netfence request submit`")
+
+// ErrApproveAs — одобрять запросы можно только от своего имени: иначе один
+// admin с --as подал бы и одобрил запрос сам.
+var ErrApproveAs = errors.New("requests can only be approved as yourself, not with --as")
+
+// RequestService — двухэтапные изменения: один человек предлагает желаемый
+// снимок ruleset-а, другой одобряет, после чего запрос применяется через sync.
+type RequestService struct {
+	Repo      repo.RequestRepo
+	Revisions RevisionService
+}
+
+// Submit сохраняет запрос; пустой план (нечего менять) — ошибка.
+func (s RequestService) Submit(ctx context.Context, actor, reason string, want model.Snapshot) (int64, plan.Plan, error) {
+	if strings.TrimSpace(reason) == "" {
+		return 0, plan.Plan{}, app.Invalidf("reason is required")
+	}
+	p, err := s.Revisions.Sync.Plan(ctx, want)
+	if err != nil {
+		return 0, p, err
+	}
+	if p.Empty() {
+		return 0, p, errors.New("no changes to request")
+	}
+	// база запроса — текущее состояние; фиксируем его ревизией
+	if _, err := s.Revisions.Record(ctx, actor, "state before change request"); err != nil {
+		return 0, p, err
+	}
+	base, err := s.latestRevision(ctx)
+	if err != nil {
+		return 0, p, err
+	}
+	id, err := s.Repo.Create(ctx, model.ChangeRequest{Author: actor, Reason: reason, Snapshot: want, BaseRevision: base})
+	if err != nil {
+		return 0, p, err
+	}
+	_ = s.audit().LogChange(ctx, actor, "request_submit", fmt.Sprintf("request:%d", id), nil,
+		map[string]any{"status": model.RequestPending, "author": actor, "reason": reason, "base_revision": base})
+	return id, p, nil
+}
+
+func (s RequestService) List(ctx context.Context, status string) ([]model.ChangeRequest, error) {
+	return s.Repo.List(ctx, status)
+}
+
+// Show — запрос и изменения, которые он внёс бы в текущую БД.
+func (s RequestService) Show(ctx context.Context, id int64) (model.ChangeRequest, plan.Plan, error) {
+	cr, err := s.Repo.Get(ctx, id)
+	if err != nil {
+		return cr, plan.Plan{}, err
+	}
+	p, err := s.Revisions.Sync.Plan(ctx, cr.Snapshot)
+	return cr, p, err
+}
+
+// Approve — второй человек: автор не может одобрить свой запрос. Сравниваются
+// и действующие пользователи, и настоящие пользователи ОС.
+func (s RequestService) Approve(ctx context.Context, actor string, id int64, note string) error {
+	return s.review(ctx, actor, id, model.RequestApproved, note)
+}
+
+func (s RequestService) Reject(ctx context.Context, actor string, id int64, note string) error {
+	return s.review(ctx, actor, id, model.RequestRejected, note)
+}
+
+func (s RequestService) review(ctx context.Context, actor string, id int64, status, note string) error {
+	cr, err := s.Repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if status == model.RequestApproved {
+		real := repo.RealActor()
+		if real != "" && real != actor {
+			return ErrApproveAs
+		}
+		if cr.Author == actor || (real != "" && cr.RealAuthor == real) {
+			return fmt.Errorf("request %d: author %s cannot approve their own request", id, cr.AuthorWho())
+		}
+	}
+	if err := s.Repo.Review(ctx, id, status, actor, note); err != nil {
+		return err
+	}
+	action := "request_approve"
+	if status == model.RequestRejected {
+		action = "request_reject"
+	}
+	_ = s.audit().LogChange(ctx, actor, action, fmt.Sprintf("request:%d", id),
+		map[string]string{"status": cr.Status}, map[string]string{"status": status, "reviewer": actor, "note": note})
+	return nil
+}
+
+// Apply применяет одобренный запрос. Если ruleset изменился после подачи
+// запроса, одобренный план уже не соответствует действительности — запрос
+// нужно подать заново.
+func (s RequestService) Apply(ctx context.Context, actor string, id int64) (plan.Plan, error) {
+	cr, err := s.Repo.Get(ctx, id)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	if cr.Status != model.RequestApproved {
+		return plan.Plan{}, fmt.Errorf("request %d is %s, not approved", id, cr.Status)
+	}
+	if _, err := s.Revisions.Record(ctx, actor, "state before change request"); err != nil {
+		return plan.Plan{}, err
+	}
+	cur, err := s.latestRevision(ctx)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	if cur != cr.BaseRevision {
+		return plan.Plan{}, fmt.Errorf("request %d was made against revision %d, ruleset is now at revision %d; submit it again", id, cr.BaseRevision, cur)
+	}
+	p, err := s.Revisions.Sync.SyncWith(ctx, actor, cr.Snapshot, func(tx *sql.Tx) error {
+		if err := s.Repo.MarkAppliedTx(ctx, tx, id); err != nil {
+			return err
+		}
+		return s.audit().LogTx(ctx, tx, actor, "request_apply", fmt.Sprintf("request:%d", id), Change{
+			Before: map[string]string{"status": cr.Status},
+			After:  map[string]string{"status": model.RequestApplied},
+			Info:   map[string]string{"author": cr.Author, "reviewer": cr.Reviewer},
+		})
+	})
+	if err != nil {
+		return p, err
+	}
+	if _, err := s.Revisions.Record(ctx, actor, fmt.Sprintf("request %d: %s", id, cr.Reason)); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func (s RequestService) latestRevision(ctx context.Context) (int64, error) {
+	v, err := s.Revisions.Repo.Get(ctx, 0)
+	if errors.Is(err, repo.ErrNoRevision) {
+		return 0, nil
+	}
+	return v.Rev, err
+}
+
+func (s RequestService) audit() AuditService { return s.Revisions.Sync.Audit }
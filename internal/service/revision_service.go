@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"netfence/internal/model"
+	"netfence/internal/plan"
+	"netfence/internal/repo"
+)
+
+// RevisionService — история ruleset-а: снимок после каждого изменения,
+// сравнение и откат к любой ревизии.
+type RevisionService struct {
+	Repo   repo.RevisionRepo
+	Sync   SyncService
+	Export HistoryExporter // необязательно: каждая новая ревизия уходит и туда
+}
+
+// HistoryExporter — внешняя история снимков (git-репозиторий).
+type HistoryExporter interface {
+	Commit(ctx context.Context, actor, message string, snap model.Snapshot, allowEmpty bool) error
+}
+
+// Current — текущее состояние ruleset-а в виде снимка.
+func (s RevisionService) Current(ctx context.Context) (model.Snapshot, error) {
+	return s.Sync.Current(ctx)
+}
+
+// Record сохраняет текущее состояние новой ревизией, если оно отличается от
+// последней. Возвращает номер ревизии (0 — изменений нет).
+func (s RevisionService) Record(ctx context.Context, actor, message string) (int64, error) {
+	cur, err := s.Current(ctx)
+	if err != nil {
+		return 0, err
+	}
+	last, err := s.Repo.Get(ctx, 0)
+	switch {
+	case errors.Is(err, repo.ErrNoRevision):
+	case err != nil:
+		return 0, err
+	case plan.Diff(last.Snapshot, cur).Empty():
+		return 0, nil
+	}
+	rev, err := s.Repo.Add(ctx, actor, message, cur)
+	if err != nil || s.Export == nil {
+		return rev, err
+	}
+	if err := s.Export.Commit(ctx, actor, message, cur, false); err != nil {
+		return rev, fmt.Errorf("revision %d recorded, git history: %w", rev, err)
+	}
+	return rev, nil
+}
+
+func (s RevisionService) History(ctx context.Context, limit int) ([]model.Revision, error) {
+	return s.Repo.List(ctx, limit)
+}
+
+func (s RevisionService) Get(ctx context.Context, rev int64) (model.Revision, error) {
+	v, err := s.Repo.Get(ctx, rev)
+	if errors.Is(err, repo.ErrNoRevision) {
+		return v, fmt.Errorf("revision %d: %w", rev, err)
+	}
+	return v, err
+}
+
+// Diff — изменения, переводящие ревизию a в ревизию b.
+func (s RevisionService) Diff(ctx context.Context, a, b int64) (plan.Plan, error) {
+	ra, err := s.Get(ctx, a)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	rb, err := s.Get(ctx, b)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	return plan.Diff(ra.Snapshot, rb.Snapshot), nil
+}
+
+// Rollback приводит ruleset к ревизии rev через sync и записывает результат
+// новой ревизией.
+func (s RevisionService) Rollback(ctx context.Context, actor string, rev int64) (plan.Plan, int64, error) {
+	v, err := s.Get(ctx, rev)
+	if err != nil {
+		return plan.Plan{}, 0, err
+	}
+	latest, err := s.Get(ctx, 0)
+	if err != nil {
+		return plan.Plan{}, 0, err
+	}
+	p, err := s.Sync.Sync(ctx, actor, v.Snapshot)
+	if err != nil || p.Empty() {
+		return p, 0, err
+	}
+	n, err := s.Record(ctx, actor, fmt.Sprintf("rollback to revision %d", rev))
+	_ = s.Sync.Audit.LogChange(ctx, actor, "rollback", fmt.Sprintf("revision:%d", rev),
+		map[string]int64{"revision": latest.Rev}, map[string]int64{"revision": n})
+	return p, n, err
+}
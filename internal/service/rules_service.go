@@ -2,12 +2,11 @@ package service
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"net"
-	"strings"
 
+	"netfence/internal/analyze"
 	"netfence/internal/model"
+	"netfence/internal/render"
 	"netfence/internal/repo"
 	"netfence/internal/util"
 )
@@ -15,39 +14,55 @@ import (
 type RulesService struct {
 	Repo   repo.RuleRepo
 	Audit  AuditService
+	Warn   func(string) // необязательно: предупреждения (например, правило затенено)
 }
 
-var ErrInvalid = errors.New("invalid rule")
+var ErrInvalid = model.ErrInvalidRule
 
 func (s RulesService) List(ctx context.Context, enabledOnly bool) ([]model.Rule, error) {
 	return s.Repo.List(ctx, enabledOnly)
 }
 func (s RulesService) Add(ctx context.Context, actor string, r *model.Rule) (int64, error) {
-	if err := validateRule(r); err != nil { return 0, err }
-	// validate interfaces exist
-	if r.InIf != nil { if err := util.IfExists(*r.InIf); err != nil { return 0, err } }
-	if r.OutIf != nil { if err := util.IfExists(*r.OutIf); err != nil { return 0, err } }
+	if err := check(r); err != nil { return 0, err }
 	id, err := s.Repo.Create(ctx, r)
-	if err == nil { _ = s.Audit.Log(ctx, actor, "add_rule", fmt.Sprintf("rule:%d", id), r) }
+	if err == nil {
+		after := *r
+		after.ID = id
+		_ = s.Audit.LogChange(ctx, actor, "add_rule", fmt.Sprintf("rule:%d", id), nil, after)
+	}
+	if err == nil && r.Enabled { s.warnShadowed(ctx, id, *r) }
 	return id, err
 }
-func (s RulesService) Delete(ctx context.Context, actor string, id int64) error {
-	err := s.Repo.Delete(ctx, id)
-	if err == nil { _ = s.Audit.Log(ctx, actor, "del_rule", fmt.Sprintf("rule:%d", id), nil) }
-	return err
-}
 
-func validateRule(r *model.Rule) error {
-	if !oneOf(r.Chain,"input","forward","output") { return Err("chain") }
-	if !oneOf(r.Proto,"all","tcp","udp","icmp") { return Err("proto") }
-	if !oneOf(r.Action,"accept","drop") { return Err("action") }
-	for _, p := range r.Ports { if p<=0 || p>65535 { return Err("port") } }
-	for _, c := range r.SrcCIDRs { if _,_,e:=net.ParseCIDR(c); e!=nil { return Err("src_cidr") } }
-	for _, c := range r.DstCIDRs { if _,_,e:=net.ParseCIDR(c); e!=nil { return Err("dst_cidr") } }
-	for _, t := range r.ICMPTypes { if t<0 || t>255 { return Err("icmp_type") } }
-	if r.InIf!=nil && strings.TrimSpace(*r.InIf)=="" { return Err("in_if") }
-	if r.OutIf!=nil && strings.TrimSpace(*r.OutIf)=="" { return Err("out_if") }
+// check — проверки Add до записи: поля правила и существование интерфейсов.
+func check(r *model.Rule) error {
+	if err := model.ValidateRule(r); err != nil { return err }
+	// validate interfaces exist
+	if r.InIf != nil { if err := util.IfExists(*r.InIf); err != nil { return err } }
+	if r.OutIf != nil { if err := util.IfExists(*r.OutIf); err != nil { return err } }
+	for ifname := range render.IfaceRefs([]model.Rule{*r}) {
+		if err := util.IfExists(ifname); err != nil { return err }
+	}
 	return nil
 }
-func oneOf(v string, xs ...string) bool { for _,x:= range xs { if v==x { return true } }; return false }
-func Err(field string) error { return fmt.Errorf("%w: %s", ErrInvalid, field) }
+
+// warnShadowed сообщает через Warn, если новое правило никогда не сработает.
+func (s RulesService) warnShadowed(ctx context.Context, id int64, r model.Rule) {
+	if s.Warn == nil { return }
+	rules, err := s.Repo.List(ctx, true)
+	if err != nil { return }
+	r.ID = id
+	var before []model.Rule
+	for _, x := range rules { if x.ID < id { before = append(before, x) } }
+	for _, f := range analyze.Check(before, r) {
+		if f.Kind != analyze.Conflict { s.Warn(f.String()) }
+	}
+}
+func (s RulesService) Delete(ctx context.Context, actor string, id int64) error {
+	before, err := s.Repo.Get(ctx, id)
+	if err != nil { return err }
+	if before == nil { return fmt.Errorf("rule %d: %w", id, repo.ErrNoRule) }
+	err = s.Repo.Delete(ctx, id)
+	if err == nil { _ = s.Audit.LogChange(ctx, actor, "del_rule", fmt.Sprintf("rule:%d", id), before, nil) }
+	return err
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"netfence/internal/app"
+	"netfence/internal/model"
+	"netfence/internal/plan"
+	"netfence/internal/repo"
+)
+
+// SyncService приводит ruleset к желаемому снапшоту точечными изменениями
+// (plan/sync), сохраняя ID совпавших правил.
+type SyncService struct {
+	Rules    repo.RuleRepo
+	Defaults repo.DefaultsRepo
+	Zones    repo.ZoneRepo
+	Audit    AuditService
+}
+
+// Plan проверяет желаемый снапшот и считает разницу с БД.
+func (s SyncService) Plan(ctx context.Context, want model.Snapshot) (plan.Plan, error) {
+	cur, err := s.current(ctx, want)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	return plan.Diff(cur, want), nil
+}
+
+// ImportPlan — что сделает import в режиме mode (см. plan.Import).
+func (s SyncService) ImportPlan(ctx context.Context, want model.Snapshot, mode string, prune bool) (plan.Plan, error) {
+	_, p, err := s.importPlan(ctx, want, mode, prune)
+	return p, err
+}
+
+// Import выполняет ImportPlan одной транзакцией: изменения правил, defaults
+// и итоговая запись import_yaml в аудите.
+func (s SyncService) Import(ctx context.Context, actor string, want model.Snapshot, mode string, prune bool) (plan.Plan, error) {
+	cur, p, err := s.importPlan(ctx, want, mode, prune)
+	if err != nil || p.Empty() {
+		return p, err
+	}
+	add, change, del := p.Counts()
+	err = s.apply(ctx, actor, p, func(tx *sql.Tx) error {
+		return s.Audit.LogTx(ctx, tx, actor, "import_yaml", "snapshot", Change{
+			Before: map[string]int{"rules": len(cur.Rules)},
+			After:  map[string]int{"rules": len(cur.Rules) + add - del},
+			Info:   map[string]any{"mode": mode, "added": add, "changed": change, "deleted": del},
+		})
+	})
+	return p, err
+}
+
+func (s SyncService) importPlan(ctx context.Context, want model.Snapshot, mode string, prune bool) (model.Snapshot, plan.Plan, error) {
+	cur, err := s.current(ctx, want)
+	if err != nil {
+		return cur, plan.Plan{}, err
+	}
+	p, err := plan.Import(cur, want, mode, prune)
+	return cur, p, app.Invalid(err)
+}
+
+// current проверяет want и читает текущее состояние.
+func (s SyncService) current(ctx context.Context, want model.Snapshot) (model.Snapshot, error) {
+	if err := validateSnapshot(want); err != nil {
+		return model.Snapshot{}, err
+	}
+	return s.Current(ctx)
+}
+
+// Current — текущее состояние namespace-а: defaults, правила и зоны.
+func (s SyncService) Current(ctx context.Context) (model.Snapshot, error) {
+	def, err := s.Defaults.Get(ctx)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	rules, err := s.Rules.List(ctx, false)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	zs, err := s.Zones.Set(ctx)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	return model.Snapshot{Defaults: def, Rules: rules, Zones: &zs}, nil
+}
+
+// Sync применяет план в одной транзакции; каждое изменение пишется в аудит
+// в той же транзакции.
+func (s SyncService) Sync(ctx context.Context, actor string, want model.Snapshot) (plan.Plan, error) {
+	return s.SyncWith(ctx, actor, want, nil)
+}
+
+// SyncWith — Sync, в транзакции которого перед commit выполняется inTx
+// (например, отметка change request-а применённым).
+func (s SyncService) SyncWith(ctx context.Context, actor string, want model.Snapshot, inTx func(tx *sql.Tx) error) (plan.Plan, error) {
+	p, err := s.Plan(ctx, want)
+	if err != nil || (p.Empty() && inTx == nil) {
+		return p, err
+	}
+	return p, s.apply(ctx, actor, p, inTx)
+}
+
+// apply выполняет план в одной транзакции; каждое изменение пишется в аудит
+// в той же транзакции, затем правила встают в порядок p.Order, inTx — перед
+// commit.
+func (s SyncService) apply(ctx context.Context, actor string, p plan.Plan, inTx func(tx *sql.Tx) error) (err error) {
+	tx, err := s.Rules.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if d := p.Defaults; d != nil {
+		if err = s.Defaults.SetTx(ctx, tx, d.New); err != nil {
+			return err
+		}
+		if err = s.Audit.LogChangeTx(ctx, tx, actor, "set_defaults", "defaults:1", d.Old, d.New); err != nil {
+			return err
+		}
+	}
+	if z := p.Zones; z != nil {
+		if err = s.Zones.ReplaceTx(ctx, tx, z.New); err != nil {
+			return err
+		}
+		if err = s.Audit.LogChangeTx(ctx, tx, actor, "set_zones", "zones", z.Old, z.New); err != nil {
+			return err
+		}
+	}
+	for i := range p.Rules {
+		c := &p.Rules[i]
+		switch c.Op {
+		case plan.Delete:
+			if err = s.Rules.DeleteTx(ctx, tx, c.Old.ID); err == nil {
+				err = s.Audit.LogChangeTx(ctx, tx, actor, "del_rule", fmt.Sprintf("rule:%d", c.Old.ID), c.Old, nil)
+			}
+		case plan.Update:
+			if err = s.Rules.UpdateTx(ctx, tx, *c.New); err == nil {
+				err = s.Audit.LogTx(ctx, tx, actor, "update_rule", fmt.Sprintf("rule:%d", c.New.ID), ruleChange(*c))
+			}
+		case plan.Create:
+			if c.New.ID, err = s.Rules.CreateTx(ctx, tx, c.New); err == nil {
+				err = s.Audit.LogChangeTx(ctx, tx, actor, "add_rule", fmt.Sprintf("rule:%d", c.New.ID), nil, c.New)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(p.Order) > 0 {
+		ids := make([]int64, len(p.Order))
+		for i, r := range p.Order {
+			ids[i] = r.ID
+		}
+		if err = s.Rules.ReorderTx(ctx, tx, ids); err != nil {
+			return err
+		}
+	}
+	if inTx != nil {
+		if err = inTx(tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ruleChange — details записи update_rule; перемещение правила в самих
+// правилах не видно, поэтому номера позиций идут в info.
+func ruleChange(c plan.Change) Change {
+	ch := Change{Before: c.Old, After: c.New}
+	for _, f := range c.Fields {
+		if f.Name == "position" {
+			ch.Info = map[string]string{"position": f.Old + " -> " + f.New}
+		}
+	}
+	return ch
+}
+
+func validateSnapshot(snap model.Snapshot) error {
+	if d := snap.Defaults; d != (model.Defaults{}) {
+		if !model.ValidPolicy(d.InputPolicy) || !model.ValidPolicy(d.ForwardPolicy) || !model.ValidPolicy(d.OutputPolicy) {
+			return app.Invalidf("defaults: invalid policy")
+		}
+	}
+	if snap.Zones != nil {
+		if err := model.ValidateZones(*snap.Zones); err != nil {
+			return app.Invalidf("zones: %v", err)
+		}
+	}
+	for i := range snap.Rules {
+		if err := model.ValidateRule(&snap.Rules[i]); err != nil {
+			return fmt.Errorf("rule #%d: %w", i+1, err)
+		}
+	}
+	return nil
+}
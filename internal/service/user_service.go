@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"netfence/internal/app"
+	"netfence/internal/model"
+	"netfence/internal/repo"
+)
+
+// UserService — пользователи netfence и их роли. Имя — имя пользователя
+// ОС (см. internal/ident), в том числе "uid:1234" для UID без имени.
+type UserService struct {
+	Repo  repo.UserRepo
+	Audit AuditService
+}
+
+var userName = regexp.MustCompile(`^([a-z_][a-z0-9_.-]{0,31}|uid:[0-9]{1,10})$`)
+
+func (s UserService) List(ctx context.Context) ([]model.User, error) {
+	return s.Repo.List(ctx)
+}
+
+func (s UserService) Add(ctx context.Context, actor string, u model.User) error {
+	if !userName.MatchString(u.Name) {
+		return app.Invalidf("invalid user name %q (an OS user name or uid:<n>)", u.Name)
+	}
+	if err := validRole(u.Role); err != nil {
+		return err
+	}
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		cur, err := s.Repo.GetTx(ctx, tx, u.Name)
+		if err != nil {
+			return err
+		}
+		if cur != nil {
+			return app.Invalidf("user %s already exists (role %s)", u.Name, cur.Role)
+		}
+		if err := s.Repo.AddTx(ctx, tx, u); err != nil {
+			return err
+		}
+		return s.Audit.LogChangeTx(ctx, tx, actor, "add_user", "user:"+u.Name, nil, u)
+	})
+}
+
+// SetRole меняет роль; последний admin не может её потерять.
+func (s UserService) SetRole(ctx context.Context, actor, name, role string) error {
+	if err := validRole(role); err != nil {
+		return err
+	}
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		cur, err := s.get(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		if cur.Role == role {
+			return nil
+		}
+		if err := s.keepAdmin(ctx, tx, *cur); err != nil {
+			return err
+		}
+		if err := s.Repo.SetRoleTx(ctx, tx, name, role); err != nil {
+			return err
+		}
+		return s.Audit.LogChangeTx(ctx, tx, actor, "set_role", "user:"+name, cur, model.User{Name: name, Role: role})
+	})
+}
+
+// Delete удаляет пользователя; последнего admin-а удалить нельзя.
+func (s UserService) Delete(ctx context.Context, actor, name string) error {
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		cur, err := s.get(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		if err := s.keepAdmin(ctx, tx, *cur); err != nil {
+			return err
+		}
+		if err := s.Repo.DeleteTx(ctx, tx, name); err != nil {
+			return err
+		}
+		return s.Audit.LogChangeTx(ctx, tx, actor, "del_user", "user:"+name, cur, nil)
+	})
+}
+
+func (s UserService) get(ctx context.Context, tx *sql.Tx, name string) (*model.User, error) {
+	u, err := s.Repo.GetTx(ctx, tx, name)
+	if err == nil && u == nil {
+		err = fmt.Errorf("user %s: %w", name, repo.ErrNoUser)
+	}
+	return u, err
+}
+
+// keepAdmin — u перестаёт быть admin-ом: без него admin-ов не останется?
+func (s UserService) keepAdmin(ctx context.Context, tx *sql.Tx, u model.User) error {
+	if u.Role != model.RoleAdmin {
+		return nil
+	}
+	n, err := s.Repo.AdminsTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if n <= 1 {
+		return app.Invalidf("%s is the last admin: add another admin first", u.Name)
+	}
+	return nil
+}
+
+func (s UserService) tx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.Repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func validRole(role string) error {
+	if !model.ValidRole(role) {
+		return app.Invalidf("invalid role %q (%s)", role, strings.Join(model.Roles, "|"))
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"netfence/internal/app"
+	"netfence/internal/model"
+	"netfence/internal/repo"
+	"netfence/internal/util"
+)
+
+type ZoneService struct {
+	Repo  repo.ZoneRepo
+	Audit AuditService
+}
+
+func (s ZoneService) List(ctx context.Context) ([]model.Zone, []model.ZonePolicy, error) {
+	zs, err := s.Repo.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ps, err := s.Repo.Policies(ctx)
+	return zs, ps, err
+}
+
+// Save создаёт зону (или меняет её политику) и добавляет в неё интерфейсы.
+func (s ZoneService) Save(ctx context.Context, actor string, z model.Zone) error {
+	if !model.ValidZoneName(z.Name) {
+		return app.Invalidf("invalid zone name %q (use [a-z][a-z0-9_]*, up to 32 chars)", z.Name)
+	}
+	if !model.ValidPolicy(z.InputPolicy) {
+		return app.Invalidf("invalid policy")
+	}
+	for _, ifname := range z.Ifaces {
+		if err := util.IfExists(ifname); err != nil {
+			return err
+		}
+	}
+	before := s.zone(ctx, z.Name)
+	if err := s.Repo.Save(ctx, z.Name, z.InputPolicy); err != nil {
+		return err
+	}
+	for _, ifname := range z.Ifaces {
+		if err := s.Repo.AddIface(ctx, z.Name, ifname); err != nil {
+			return err
+		}
+	}
+	_ = s.Audit.LogChange(ctx, actor, "save_zone", "zone:"+z.Name, before, s.zone(ctx, z.Name))
+	return nil
+}
+
+// zone — текущее состояние зоны для аудита; nil, если её нет.
+func (s ZoneService) zone(ctx context.Context, name string) *model.Zone {
+	z, err := s.Repo.Get(ctx, name)
+	if err != nil {
+		return nil
+	}
+	return &z
+}
+
+func (s ZoneService) Delete(ctx context.Context, actor, name string) error {
+	before, err := s.Repo.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := s.Repo.Delete(ctx, name); err != nil {
+		return err
+	}
+	_ = s.Audit.LogChange(ctx, actor, "del_zone", "zone:"+name, before, nil)
+	return nil
+}
+
+func (s ZoneService) AddIface(ctx context.Context, actor, zone, iface string) error {
+	before, err := s.Repo.Get(ctx, zone)
+	if err != nil {
+		return err
+	}
+	if err := util.IfExists(iface); err != nil {
+		return err
+	}
+	if err := s.Repo.AddIface(ctx, zone, iface); err != nil {
+		return err
+	}
+	_ = s.Audit.LogChange(ctx, actor, "zone_add_if", "zone:"+zone, before, s.zone(ctx, zone))
+	return nil
+}
+
+func (s ZoneService) DelIface(ctx context.Context, actor, zone, iface string) error {
+	before := s.zone(ctx, zone)
+	if err := s.Repo.DelIface(ctx, zone, iface); err != nil {
+		return err
+	}
+	_ = s.Audit.LogChange(ctx, actor, "zone_del_if", "zone:"+zone, before, s.zone(ctx, zone))
+	return nil
+}
+
+// SetPolicy задаёт ячейку forward-матрицы; action "none" удаляет её.
+func (s ZoneService) SetPolicy(ctx context.Context, actor string, p model.ZonePolicy) error {
+	for _, z := range []string{p.From, p.To} {
+		if _, err := s.Repo.Get(ctx, z); err != nil {
+			return err
+		}
+	}
+	action := p.Action
+	if action == "none" {
+		action = ""
+	} else if !model.ValidPolicy(action) {
+		return app.Invalidf("invalid policy")
+	}
+	var before, after *model.ZonePolicy
+	if ps, err := s.Repo.Policies(ctx); err == nil {
+		for i := range ps {
+			if ps[i].From == p.From && ps[i].To == p.To {
+				before = &ps[i]
+			}
+		}
+	}
+	if err := s.Repo.SetPolicy(ctx, p.From, p.To, action); err != nil {
+		return err
+	}
+	if action != "" {
+		after = &model.ZonePolicy{From: p.From, To: p.To, Action: action}
+	}
+	_ = s.Audit.LogChange(ctx, actor, "zone_forward", fmt.Sprintf("zone:%s->%s", p.From, p.To), before, after)
+	return nil
+}
@@ -0,0 +1,203 @@
+// Package simulate вычисляет вердикт для пакета по ruleset-у без обращения
+// к ядру. Семантика повторяет render: порядок правил, пропуск выключенных,
+// ports/icmp только для своих протоколов, dst-имена через адреса множеств,
+// затем диспетчеризация по зонам и политика цепочки.
+package simulate
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"netfence/internal/model"
+	"netfence/internal/render"
+)
+
+// Packet — первый пакет нового соединения (established/related в ruleset
+// и так всегда разрешены).
+type Packet struct {
+	InIf     string
+	OutIf    string
+	Src      net.IP // nil — не задан, правила с src не совпадут
+	Dst      net.IP
+	Proto    string // tcp|udp|icmp
+	DPort    int
+	ICMPType int // -1 — не задан
+}
+
+type Verdict struct {
+	Chain  string
+	Action string
+	RuleID int64  // 0 — решило не правило
+	Zone   string // зона, если решила её политика
+	Reason string
+}
+
+func (v Verdict) String() string { return fmt.Sprintf("%s (%s, chain %s)", v.Action, v.Reason, v.Chain) }
+
+// ParsePacket проверяет и собирает Packet из строковых аргументов CLI/TUI.
+func ParsePacket(inIf, outIf, src, dst, proto string, dport, icmpType int) (Packet, error) {
+	p := Packet{InIf: inIf, OutIf: outIf, Proto: strings.ToLower(proto), DPort: dport, ICMPType: icmpType}
+	switch p.Proto {
+	case "tcp", "udp":
+		if dport < 0 || dport > 65535 {
+			return p, fmt.Errorf("invalid dport %d", dport)
+		}
+	case "icmp":
+		if icmpType > 255 {
+			return p, fmt.Errorf("invalid icmp type %d", icmpType)
+		}
+	default:
+		return p, fmt.Errorf("invalid proto %q (use tcp|udp|icmp)", proto)
+	}
+	for _, a := range []struct {
+		s   string
+		dst *net.IP
+	}{{src, &p.Src}, {dst, &p.Dst}} {
+		if a.s == "" {
+			continue
+		}
+		ip := net.ParseIP(a.s).To4()
+		if ip == nil {
+			return p, fmt.Errorf("invalid IPv4 address %q", a.s)
+		}
+		*a.dst = ip
+	}
+	return p, nil
+}
+
+// ChainFor — цепочка, в которую попадёт пакет: есть оба интерфейса — forward,
+// только выходной — output, иначе input.
+func ChainFor(p Packet) string {
+	switch {
+	case p.InIf != "" && p.OutIf != "":
+		return "forward"
+	case p.OutIf != "":
+		return "output"
+	default:
+		return "input"
+	}
+}
+
+// Evaluate проходит цепочку chain ruleset-а rs (ссылки iface: уже раскрыты).
+func Evaluate(rs render.Ruleset, chain string, p Packet) Verdict {
+	for _, r := range rs.Rules {
+		if r.Chain != chain || !r.Enabled {
+			continue
+		}
+		if Matches(r, p, rs.FQDNs) {
+			return Verdict{Chain: chain, Action: r.Action, RuleID: r.ID, Reason: fmt.Sprintf("rule %d", r.ID)}
+		}
+	}
+	if v, ok := zoneVerdict(rs, chain, p); ok {
+		return v
+	}
+	policy := map[string]string{
+		"input": rs.Defaults.InputPolicy, "forward": rs.Defaults.ForwardPolicy, "output": rs.Defaults.OutputPolicy,
+	}[chain]
+	return Verdict{Chain: chain, Action: policy, Reason: "default " + chain + " policy"}
+}
+
+// Matches — совпадает ли пакет с правилом так, как его отрендерит render.
+func Matches(r model.Rule, p Packet, fqdns map[string][]string) bool {
+	if r.InIf != nil && *r.InIf != p.InIf {
+		return false
+	}
+	if r.OutIf != nil && *r.OutIf != p.OutIf {
+		return false
+	}
+	if r.Proto != "all" && r.Proto != p.Proto {
+		return false
+	}
+	if len(r.Ports) > 0 && (r.Proto == "tcp" || r.Proto == "udp") && !containsInt(r.Ports, p.DPort) {
+		return false
+	}
+	if len(r.SrcCIDRs) > 0 && !anyContains(r.SrcCIDRs, p.Src) {
+		return false
+	}
+	if len(r.DstCIDRs) > 0 && !dstMatches(r.DstCIDRs, p.Dst, fqdns) {
+		return false
+	}
+	if len(r.ICMPTypes) > 0 && r.Proto == "icmp" && !containsInt(r.ICMPTypes, p.ICMPType) {
+		return false
+	}
+	return true
+}
+
+func zoneVerdict(rs render.Ruleset, chain string, p Packet) (Verdict, bool) {
+	zoneOf := func(ifname string) (model.Zone, bool) {
+		for _, z := range rs.Zones {
+			for _, x := range z.Ifaces {
+				if x == ifname {
+					return z, true
+				}
+			}
+		}
+		return model.Zone{}, false
+	}
+	switch chain {
+	case "input":
+		if z, ok := zoneOf(p.InIf); ok && p.InIf != "" {
+			return Verdict{Chain: chain, Action: z.InputPolicy, Zone: z.Name, Reason: "zone " + z.Name + " input policy"}, true
+		}
+	case "forward":
+		from, ok := zoneOf(p.InIf)
+		if !ok {
+			return Verdict{}, false
+		}
+		to, ok := zoneOf(p.OutIf)
+		if !ok {
+			return Verdict{}, false
+		}
+		for _, zp := range rs.ZonePolicies {
+			if zp.From == from.Name && zp.To == to.Name {
+				return Verdict{Chain: chain, Action: zp.Action, Zone: from.Name,
+					Reason: "zone forward policy " + from.Name + " -> " + to.Name}, true
+			}
+		}
+	}
+	return Verdict{}, false
+}
+
+func dstMatches(dsts []string, ip net.IP, fqdns map[string][]string) bool {
+	for _, d := range dsts {
+		if name, ok := model.ParseFQDN(d); ok {
+			if anyContains(fqdns[name], ip) {
+				return true
+			}
+			continue
+		}
+		if anyContains([]string{d}, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyContains: адрес совпадает с любым из CIDR-ов (или голых адресов).
+func anyContains(cidrs []string, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			if x := net.ParseIP(c); x != nil && x.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		if _, n, err := net.ParseCIDR(c); err == nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(xs []int, v int) bool {
+	for _, x := range xs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,299 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"netfence/internal/model"
+)
+
+// Version — текущая версия формата снапшота.
+//
+//	1 — без поля version, ключи — имена полей Go в нижнем регистре
+//	    (inputpolicy, srccidrs, icmptypes, ...);
+//	2 — version: 2, snake_case (input_policy, src, icmp_types, ...);
+//	3 — необязательная секция zones (зоны и forward-матрица).
+const Version = 3
+
+// upgrades[v] переводит документ версии v в версию v+1.
+var upgrades = map[int]func(root *yaml.Node){
+	1: upgradeV1,
+	2: func(*yaml.Node) {}, // только новая секция
+}
+
+// Допустимые ключи текущей версии.
+var (
+	topFields      = []string{"version", "defaults", "rules", "zones"}
+	zonesFields    = []string{"list", "forward"}
+	zoneFields     = []string{"name", "ifaces", "input_policy"}
+	forwardFields  = []string{"from", "to", "action"}
+	defaultsFields = []string{"input_policy", "forward_policy", "output_policy", "log_prefix"}
+	ruleFields     = []string{"id", "chain", "proto", "action", "in_if", "out_if", "ports", "src", "dst",
+		"icmp_types", "comment", "enabled", "profile", "origin"}
+)
+
+// ruleKeys: поле FieldError -> ключ YAML (для позиции ошибки).
+var ruleKeys = map[string]string{"chain": "chain", "proto": "proto", "action": "action", "port": "ports",
+	"src_cidr": "src", "dst_cidr": "dst", "icmp_type": "icmp_types", "in_if": "in_if", "out_if": "out_if"}
+
+// Marshal — YAML снапшота в текущей версии.
+func Marshal(s model.Snapshot) ([]byte, error) {
+	s.Version = Version
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Write сохраняет снапшот в файл (export).
+func Write(path string, s model.Snapshot) error {
+	b, err := Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// Unmarshal разбирает снапшот, сохранённый в БД (ревизии, apply, change
+// request-ы). Старые версии поднимаются до текущей.
+func Unmarshal(b []byte) (model.Snapshot, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return model.Snapshot{}, err
+	}
+	return Decode("snapshot", &doc)
+}
+
+// Decode проверяет документ и разбирает его в снапшот текущей версии:
+// версия (нет поля — 1), апгрейд, неизвестные поля, типы и те же проверки
+// правил и политик, что при add-rule/set-defaults. Ошибки содержат
+// file:line:column.
+func Decode(file string, doc *yaml.Node) (model.Snapshot, error) {
+	var snap model.Snapshot
+	root := doc
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return snap, fmt.Errorf("%s: empty snapshot", file)
+		}
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return snap, posErr(file, root, errors.New("snapshot must be a mapping"))
+	}
+
+	v, err := version(file, root)
+	if err != nil {
+		return snap, err
+	}
+	for ; v < Version; v++ {
+		upgrades[v](root)
+	}
+	setVersion(root)
+
+	if err := checkFields(file, root, topFields, "snapshot"); err != nil {
+		return snap, err
+	}
+	snap.Version = Version
+	if n := field(root, "defaults"); n != nil {
+		if err := checkFields(file, n, defaultsFields, "defaults"); err != nil {
+			return snap, err
+		}
+		if err := n.Decode(&snap.Defaults); err != nil {
+			return snap, posErr(file, n, fmt.Errorf("defaults: %w", yamlErr(err)))
+		}
+		if d := snap.Defaults; d != (model.Defaults{}) {
+			for _, p := range []struct{ name, v string }{{"input_policy", d.InputPolicy}, {"forward_policy", d.ForwardPolicy}, {"output_policy", d.OutputPolicy}} {
+				if !model.ValidPolicy(p.v) {
+					at := n
+					if f := field(n, p.name); f != nil {
+						at = f
+					}
+					return snap, posErr(file, at, fmt.Errorf("defaults: invalid %s %q (accept|drop)", p.name, p.v))
+				}
+			}
+		}
+	}
+	if n := field(root, "rules"); n != nil && !isNull(n) {
+		if n.Kind != yaml.SequenceNode {
+			return snap, posErr(file, n, errors.New("rules must be a list"))
+		}
+		for i, rn := range n.Content {
+			what := "rule #" + strconv.Itoa(i+1)
+			if err := checkFields(file, rn, ruleFields, what); err != nil {
+				return snap, err
+			}
+			var r model.Rule
+			if err := rn.Decode(&r); err != nil {
+				return snap, posErr(file, rn, fmt.Errorf("%s: %w", what, yamlErr(err)))
+			}
+			if err := model.ValidateRule(&r); err != nil {
+				at := rn
+				var fe *model.FieldError
+				if errors.As(err, &fe) {
+					if f := field(rn, ruleKeys[fe.Field]); f != nil {
+						at = f
+					}
+				}
+				return snap, posErr(file, at, fmt.Errorf("%s: %w", what, err))
+			}
+			snap.Rules = append(snap.Rules, r)
+		}
+	}
+	if n := field(root, "zones"); n != nil && !isNull(n) {
+		if snap.Zones, err = decodeZones(file, n); err != nil {
+			return snap, err
+		}
+	}
+	return snap, nil
+}
+
+// decodeZones: секция zones — списки list (зоны) и forward (матрица).
+func decodeZones(file string, n *yaml.Node) (*model.ZoneSet, error) {
+	if err := checkFields(file, n, zonesFields, "zones"); err != nil {
+		return nil, err
+	}
+	zs := &model.ZoneSet{}
+	for _, sec := range []struct {
+		key    string
+		fields []string
+	}{{"list", zoneFields}, {"forward", forwardFields}} {
+		l := field(n, sec.key)
+		if l == nil || isNull(l) {
+			continue
+		}
+		if l.Kind != yaml.SequenceNode {
+			return nil, posErr(file, l, fmt.Errorf("zones: %s must be a list", sec.key))
+		}
+		for i, e := range l.Content {
+			what := "zones: " + sec.key + " #" + strconv.Itoa(i+1)
+			if err := checkFields(file, e, sec.fields, what); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := n.Decode(zs); err != nil {
+		return nil, posErr(file, n, fmt.Errorf("zones: %w", yamlErr(err)))
+	}
+	if err := model.ValidateZones(*zs); err != nil {
+		return nil, posErr(file, n, fmt.Errorf("zones: %w", err))
+	}
+	return zs, nil
+}
+
+func version(file string, root *yaml.Node) (int, error) {
+	n := field(root, "version")
+	if n == nil {
+		return 1, nil
+	}
+	v, err := strconv.Atoi(n.Value)
+	if err != nil || n.Kind != yaml.ScalarNode || v < 1 {
+		return 0, posErr(file, n, fmt.Errorf("bad snapshot version %q", n.Value))
+	}
+	if v > Version {
+		return 0, posErr(file, n, fmt.Errorf("snapshot version %d is newer than supported %d; upgrade netfence", v, Version))
+	}
+	return v, nil
+}
+
+func setVersion(root *yaml.Node) {
+	if n := field(root, "version"); n != nil {
+		n.Value, n.Tag = strconv.Itoa(Version), "!!int"
+		return
+	}
+	root.Content = append([]*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"},
+		{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(Version)},
+	}, root.Content...)
+}
+
+// upgradeV1: ключи полей Go в нижнем регистре -> snake_case.
+func upgradeV1(root *yaml.Node) {
+	if d := field(root, "defaults"); d != nil {
+		rename(d, map[string]string{"inputpolicy": "input_policy", "forwardpolicy": "forward_policy",
+			"outputpolicy": "output_policy", "logprefix": "log_prefix"})
+	}
+	if rs := field(root, "rules"); rs != nil && rs.Kind == yaml.SequenceNode {
+		for _, r := range rs.Content {
+			rename(r, map[string]string{"inif": "in_if", "outif": "out_if", "srccidrs": "src",
+				"dstcidrs": "dst", "icmptypes": "icmp_types"})
+		}
+	}
+}
+
+func rename(m *yaml.Node, names map[string]string) {
+	if m.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if to, ok := names[m.Content[i].Value]; ok {
+			m.Content[i].Value = to
+		}
+	}
+}
+
+// checkFields: m — словарь только из ключей allowed, без повторов.
+func checkFields(file string, m *yaml.Node, allowed []string, what string) error {
+	if isNull(m) {
+		return nil
+	}
+	if m.Kind != yaml.MappingNode {
+		return posErr(file, m, fmt.Errorf("%s must be a mapping", what))
+	}
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k := m.Content[i]
+		ok := false
+		for _, a := range allowed {
+			if k.Value == a {
+				ok = true
+				break
+			}
+		}
+		switch {
+		case !ok:
+			return posErr(file, k, fmt.Errorf("%s: unknown field %q", what, k.Value))
+		case seen[k.Value]:
+			return posErr(file, k, fmt.Errorf("%s: duplicate field %q", what, k.Value))
+		}
+		seen[k.Value] = true
+	}
+	return nil
+}
+
+func field(m *yaml.Node, name string) *yaml.Node {
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == name {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func isNull(n *yaml.Node) bool { return n.Kind == yaml.ScalarNode && n.Tag == "!!null" }
+
+func posErr(file string, n *yaml.Node, err error) error {
+	return fmt.Errorf("%s:%d:%d: %w", file, n.Line, n.Column, err)
+}
+
+// yamlErr убирает из ошибки yaml.v3 префикс "yaml: unmarshal errors:".
+func yamlErr(err error) error {
+	var te *yaml.TypeError
+	if errors.As(err, &te) && len(te.Errors) > 0 {
+		return errors.New(te.Errors[0])
+	}
+	return err
+}
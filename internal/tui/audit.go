@@ -0,0 +1,194 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"netfence/internal/repo"
+	"netfence/internal/service"
+	"netfence/internal/util"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// auditLimit — сколько последних записей показывает экран.
+const auditLimit = 200
+
+func (m *modelT) initAuditTable() {
+	cols := []table.Column{{Title: "ID", Width: 6}, {Title: "TIME", Width: 19}, {Title: "ACTOR", Width: 16}, {Title: "ACTION", Width: 16}, {Title: "OBJECT", Width: 14}}
+	m.auditTbl = table.New(table.WithColumns(cols), table.WithFocused(true), table.WithHeight(10))
+	m.auditFilter = repo.AuditFilter{Limit: auditLimit}
+}
+
+func (m *modelT) auditService() service.AuditService {
+	return service.AuditService{Repo: repo.AuditRepo{DB: m.db}}
+}
+
+func (m *modelT) reloadAudit() error {
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+	es, err := m.auditService().List(ctx, m.auditFilter)
+	if err != nil {
+		return err
+	}
+	m.auditEntries = es
+	rows := make([]table.Row, 0, len(es))
+	for _, e := range es {
+		rows = append(rows, table.Row{fmt.Sprint(e.ID), e.TS.Local().Format("2006-01-02 15:04:05"), e.Who(), e.Action, e.Object})
+	}
+	m.auditTbl.SetRows(rows)
+	m.auditTbl.SetCursor(0)
+	return nil
+}
+
+func (m *modelT) auditButtons() []string {
+	return []string{"[Filter]", "[Clear Filter]", "[Back]"}
+}
+
+func (m *modelT) updateAudit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "tab":
+		m.auditBtnIx = (m.auditBtnIx + 1) % len(m.auditButtons())
+	case "left":
+		if m.auditBtnIx > 0 {
+			m.auditBtnIx--
+		}
+	case "right":
+		if m.auditBtnIx < len(m.auditButtons())-1 {
+			m.auditBtnIx++
+		}
+	case "enter":
+		m.errMsg, m.okMsg = "", ""
+		switch m.auditBtnIx {
+		case 0:
+			m.startAuditFilterForm()
+			m.scr = scrAuditFilter
+		case 1:
+			m.auditFilter = repo.AuditFilter{Limit: auditLimit}
+			if err := m.reloadAudit(); err != nil {
+				m.errMsg = err.Error()
+			}
+		case 2:
+			m.scr = scrMain
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.auditTbl, cmd = m.auditTbl.Update(msg)
+	return m, cmd
+}
+
+func (m *modelT) startAuditFilterForm() {
+	f := m.auditFilter
+	var since, until string
+	if !f.Since.IsZero() {
+		since = f.Since.Local().Format("2006-01-02 15:04:05")
+	}
+	if !f.Until.IsZero() {
+		until = f.Until.Local().Format("2006-01-02 15:04:05")
+	}
+	m.auditForm = newForm("Filter Audit Log", []string{
+		"actor(Optional)", "action(Optional)", "object: rule:42 or rule(Optional)", "since: 2006-01-02 or 24h(Optional)", "until(Optional)",
+	}, []string{f.Actor, f.Action, f.Object, since, until})
+}
+
+func (m *modelT) updateAuditFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	submit, cancel, cmd := m.auditForm.update(msg)
+	if cancel {
+		m.scr = scrAudit
+		return m, nil
+	}
+	if submit {
+		m.errMsg, m.okMsg = "", ""
+		v := m.auditForm.values()
+		f := repo.AuditFilter{Actor: v[0], Action: v[1], Object: v[2], Limit: auditLimit}
+		now := time.Now()
+		var err error
+		if v[3] != "" {
+			if f.Since, err = util.ParseTime(v[3], now); err != nil {
+				m.errMsg = "since: " + err.Error()
+				return m, nil
+			}
+		}
+		if v[4] != "" {
+			if f.Until, err = util.ParseTime(v[4], now); err != nil {
+				m.errMsg = "until: " + err.Error()
+				return m, nil
+			}
+		}
+		m.auditFilter = f
+		if err := m.reloadAudit(); err != nil {
+			m.errMsg = err.Error()
+		}
+		m.auditBtnIx = 0
+		m.scr = scrAudit
+	}
+	return m, cmd
+}
+
+// auditFilterLine — активные условия фильтра одной строкой.
+func (m *modelT) auditFilterLine() string {
+	f := m.auditFilter
+	var parts []string
+	for _, p := range []struct{ name, v string }{{"actor", f.Actor}, {"action", f.Action}, {"object", f.Object}} {
+		if p.v != "" {
+			parts = append(parts, p.name+"="+p.v)
+		}
+	}
+	if !f.Since.IsZero() {
+		parts = append(parts, "since="+f.Since.Local().Format("2006-01-02 15:04:05"))
+	}
+	if !f.Until.IsZero() {
+		parts = append(parts, "until="+f.Until.Local().Format("2006-01-02 15:04:05"))
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("last %d entries", auditLimit)
+	}
+	return "filter: " + strings.Join(parts, " ")
+}
+
+// auditDetails — выбранная запись: diff полей до/после, для старых записей
+// без before/after — details как JSON с отступами.
+func (m *modelT) auditDetails() string {
+	i := m.auditTbl.Cursor()
+	if i < 0 || i >= len(m.auditEntries) {
+		return "  no entries"
+	}
+	e := m.auditEntries[i]
+	head := fmt.Sprintf("#%d %s %s by %s\n", e.ID, e.Action, e.Object, e.Who())
+	if fields, info, ok := service.ChangeDiff(e.Details); ok {
+		var b strings.Builder
+		b.WriteString(head)
+		for _, f := range fields {
+			if f.Op != service.FieldSame {
+				b.WriteString("  " + f.String() + "\n")
+			}
+		}
+		if info != nil {
+			j, _ := json.Marshal(info)
+			b.WriteString("  info: " + string(j) + "\n")
+		}
+		return strings.TrimSuffix(b.String(), "\n")
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte(e.Details), "  ", "  "); err != nil {
+		b.Reset()
+		b.WriteString(e.Details)
+	}
+	return head + "  " + b.String()
+}
+
+func (m *modelT) viewAudit() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Audit Log") + "  " + tabInactive.Render(m.auditFilterLine()) + "\n")
+	b.WriteString(m.auditTbl.View() + "\n\n")
+	b.WriteString(fieldTitle.Render("DETAILS") + "\n")
+	b.WriteString(m.auditDetails() + "\n")
+	b.WriteString("\n" + btnRow(m.auditButtons(), m.auditBtnIx))
+	return b.String()
+}
@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// form — набор текстовых полей с кнопками [OK]/[Cancel]; навигация как в
+// мастере добавления правила (up/down/tab по полям, enter — дальше/нажать).
+type form struct {
+	title  string
+	inputs []*textinput.Model
+	step   int
+	onBtns bool
+	btnIx  int
+	btns   []string
+}
+
+func newForm(title string, labels, values []string) *form {
+	f := &form{title: title, btns: []string{"[OK]", "[Cancel]"}}
+	for i, lab := range labels {
+		ti := textinput.New()
+		ti.Placeholder = lab
+		if i < len(values) {
+			ti.SetValue(values[i])
+		}
+		f.inputs = append(f.inputs, &ti)
+	}
+	f.focus()
+	return f
+}
+
+func (f *form) focus() {
+	for i := range f.inputs {
+		if !f.onBtns && i == f.step {
+			f.inputs[i].Focus()
+		} else {
+			f.inputs[i].Blur()
+		}
+	}
+}
+
+func (f *form) values() []string {
+	out := make([]string, len(f.inputs))
+	for i, in := range f.inputs {
+		out[i] = strings.TrimSpace(in.Value())
+	}
+	return out
+}
+
+func (f *form) next() {
+	if f.step < len(f.inputs)-1 {
+		f.step++
+	} else {
+		f.onBtns = true
+		f.btnIx = 0
+	}
+	f.focus()
+}
+
+// update: submit — нажата [OK], cancel — [Cancel].
+func (f *form) update(msg tea.KeyMsg) (submit, cancel bool, cmd tea.Cmd) {
+	switch msg.String() {
+	case "up", "lefttab":
+		if f.onBtns {
+			f.onBtns = false
+			f.step = len(f.inputs) - 1
+		} else if f.step > 0 {
+			f.step--
+		}
+		f.focus()
+		return false, false, nil
+	case "down":
+		if !f.onBtns {
+			f.next()
+		}
+		return false, false, nil
+	case "tab":
+		if f.onBtns {
+			f.btnIx = (f.btnIx + 1) % len(f.btns)
+		} else {
+			f.next()
+		}
+		return false, false, nil
+	case "left":
+		if f.onBtns && f.btnIx > 0 {
+			f.btnIx--
+			return false, false, nil
+		}
+	case "right":
+		if f.onBtns && f.btnIx < len(f.btns)-1 {
+			f.btnIx++
+			return false, false, nil
+		}
+	case "enter":
+		if !f.onBtns {
+			f.next()
+			return false, false, nil
+		}
+		return f.btnIx == 0, f.btnIx == 1, nil
+	}
+	if !f.onBtns && f.step < len(f.inputs) {
+		*f.inputs[f.step], cmd = f.inputs[f.step].Update(msg)
+	}
+	return false, false, cmd
+}
+
+func (f *form) view() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render(f.title) + "\n\n")
+	for i, in := range f.inputs {
+		prefix := "  "
+		if !f.onBtns && i == f.step {
+			prefix = "> "
+		}
+		b.WriteString(prefix + in.View() + "\n")
+	}
+	sel := -1
+	if f.onBtns {
+		sel = f.btnIx
+	}
+	b.WriteString("\n" + btnRow(f.btns, sel))
+	return b.String()
+}
@@ -0,0 +1,213 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"netfence/internal/app"
+	"netfence/internal/githist"
+	"netfence/internal/plan"
+	"netfence/internal/repo"
+	"netfence/internal/service"
+	"netfence/internal/util"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (m *modelT) initHistoryTable() {
+	cols := []table.Column{{Title: "REV", Width: 5}, {Title: "TIME", Width: 19}, {Title: "ACTOR", Width: 10}, {Title: "MESSAGE", Width: 40}}
+	m.histTbl = table.New(table.WithColumns(cols), table.WithFocused(true), table.WithHeight(10))
+}
+
+func (m *modelT) revisionService() service.RevisionService {
+	return service.RevisionService{
+		Repo: repo.RevisionRepo{DB: m.db, NS: m.netns},
+		Sync: service.SyncService{
+			Rules:    repo.RuleRepo{DB: m.db, NS: m.netns},
+			Defaults: repo.DefaultsRepo{DB: m.db, NS: m.netns},
+			Zones:    repo.ZoneRepo{DB: m.db, NS: m.netns},
+			Audit:    service.AuditService{Repo: repo.AuditRepo{DB: m.db}},
+		},
+		Export: m.gitHistory(),
+	}
+}
+
+// gitHistory: выгрузка снимков в git (config git.dir); nil — выключено.
+func (m *modelT) gitHistory() service.HistoryExporter {
+	if m.cfg.Git.Dir == "" {
+		return nil
+	}
+	return githist.Repo{Dir: m.cfg.Git.Dir, NS: m.netns}
+}
+
+// recordRevision — ревизия после изменения из TUI; ошибка истории показывается,
+// но изменение не отменяет.
+func (m *modelT) recordRevision(ctx context.Context, msg string) {
+	if _, err := m.revisionService().Record(ctx, m.actor, msg); err != nil {
+		m.errMsg = "revision not recorded: " + err.Error()
+	}
+}
+
+func (m *modelT) reloadHistory() error {
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+	revs, err := m.revisionService().History(ctx, 0)
+	if err != nil {
+		return err
+	}
+	m.revisions = revs
+	rows := make([]table.Row, 0, len(revs))
+	for _, v := range revs {
+		rows = append(rows, table.Row{fmt.Sprint(v.Rev), v.TS.Local().Format("2006-01-02 15:04:05"), v.Actor, v.Message})
+	}
+	m.histTbl.SetRows(rows)
+	return nil
+}
+
+func (m *modelT) historyButtons() []string {
+	return []string{"[Show Changes]", "[Rollback]", "[Back]"}
+}
+
+func (m *modelT) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "tab":
+		m.histBtnIx = (m.histBtnIx + 1) % len(m.historyButtons())
+	case "left":
+		if m.histBtnIx > 0 {
+			m.histBtnIx--
+		}
+	case "right":
+		if m.histBtnIx < len(m.historyButtons())-1 {
+			m.histBtnIx++
+		}
+	case "enter":
+		m.errMsg, m.okMsg = "", ""
+		i := m.histTbl.Cursor()
+		if m.histBtnIx == 2 {
+			m.scr = scrMain
+			return m, nil
+		}
+		if i < 0 || i >= len(m.revisions) {
+			return m, nil
+		}
+		rev := m.revisions[i].Rev
+		switch m.histBtnIx {
+		case 0:
+			if err := m.showRevisionChanges(rev); err != nil {
+				m.errMsg = err.Error()
+			}
+		case 1:
+			if err := m.rollback(rev); err != nil {
+				m.errMsg = err.Error()
+			} else {
+				_ = m.reloadAll()
+				_ = m.reloadHistory()
+				m.histView = ""
+			}
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.histTbl, cmd = m.histTbl.Update(msg)
+	return m, cmd
+}
+
+// showRevisionChanges — что изменила ревизия rev относительно предыдущей.
+func (m *modelT) showRevisionChanges(rev int64) error {
+	if rev == 1 {
+		m.histView = "revision 1 is the first recorded state"
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+	p, err := m.revisionService().Diff(ctx, rev-1, rev)
+	if err != nil {
+		return err
+	}
+	m.histView = fmt.Sprintf("changes in revision %d:\n%s", rev, strings.Join(planLines(p), "\n"))
+	return nil
+}
+
+func (m *modelT) rollback(rev int64) error {
+	lock, err := util.Acquire(lockFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	defer cancel()
+	role, err := repo.UserRepo{DB: m.db}.RoleOf(ctx, m.actor)
+	if err != nil {
+		return err
+	}
+	if err := app.Require(m.actor, role, "admin"); err != nil {
+		return err
+	}
+	if err := m.directChange(); err != nil {
+		return err
+	}
+	p, _, err := m.revisionService().Rollback(ctx, m.actor, rev)
+	if err != nil {
+		return err
+	}
+	if p.Empty() {
+		m.okMsg = fmt.Sprintf("already at revision %d", rev)
+	} else {
+		m.okMsg = fmt.Sprintf("rolled back to revision %d (apply to load it)", rev)
+	}
+	if v, err := m.revisionService().Get(ctx, rev); err == nil && v.Snapshot.Zones == nil {
+		m.okMsg += "; zones left as they are (revision predates zone history)"
+	}
+	return nil
+}
+
+func planLines(p plan.Plan) []string {
+	if p.Empty() {
+		return []string{"  no changes"}
+	}
+	var out []string
+	if d := p.Defaults; d != nil {
+		for _, f := range d.Fields {
+			out = append(out, fmt.Sprintf("  ~ defaults %s: %s -> %s", f.Name, f.Old, f.New))
+		}
+	}
+	if z := p.Zones; z != nil {
+		for _, f := range z.Fields {
+			switch {
+			case f.Old == "":
+				out = append(out, fmt.Sprintf("  + %s: %s", f.Name, f.New))
+			case f.New == "":
+				out = append(out, fmt.Sprintf("  - %s: %s", f.Name, f.Old))
+			default:
+				out = append(out, fmt.Sprintf("  ~ %s: %s -> %s", f.Name, f.Old, f.New))
+			}
+		}
+	}
+	for _, c := range p.Rules {
+		switch c.Op {
+		case plan.Create:
+			out = append(out, "  + "+c.Key)
+		case plan.Update:
+			for _, f := range c.Fields {
+				out = append(out, fmt.Sprintf("  ~ rule %d %s: %q -> %q", c.Old.ID, f.Name, f.Old, f.New))
+			}
+		case plan.Delete:
+			out = append(out, fmt.Sprintf("  - rule %d: %s", c.Old.ID, c.Key))
+		}
+	}
+	return out
+}
+
+func (m *modelT) viewHistory() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Revision History") + "\n")
+	b.WriteString(m.histTbl.View() + "\n")
+	if m.histView != "" {
+		b.WriteString("\n" + m.histView + "\n")
+	}
+	b.WriteString("\n" + btnRow(m.historyButtons(), m.histBtnIx))
+	return b.String()
+}
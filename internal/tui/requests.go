@@ -0,0 +1,177 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"netfence/internal/app"
+	"netfence/internal/model"
+	"netfence/internal/repo"
+	"netfence/internal/service"
+	"netfence/internal/util"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (m *modelT) initRequestsTable() {
+	cols := []table.Column{{Title: "ID", Width: 5}, {Title: "STATUS", Width: 9}, {Title: "AUTHOR", Width: 16}, {Title: "REVIEWER", Width: 16}, {Title: "REASON", Width: 30}}
+	m.reqTbl = table.New(table.WithColumns(cols), table.WithFocused(true), table.WithHeight(8))
+}
+
+func (m *modelT) requestService() service.RequestService {
+	return service.RequestService{Repo: repo.RequestRepo{DB: m.db, NS: m.netns}, Revisions: m.revisionService()}
+}
+
+func (m *modelT) reloadRequests() error {
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+	crs, err := m.requestService().List(ctx, "")
+	if err != nil {
+		return err
+	}
+	m.requests = crs
+	rows := make([]table.Row, 0, len(crs))
+	for _, cr := range crs {
+		reviewer := cr.ReviewerWho()
+		if reviewer == "" {
+			reviewer = "-"
+		}
+		rows = append(rows, table.Row{fmt.Sprint(cr.ID), cr.Status, cr.AuthorWho(), reviewer, cr.Reason})
+	}
+	m.reqTbl.SetRows(rows)
+	m.inbox = 0
+	for _, cr := range crs {
+		if cr.Status == model.RequestPending {
+			m.inbox++
+		}
+	}
+	return nil
+}
+
+func (m *modelT) requestsButtons() []string {
+	return []string{"[Show]", "[Approve]", "[Reject]", "[Apply]", "[Back]"}
+}
+
+func (m *modelT) updateRequests(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "tab":
+		m.reqBtnIx = (m.reqBtnIx + 1) % len(m.requestsButtons())
+	case "left":
+		if m.reqBtnIx > 0 {
+			m.reqBtnIx--
+		}
+	case "right":
+		if m.reqBtnIx < len(m.requestsButtons())-1 {
+			m.reqBtnIx++
+		}
+	case "enter":
+		m.errMsg, m.okMsg = "", ""
+		if m.reqBtnIx == 4 {
+			m.scr = scrMain
+			return m, nil
+		}
+		i := m.reqTbl.Cursor()
+		if i < 0 || i >= len(m.requests) {
+			return m, nil
+		}
+		id := m.requests[i].ID
+		var err error
+		switch m.reqBtnIx {
+		case 0:
+			err = m.showRequest(id)
+		case 1:
+			err = m.reviewRequest(id, true)
+		case 2:
+			err = m.reviewRequest(id, false)
+		case 3:
+			err = m.applyRequest(id)
+		}
+		if err != nil {
+			m.errMsg = err.Error()
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.reqTbl, cmd = m.reqTbl.Update(msg)
+	return m, cmd
+}
+
+func (m *modelT) showRequest(id int64) error {
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+	cr, p, err := m.requestService().Show(ctx, id)
+	if err != nil {
+		return err
+	}
+	m.reqView = fmt.Sprintf("request %d by %s (base revision %d): %s\nchanges against the current ruleset:\n%s",
+		cr.ID, cr.AuthorWho(), cr.BaseRevision, cr.Reason, strings.Join(planLines(p), "\n"))
+	return nil
+}
+
+// requestMutate: lock + RBAC + операция + перечитывание списка.
+func (m *modelT) requestMutate(roles []string, fn func(ctx context.Context, svc service.RequestService) error) error {
+	lock, err := util.Acquire(lockFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	defer cancel()
+	role, err := repo.UserRepo{DB: m.db}.RoleOf(ctx, m.actor)
+	if err != nil {
+		return err
+	}
+	if err := app.Require(m.actor, role, roles...); err != nil {
+		return err
+	}
+	if err := fn(ctx, m.requestService()); err != nil {
+		return err
+	}
+	m.reqView = ""
+	return m.reloadRequests()
+}
+
+func (m *modelT) reviewRequest(id int64, approve bool) error {
+	return m.requestMutate([]string{"admin"}, func(ctx context.Context, svc service.RequestService) error {
+		if approve {
+			if err := svc.Approve(ctx, m.actor, id, ""); err != nil {
+				return err
+			}
+			m.okMsg = fmt.Sprintf("request %d approved", id)
+			return nil
+		}
+		if err := svc.Reject(ctx, m.actor, id, ""); err != nil {
+			return err
+		}
+		m.okMsg = fmt.Sprintf("request %d rejected", id)
+		return nil
+	})
+}
+
+func (m *modelT) applyRequest(id int64) error {
+	err := m.requestMutate([]string{"operator", "admin"}, func(ctx context.Context, svc service.RequestService) error {
+		if _, err := svc.Apply(ctx, m.actor, id); err != nil {
+			return err
+		}
+		m.okMsg = fmt.Sprintf("request %d written to the DB (apply to load it)", id)
+		return nil
+	})
+	if err == nil {
+		_ = m.reloadAll()
+	}
+	return err
+}
+
+func (m *modelT) viewRequests() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Change Requests") + "\n")
+	b.WriteString(m.reqTbl.View() + "\n")
+	if m.reqView != "" {
+		b.WriteString("\n" + m.reqView + "\n")
+	}
+	b.WriteString("\n" + btnRow(m.requestsButtons(), m.reqBtnIx))
+	return b.String()
+}
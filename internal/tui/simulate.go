@@ -0,0 +1,73 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"netfence/internal/simulate"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (m *modelT) startSimulateForm() {
+	m.simForm = newForm("Test Packet", []string{
+		"in-if(Optional)", "out-if(Optional)", "src IPv4(Optional)", "dst IPv4(Optional)", "proto(tcp/udp/icmp)", "dport / icmp type",
+	}, []string{"", "", "", "", "tcp", ""})
+	m.simForm.btns = []string{"[Test]", "[Back]"}
+	m.simResult = ""
+}
+
+func (m *modelT) updateSimulate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	submit, cancel, cmd := m.simForm.update(msg)
+	if cancel {
+		m.scr = scrMain
+		return m, nil
+	}
+	if submit {
+		m.errMsg, m.okMsg = "", ""
+		if v, err := m.simulate(m.simForm.values()); err != nil {
+			m.errMsg = err.Error()
+			m.simResult = ""
+		} else {
+			m.simResult = v
+		}
+	}
+	return m, cmd
+}
+
+func (m *modelT) simulate(v []string) (string, error) {
+	port, icmp := 0, -1
+	if v[5] != "" {
+		n, err := strconv.Atoi(v[5])
+		if err != nil {
+			return "", fmt.Errorf("bad port/icmp type: %v", err)
+		}
+		if v[4] == "icmp" {
+			icmp = n
+		} else {
+			port = n
+		}
+	}
+	pkt, err := simulate.ParsePacket(v[0], v[1], v[2], v[3], orDefault(v[4], "tcp"), port, icmp)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+	rs, err := m.applyService().Ruleset(ctx)
+	if err != nil {
+		return "", err
+	}
+	verdict := simulate.Evaluate(rs, simulate.ChainFor(pkt), pkt)
+	return verdict.String(), nil
+}
+
+func (m *modelT) viewSimulate() string {
+	s := m.simForm.view() + "\n"
+	if m.simResult != "" {
+		s += "\n" + fieldTitle.Render("VERDICT") + " " + okStyle.Render(m.simResult) + "\n"
+	}
+	return s
+}
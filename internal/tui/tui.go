@@ -7,9 +7,11 @@ import (
 	"strings"
 	"time"
 
+	"netfence/internal/app"
+	"netfence/internal/config"
 	"netfence/internal/model"
-	"netfence/internal/render"
 	"netfence/internal/repo"
+	"netfence/internal/resolve"
 	"netfence/internal/service"
 	"netfence/internal/util"
 
@@ -45,16 +47,32 @@ const (
 	scrDefaults
 	scrPreview
 	scrAddRule
+	scrZones
+	scrZoneForm
+	scrSimulate
+	scrHistory
+	scrRequests
+	scrAudit
+	scrAuditFilter
+	scrUsers
+	scrUserForm
 )
 
 type modelT struct {
 	ctx    context.Context
 	dbPath string
 	actor  string
+	netns  string
+	cfg    config.Config
 	db     *sql.DB
 
+	pending int  // неприменённые изменения (для заголовка)
+	never   bool // apply ещё не выполнялся
+	inbox   int  // change request-ы, ждущие одобрения
+
 	width, height int
 	errMsg, okMsg string
+	ruleWarn      string // предупреждение анализатора о последнем добавленном правиле
 
 	scr screen
 
@@ -85,6 +103,44 @@ type modelT struct {
 	addFocus  string // "fields" | "buttons"
 	addBtnIx  int
 
+	// Zones
+	zonesTbl     table.Model
+	zones        []model.Zone
+	zonePolicies []model.ZonePolicy
+	zoneBtnIx    int
+	zoneForm     *form
+	zoneFormKind string // "add" | "forward"
+
+	// Test packet
+	simForm   *form
+	simResult string
+
+	// History
+	histTbl   table.Model
+	revisions []model.Revision
+	histBtnIx int
+	histView  string // изменения выбранной ревизии
+
+	// Change requests
+	reqTbl   table.Model
+	requests []model.ChangeRequest
+	reqBtnIx int
+	reqView  string
+
+	// Audit log
+	auditTbl     table.Model
+	auditEntries []model.AuditEntry
+	auditFilter  repo.AuditFilter
+	auditBtnIx   int
+	auditForm    *form
+
+	// Users
+	usersTbl     table.Model
+	users        []model.User
+	userBtnIx    int
+	userForm     *form
+	userFormKind string // "add" | "role"
+
 	quit bool
 }
 
@@ -110,7 +166,7 @@ CREATE TABLE IF NOT EXISTS rule_icmp_type(rule_id INT, itype INT);
 	return nil
 }
 
-func New(ctx context.Context, dbPath, actor string) (*modelT, error) {
+func New(ctx context.Context, dbPath, actor, netns string, cfg config.Config) (*modelT, error) {
 	db, err := openDB(dbPath)
 	if err != nil {
 		return nil, err
@@ -119,12 +175,19 @@ func New(ctx context.Context, dbPath, actor string) (*modelT, error) {
 		ctx:    ctx,
 		dbPath: dbPath,
 		actor:  actor,
+		netns:  netns,
+		cfg:    cfg,
 		db:     db,
 		scr:    scrMain,
 	}
 	m.initMain()
 	m.initRulesTable()
 	m.initDefaults()
+	m.initZonesTable()
+	m.initHistoryTable()
+	m.initRequestsTable()
+	m.initAuditTable()
+	m.initUsersTable()
 	if err := m.reloadAll(); err != nil {
 		m.errMsg = err.Error()
 	}
@@ -133,8 +196,16 @@ func New(ctx context.Context, dbPath, actor string) (*modelT, error) {
 
 func (m *modelT) Close() { _ = m.db.Close() }
 
+// directChange: при approval.required изменения — только через change request-ы.
+func (m *modelT) directChange() error {
+	if m.cfg.Approval.Required {
+		return service.ErrApprovalRequired
+	}
+	return nil
+}
+
 func (m *modelT) initMain() {
-	m.mainItems = []string{"Manage Rules", "Set Default Policies", "Preview & Apply", "Quit"}
+	m.mainItems = []string{"Manage Rules", "Set Default Policies", "Preview & Apply", "Zones", "Test Packet", "History", "Requests", "Audit Log", "Users", "Quit"}
 	m.mainCursor = 0
 }
 
@@ -142,7 +213,8 @@ func (m *modelT) initRulesTable() {
 	cols := []table.Column{
 		{Title: "ID", Width: 4}, {Title: "CHAIN", Width: 8}, {Title: "PROTO", Width: 6}, {Title: "ACTION", Width: 7},
 		{Title: "EN", Width: 3}, {Title: "IN_IF", Width: 9}, {Title: "OUT_IF", Width: 9}, {Title: "PORTS", Width: 12},
-		{Title: "SRC", Width: 16}, {Title: "DST", Width: 16}, {Title: "ICMP", Width: 8}, {Title: "COMMENT", Width: 18},
+		{Title: "SRC", Width: 16}, {Title: "DST", Width: 16}, {Title: "ICMP", Width: 8}, {Title: "PROFILE", Width: 9}, {Title: "ORIGIN", Width: 12},
+		{Title: "COMMENT", Width: 18},
 	}
 	t := table.New(table.WithColumns(cols), table.WithFocused(true), table.WithHeight(12))
 	m.rulesTbl = t
@@ -167,14 +239,14 @@ func (m *modelT) reloadAll() error {
 		return err
 	}
 
-	def, err := repo.DefaultsRepo{DB: m.db}.Get(ctx)
+	def, err := repo.DefaultsRepo{DB: m.db, NS: m.netns}.Get(ctx)
 	if err != nil {
 		return err
 	}
 	m.policies = def
 	m.logInput.SetValue(def.LogPrefix)
 
-	rs, err := repo.RuleRepo{DB: m.db}.List(ctx, false)
+	rs, err := repo.RuleRepo{DB: m.db, NS: m.netns}.List(ctx, false)
 	if err != nil {
 		return err
 	}
@@ -184,11 +256,17 @@ func (m *modelT) reloadAll() error {
 			fmt.Sprint(r.ID), r.Chain, r.Proto, r.Action,
 			boolFlag(r.Enabled), ptrOrDash(r.InIf), ptrOrDash(r.OutIf),
 			intSlice(r.Ports), strSlice(r.SrcCIDRs), strSlice(r.DstCIDRs),
-			intSlice(r.ICMPTypes), ptrOrDash(r.Comment),
+			intSlice(r.ICMPTypes), ptrOrDash(r.Profile), ptrOrDash(r.Origin), ptrOrDash(r.Comment),
 		})
 	}
 	m.rulesTbl.SetRows(rows)
-	return nil
+
+	st, err := m.applyService().Status(ctx)
+	if err != nil {
+		return err
+	}
+	m.pending, m.never = st.PendingCount(), !st.Ever
+	return m.reloadRequests()
 }
 
 func boolFlag(b bool) string {
@@ -261,6 +339,24 @@ func (m *modelT) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updatePreview(msg)
 		case scrAddRule:
 			return m.updateAddRule(msg)
+		case scrZones:
+			return m.updateZones(msg)
+		case scrZoneForm:
+			return m.updateZoneForm(msg)
+		case scrSimulate:
+			return m.updateSimulate(msg)
+		case scrHistory:
+			return m.updateHistory(msg)
+		case scrRequests:
+			return m.updateRequests(msg)
+		case scrAudit:
+			return m.updateAudit(msg)
+		case scrAuditFilter:
+			return m.updateAuditFilter(msg)
+		case scrUsers:
+			return m.updateUsers(msg)
+		case scrUserForm:
+			return m.updateUserForm(msg)
 		}
 	}
 	return m, nil
@@ -291,6 +387,40 @@ func (m *modelT) updateMain(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.scr = scrPreview
 			}
 		case 3:
+			if err := m.reloadZones(); err != nil {
+				m.errMsg = err.Error()
+			} else {
+				m.scr = scrZones
+			}
+		case 4:
+			m.startSimulateForm()
+			m.scr = scrSimulate
+		case 5:
+			if err := m.reloadHistory(); err != nil {
+				m.errMsg = err.Error()
+			}
+			m.histBtnIx, m.histView = 0, ""
+			m.scr = scrHistory
+		case 6:
+			if err := m.reloadRequests(); err != nil {
+				m.errMsg = err.Error()
+			}
+			m.reqBtnIx, m.reqView = 0, ""
+			m.scr = scrRequests
+		case 7:
+			if err := m.reloadAudit(); err != nil {
+				m.errMsg = err.Error()
+			}
+			m.auditBtnIx = 0
+			m.scr = scrAudit
+		case 8:
+			if err := m.reloadUsers(); err != nil {
+				m.errMsg = err.Error()
+			} else {
+				m.errMsg, m.userBtnIx = "", 0
+				m.scr = scrUsers
+			}
+		case 9:
 			m.quit = true
 			return m, tea.Quit
 		}
@@ -465,6 +595,7 @@ func (m *modelT) updatePreview(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.errMsg = err.Error()
 			} else {
 				m.okMsg = "applied"
+				_ = m.reloadAll()
 				m.scr = scrMain // ← возврат в меню после успешного apply
 			}
 		} else { // Back
@@ -479,9 +610,10 @@ func (m *modelT) updatePreview(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m *modelT) preparePreviewTables() error {
 	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
 	defer cancel()
-	def, _ := repo.DefaultsRepo{DB: m.db}.Get(ctx)
-	rules, _ := repo.RuleRepo{DB: m.db}.List(ctx, true)
-	content := buildPreviewTables(def, rules)
+	def, _ := repo.DefaultsRepo{DB: m.db, NS: m.netns}.Get(ctx)
+	rules, _ := repo.RuleRepo{DB: m.db, NS: m.netns}.List(ctx, true)
+	names, _ := m.fqdnService().Entries(ctx)
+	content := buildPreviewTables(def, rules, names)
 	m.preview = viewport.Model{Width: m.width - 4, Height: m.height - 12}
 	m.preview.SetContent(content)
 	m.previewBtns = []string{"[Apply]", "[Back]"}
@@ -499,8 +631,8 @@ func (m *modelT) startAddRuleWizard() {
 		"in-if(Optional)",
 		"out-if(Optional)",
 		"ports(csv)",
-		"src(csv CIDR)",
-		"dst(csv CIDR)",
+		"src(csv CIDR / iface:<if>:network)",
+		"dst(csv CIDR / DNS name / iface:<if>:address)",
 		"comment(Optional)",
 	}
 	m.addInputs = make([]*textinput.Model, len(labels))
@@ -624,6 +756,9 @@ func (m *modelT) updateAddRule(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 					m.errMsg = err.Error()
 				} else {
 					m.okMsg = "rule added"
+					if m.ruleWarn != "" {
+						m.okMsg += " — warning: " + m.ruleWarn
+					}
 					_ = m.reloadAll()
 					m.scr = scrRules
 				}
@@ -650,11 +785,27 @@ func (m *modelT) View() string {
 	var b strings.Builder
 
 	b.WriteString(titleStyle.Render("NetFence"))
+	if m.netns != "" {
+		b.WriteString(tabInactive.Render("netns: " + m.netns))
+	}
+	if m.never {
+		b.WriteString(tabInactive.Render("never applied"))
+	} else if m.pending > 0 {
+		b.WriteString(tabInactive.Render(fmt.Sprintf("%d pending", m.pending)))
+	}
+	if m.inbox > 0 {
+		b.WriteString(tabInactive.Render(fmt.Sprintf("%d to review", m.inbox)))
+	}
 	b.WriteString("   ")
 	b.WriteString(tab(scrMain, m.scr, "Main"))
 	b.WriteString(tab(scrRules, m.scr, "Rules"))
 	b.WriteString(tab(scrDefaults, m.scr, "Defaults"))
 	b.WriteString(tab(scrPreview, m.scr, "Preview"))
+	b.WriteString(tab(scrZones, m.scr, "Zones"))
+	b.WriteString(tab(scrHistory, m.scr, "History"))
+	b.WriteString(tab(scrRequests, m.scr, "Requests"))
+	b.WriteString(tab(scrAudit, m.scr, "Audit"))
+	b.WriteString(tab(scrUsers, m.scr, "Users"))
 	b.WriteString("\n")
 
 	if m.errMsg != "" {
@@ -719,6 +870,33 @@ func (m *modelT) View() string {
 			sel = m.addBtnIx
 		}
 		b.WriteString("\n" + btnRow([]string{"[Save]", "[Cancel]"}, sel))
+
+	case scrZones:
+		b.WriteString(m.viewZones())
+
+	case scrZoneForm:
+		b.WriteString(m.zoneForm.view())
+
+	case scrSimulate:
+		b.WriteString(m.viewSimulate())
+
+	case scrHistory:
+		b.WriteString(m.viewHistory())
+
+	case scrRequests:
+		b.WriteString(m.viewRequests())
+
+	case scrAudit:
+		b.WriteString(m.viewAudit())
+
+	case scrAuditFilter:
+		b.WriteString(m.auditForm.view())
+
+	case scrUsers:
+		b.WriteString(m.viewUsers())
+
+	case scrUserForm:
+		b.WriteString(m.userForm.view())
 	}
 
 	b.WriteString("\n")
@@ -761,7 +939,7 @@ func renderDefaultLine(name, val string, focused bool) string {
 
 // ---------- preview tables ----------
 
-func buildPreviewTables(def model.Defaults, rules []model.Rule) string {
+func buildPreviewTables(def model.Defaults, rules []model.Rule, names []model.FQDNEntry) string {
 	var b strings.Builder
 	b.WriteString("DEFAULT POLICIES\n")
 	b.WriteString(fmt.Sprintf("%-8s %-8s %-8s %-s\n", "INPUT", "FORWARD", "OUTPUT", "LOG_PREFIX"))
@@ -797,6 +975,22 @@ func buildPreviewTables(def model.Defaults, rules []model.Rule) string {
 		b.WriteString(fmt.Sprintf("%-4d %-8s %-6s %-7s %-2s %-9s %-9s %-12s %-16s %-16s %-8s %-18s\n",
 			x.ID, x.Chain, x.Proto, x.Action, en, inIf, outIf, ports, src, dst, icmp, comment))
 	}
+
+	if len(names) > 0 {
+		b.WriteString("\nRESOLVED NAMES\n")
+		b.WriteString(fmt.Sprintf("%-31s %-34s %-9s %-s\n", "NAME", "ADDRS", "EXPIRES", "ERROR"))
+		for _, e := range names {
+			exp := "-"
+			if !e.ExpiresAt.IsZero() {
+				exp = time.Until(e.ExpiresAt).Round(time.Second).String()
+			}
+			errS := "-"
+			if e.Error != "" {
+				errS = e.Error
+			}
+			b.WriteString(fmt.Sprintf("%-31s %-34s %-9s %-s\n", e.Name, strSlice(e.Addrs), exp, errS))
+		}
+	}
 	return b.String()
 }
 
@@ -814,18 +1008,32 @@ func (m *modelT) apply() error {
 	if err != nil {
 		return err
 	}
-	if role != "admin" && role != "operator" {
-		return fmt.Errorf("rbac: need operator or admin, got %s", role)
+	if err := app.Require(m.actor, role, "operator", "admin"); err != nil {
+		return err
 	}
-	def, _ := repo.DefaultsRepo{DB: m.db}.Get(ctx)
-	rules, _ := repo.RuleRepo{DB: m.db}.List(ctx, true)
-	script := render.Render(def, rules)
-	_, stderr, err := (util.ShellRunner{}).Run("nft", []byte(script), "-f", "-")
-	if err != nil {
-		return fmt.Errorf("nft failed: %v\n%s", err, stderr)
+	return m.applyService().Apply(ctx, m.actor)
+}
+
+func (m *modelT) applyService() service.ApplyService {
+	return service.ApplyService{
+		Rules:     repo.RuleRepo{DB: m.db, NS: m.netns},
+		Defaults:  repo.DefaultsRepo{DB: m.db, NS: m.netns},
+		Zones:     repo.ZoneRepo{DB: m.db, NS: m.netns},
+		FQDN:      m.fqdnService(),
+		Audit:     service.AuditService{Repo: repo.AuditRepo{DB: m.db}},
+		Runner:    util.NetnsRunner{NS: m.netns, Runner: util.ShellRunner{}},
+		Applied:   repo.AppliedRepo{DB: m.db, NS: m.netns},
+		Revisions: repo.RevisionRepo{DB: m.db, NS: m.netns},
+		Export:    m.gitHistory(),
+	}
+}
+
+func (m *modelT) fqdnService() service.FQDNService {
+	return service.FQDNService{
+		Repo:     repo.FQDNRepo{DB: m.db, NS: m.netns},
+		Rules:    repo.RuleRepo{DB: m.db, NS: m.netns},
+		Resolver: resolve.DNSResolver{},
 	}
-	_ = service.AuditService{Repo: repo.AuditRepo{DB: m.db}}.Log(ctx, m.actor, "apply", "ruleset", map[string]int{"rules": len(rules)})
-	return nil
 }
 
 func (m *modelT) deleteSelected() error {
@@ -850,11 +1058,18 @@ func (m *modelT) deleteSelected() error {
 	if err != nil {
 		return err
 	}
-	if role != "admin" && role != "operator" {
-		return fmt.Errorf("rbac: need operator or admin, got %s", role)
+	if err := app.Require(m.actor, role, "operator", "admin"); err != nil {
+		return err
 	}
-	svc := service.RulesService{Repo: repo.RuleRepo{DB: m.db}, Audit: service.AuditService{Repo: repo.AuditRepo{DB: m.db}}}
-	return svc.Delete(ctx, m.actor, id)
+	if err := m.directChange(); err != nil {
+		return err
+	}
+	svc := service.RulesService{Repo: repo.RuleRepo{DB: m.db, NS: m.netns}, Audit: service.AuditService{Repo: repo.AuditRepo{DB: m.db}}}
+	if err := svc.Delete(ctx, m.actor, id); err != nil {
+		return err
+	}
+	m.recordRevision(ctx, fmt.Sprintf("delete rule %d", id))
+	return nil
 }
 
 func (m *modelT) saveDefaults() error {
@@ -870,16 +1085,18 @@ func (m *modelT) saveDefaults() error {
 	if err != nil {
 		return err
 	}
-	if role != "admin" {
-		return fmt.Errorf("rbac: need admin, got %s", role)
+	if err := app.Require(m.actor, role, "admin"); err != nil {
+		return err
+	}
+	if err := m.directChange(); err != nil {
+		return err
 	}
 	m.policies.LogPrefix = m.logInput.Value()
-	ds := service.DefaultsService{Repo: repo.DefaultsRepo{DB: m.db}}
-	if err := ds.Set(ctx, m.policies); err != nil {
+	ds := service.DefaultsService{Repo: repo.DefaultsRepo{DB: m.db, NS: m.netns}, Audit: m.auditService()}
+	if err := ds.Set(ctx, m.actor, m.policies); err != nil {
 		return err
 	}
-	_ = service.AuditService{Repo: repo.AuditRepo{DB: m.db}}.Log(ctx, m.actor, "set_defaults", "defaults:1",
-		map[string]string{"input": m.policies.InputPolicy, "forward": m.policies.ForwardPolicy, "output": m.policies.OutputPolicy, "log": m.policies.LogPrefix})
+	m.recordRevision(ctx, "set defaults")
 	return nil
 }
 
@@ -950,14 +1167,26 @@ func (m *modelT) saveNewRule() error {
 	if err != nil {
 		return err
 	}
-	if role != "admin" && role != "operator" {
-		return fmt.Errorf("rbac: need operator or admin, got %s", role)
+	if err := app.Require(m.actor, role, "operator", "admin"); err != nil {
+		return err
+	}
+	if err := m.directChange(); err != nil {
+		return err
 	}
-	rr := repo.RuleRepo{DB: m.db}
+	rr := repo.RuleRepo{DB: m.db, NS: m.netns}
 	as := service.AuditService{Repo: repo.AuditRepo{DB: m.db}}
-	svc := service.RulesService{Repo: rr, Audit: as}
-	_, err = svc.Add(ctx, m.actor, r)
-	return err
+	m.ruleWarn = ""
+	svc := service.RulesService{Repo: rr, Audit: as, Warn: func(w string) {
+		if m.ruleWarn == "" {
+			m.ruleWarn = w
+		}
+	}}
+	id, err := svc.Add(ctx, m.actor, r)
+	if err != nil {
+		return err
+	}
+	m.recordRevision(ctx, fmt.Sprintf("add rule %d", id))
+	return nil
 }
 
 func inSet(v string, opts ...string) bool {
@@ -998,8 +1227,8 @@ func csvSplit(s string) []string {
 
 // ---------- Run ----------
 
-func Run(ctx context.Context, dbPath, actor string) error {
-	m, err := New(ctx, dbPath, actor)
+func Run(ctx context.Context, dbPath, actor, netns string, cfg config.Config) error {
+	m, err := New(ctx, dbPath, actor, netns, cfg)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,170 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"netfence/internal/app"
+	"netfence/internal/model"
+	"netfence/internal/repo"
+	"netfence/internal/service"
+	"netfence/internal/util"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (m *modelT) initUsersTable() {
+	cols := []table.Column{{Title: "USER", Width: 32}, {Title: "ROLE", Width: 10}}
+	m.usersTbl = table.New(table.WithColumns(cols), table.WithFocused(true), table.WithHeight(10))
+}
+
+func (m *modelT) userService() service.UserService {
+	return service.UserService{Repo: repo.UserRepo{DB: m.db}, Audit: m.auditService()}
+}
+
+// requireAdmin — экран пользователей только для admin-а.
+func (m *modelT) requireAdmin(ctx context.Context) error {
+	role, err := repo.UserRepo{DB: m.db}.RoleOf(ctx, m.actor)
+	if err != nil {
+		return err
+	}
+	return app.Require(m.actor, role, model.RoleAdmin)
+}
+
+func (m *modelT) reloadUsers() error {
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+	if err := m.requireAdmin(ctx); err != nil {
+		return err
+	}
+	us, err := m.userService().List(ctx)
+	if err != nil {
+		return err
+	}
+	m.users = us
+	rows := make([]table.Row, 0, len(us))
+	for _, u := range us {
+		rows = append(rows, table.Row{u.Name, u.Role})
+	}
+	m.usersTbl.SetRows(rows)
+	return nil
+}
+
+func (m *modelT) usersButtons() []string {
+	return []string{"[Add]", "[Set Role]", "[Delete]", "[Back]"}
+}
+
+func (m *modelT) updateUsers(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "tab":
+		m.userBtnIx = (m.userBtnIx + 1) % len(m.usersButtons())
+	case "left":
+		if m.userBtnIx > 0 {
+			m.userBtnIx--
+		}
+	case "right":
+		if m.userBtnIx < len(m.usersButtons())-1 {
+			m.userBtnIx++
+		}
+	case "enter":
+		m.errMsg, m.okMsg = "", ""
+		switch m.userBtnIx {
+		case 0:
+			m.userFormKind = "add"
+			m.userForm = newForm("Add User", []string{"OS user name", "role(admin/operator/viewer)"}, []string{"", model.RoleViewer})
+			m.scr = scrUserForm
+		case 1:
+			if u, ok := m.selectedUser(); ok {
+				m.userFormKind = "role"
+				m.userForm = newForm("Set Role of "+u.Name, []string{"role(admin/operator/viewer)"}, []string{u.Role})
+				m.scr = scrUserForm
+			}
+		case 2:
+			if u, ok := m.selectedUser(); ok {
+				m.userMutate("user "+u.Name+" deleted", func(ctx context.Context, svc service.UserService) error {
+					return svc.Delete(ctx, m.actor, u.Name)
+				})
+			}
+		case 3:
+			m.scr = scrMain
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.usersTbl, cmd = m.usersTbl.Update(msg)
+	return m, cmd
+}
+
+func (m *modelT) updateUserForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	submit, cancel, cmd := m.userForm.update(msg)
+	if cancel {
+		m.scr = scrUsers
+		return m, nil
+	}
+	if !submit {
+		return m, cmd
+	}
+	v := m.userForm.values()
+	switch m.userFormKind {
+	case "add":
+		u := model.User{Name: strings.TrimSpace(v[0]), Role: strings.ToLower(orDefault(v[1], model.RoleViewer))}
+		m.userMutate("user "+u.Name+" added", func(ctx context.Context, svc service.UserService) error {
+			return svc.Add(ctx, m.actor, u)
+		})
+	case "role":
+		if u, ok := m.selectedUser(); ok {
+			role := strings.ToLower(strings.TrimSpace(v[0]))
+			m.userMutate(u.Name+" is now "+role, func(ctx context.Context, svc service.UserService) error {
+				return svc.SetRole(ctx, m.actor, u.Name, role)
+			})
+		}
+	}
+	if m.errMsg == "" {
+		m.scr = scrUsers
+	}
+	return m, nil
+}
+
+func (m *modelT) selectedUser() (model.User, bool) {
+	i := m.usersTbl.Cursor()
+	if i < 0 || i >= len(m.users) {
+		return model.User{}, false
+	}
+	return m.users[i], true
+}
+
+// userMutate: lock + RBAC admin + операция + перечитывание списка. Если
+// admin снял роль с себя, список ему больше не доступен — возврат в меню.
+func (m *modelT) userMutate(ok string, fn func(ctx context.Context, svc service.UserService) error) {
+	err := func() error {
+		lock, err := util.Acquire(lockFile)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+		defer cancel()
+		if err := m.requireAdmin(ctx); err != nil {
+			return err
+		}
+		return fn(ctx, m.userService())
+	}()
+	if err != nil {
+		m.errMsg = err.Error()
+		return
+	}
+	m.okMsg = ok
+	if err := m.reloadUsers(); err != nil {
+		m.scr = scrMain
+	}
+}
+
+func (m *modelT) viewUsers() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Users") + "\n")
+	b.WriteString(m.usersTbl.View() + "\n\n")
+	b.WriteString(btnRow(m.usersButtons(), m.userBtnIx))
+	return b.String()
+}
@@ -0,0 +1,203 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"netfence/internal/app"
+	"netfence/internal/model"
+	"netfence/internal/repo"
+	"netfence/internal/service"
+	"netfence/internal/util"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (m *modelT) initZonesTable() {
+	cols := []table.Column{{Title: "ZONE", Width: 16}, {Title: "INPUT", Width: 8}, {Title: "IFACES", Width: 40}}
+	m.zonesTbl = table.New(table.WithColumns(cols), table.WithFocused(true), table.WithHeight(8))
+}
+
+func (m *modelT) zoneService() service.ZoneService {
+	return service.ZoneService{Repo: repo.ZoneRepo{DB: m.db, NS: m.netns}, Audit: service.AuditService{Repo: repo.AuditRepo{DB: m.db}}}
+}
+
+func (m *modelT) reloadZones() error {
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+	zs, ps, err := m.zoneService().List(ctx)
+	if err != nil {
+		return err
+	}
+	m.zones, m.zonePolicies = zs, ps
+	rows := make([]table.Row, 0, len(zs))
+	for _, z := range zs {
+		rows = append(rows, table.Row{z.Name, z.InputPolicy, strSlice(z.Ifaces)})
+	}
+	m.zonesTbl.SetRows(rows)
+	return nil
+}
+
+func (m *modelT) zonesButtons() []string {
+	return []string{"[Add]", "[Delete]", "[Toggle Input]", "[Forward]", "[Back]"}
+}
+
+func (m *modelT) updateZones(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "tab":
+		m.zoneBtnIx = (m.zoneBtnIx + 1) % len(m.zonesButtons())
+	case "left":
+		if m.zoneBtnIx > 0 {
+			m.zoneBtnIx--
+		}
+	case "right":
+		if m.zoneBtnIx < len(m.zonesButtons())-1 {
+			m.zoneBtnIx++
+		}
+	case "enter":
+		m.errMsg, m.okMsg = "", ""
+		switch m.zoneBtnIx {
+		case 0:
+			m.zoneFormKind = "add"
+			m.zoneForm = newForm("Add Zone", []string{"name", "interfaces(csv)", "input policy(accept/drop)"}, []string{"", "", "drop"})
+			m.scr = scrZoneForm
+		case 1:
+			if z, ok := m.selectedZone(); ok {
+				m.zoneMutate("zone "+z.Name+" deleted", func(ctx context.Context, svc service.ZoneService) error {
+					return svc.Delete(ctx, m.actor, z.Name)
+				})
+			}
+		case 2:
+			if z, ok := m.selectedZone(); ok {
+				z.InputPolicy = togglePolicy(z.InputPolicy)
+				z.Ifaces = nil
+				m.zoneMutate("input policy of "+z.Name+" set to "+z.InputPolicy, func(ctx context.Context, svc service.ZoneService) error {
+					return svc.Save(ctx, m.actor, z)
+				})
+			}
+		case 3:
+			from := ""
+			if z, ok := m.selectedZone(); ok {
+				from = z.Name
+			}
+			m.zoneFormKind = "forward"
+			m.zoneForm = newForm("Zone Forward Policy", []string{"from zone", "to zone", "action(accept/drop/none)"}, []string{from, "", "accept"})
+			m.scr = scrZoneForm
+		case 4:
+			m.scr = scrMain
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.zonesTbl, cmd = m.zonesTbl.Update(msg)
+	return m, cmd
+}
+
+func (m *modelT) updateZoneForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	submit, cancel, cmd := m.zoneForm.update(msg)
+	if cancel {
+		m.scr = scrZones
+		return m, nil
+	}
+	if !submit {
+		return m, cmd
+	}
+	v := m.zoneForm.values()
+	switch m.zoneFormKind {
+	case "add":
+		z := model.Zone{Name: strings.ToLower(v[0]), Ifaces: csvSplit(v[1]), InputPolicy: strings.ToLower(orDefault(v[2], "drop"))}
+		m.zoneMutate("zone "+z.Name+" saved", func(ctx context.Context, svc service.ZoneService) error {
+			return svc.Save(ctx, m.actor, z)
+		})
+	case "forward":
+		p := model.ZonePolicy{From: v[0], To: v[1], Action: strings.ToLower(orDefault(v[2], "none"))}
+		m.zoneMutate("forward policy "+p.From+"->"+p.To+" set to "+p.Action, func(ctx context.Context, svc service.ZoneService) error {
+			return svc.SetPolicy(ctx, m.actor, p)
+		})
+	}
+	if m.errMsg == "" {
+		m.scr = scrZones
+	}
+	return m, nil
+}
+
+func (m *modelT) selectedZone() (model.Zone, bool) {
+	i := m.zonesTbl.Cursor()
+	if i < 0 || i >= len(m.zones) {
+		return model.Zone{}, false
+	}
+	return m.zones[i], true
+}
+
+// zoneMutate: lock + RBAC admin + операция + ревизия (ok — её сообщение) +
+// перечитывание зон.
+func (m *modelT) zoneMutate(ok string, fn func(ctx context.Context, svc service.ZoneService) error) {
+	err := func() error {
+		lock, err := util.Acquire(lockFile)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+		defer cancel()
+		role, err := repo.UserRepo{DB: m.db}.RoleOf(ctx, m.actor)
+		if err != nil {
+			return err
+		}
+		if err := app.Require(m.actor, role, "admin"); err != nil {
+			return err
+		}
+		if err := m.directChange(); err != nil {
+			return err
+		}
+		if err := fn(ctx, m.zoneService()); err != nil {
+			return err
+		}
+		m.recordRevision(ctx, ok)
+		return nil
+	}()
+	if err != nil {
+		m.errMsg = err.Error()
+		return
+	}
+	m.okMsg = ok
+	if err := m.reloadZones(); err != nil {
+		m.errMsg = err.Error()
+	}
+}
+
+func (m *modelT) viewZones() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Zones") + "\n")
+	b.WriteString(m.zonesTbl.View() + "\n\n")
+	b.WriteString(fieldTitle.Render("FORWARD (from \\ to)") + "\n")
+	if len(m.zones) == 0 {
+		b.WriteString("(no zones)\n")
+	} else {
+		cell := map[string]string{}
+		for _, p := range m.zonePolicies {
+			cell[p.From+"->"+p.To] = p.Action
+		}
+		b.WriteString(fmt.Sprintf("%-16s", ""))
+		for _, to := range m.zones {
+			b.WriteString(fmt.Sprintf(" %-10s", to.Name))
+		}
+		b.WriteString("\n")
+		for _, from := range m.zones {
+			b.WriteString(fmt.Sprintf("%-16s", from.Name))
+			for _, to := range m.zones {
+				v := cell[from.Name+"->"+to.Name]
+				if v == "" {
+					v = "-"
+				}
+				b.WriteString(fmt.Sprintf(" %-10s", v))
+			}
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n" + btnRow(m.zonesButtons(), m.zoneBtnIx))
+	return b.String()
+}
@@ -1,15 +1,113 @@
 package util
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+
 	"github.com/vishvananda/netlink"
 )
 
 func IfExists(name string) error {
 	if name == "" { return nil }
-	_, err := netlink.LinkByName(name)
+	h, err := nlHandle()
+	if err != nil { return err }
+	defer h.Close()
+	_, err = h.LinkByName(name)
 	if err != nil {
 		return fmt.Errorf("interface %q not found: %w", name, err)
 	}
 	return nil
 }
+
+// IfAddrs возвращает IPv4-адреса интерфейса вместе с префиксом.
+func IfAddrs(name string) ([]*net.IPNet, error) {
+	h, err := nlHandle()
+	if err != nil {
+		return nil, err
+	}
+	defer h.Close()
+	link, err := h.LinkByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %q not found: %w", name, err)
+	}
+	addrs, err := h.AddrList(link, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, fmt.Errorf("addresses of %q: %w", name, err)
+	}
+	out := make([]*net.IPNet, 0, len(addrs))
+	for _, a := range addrs {
+		if a.IPNet != nil {
+			out = append(out, a.IPNet)
+		}
+	}
+	return out, nil
+}
+
+// ResolveIfaceRef превращает ссылку iface:<if>:address|network в список CIDR:
+// address — адреса интерфейса как /32, network — подсети этих адресов.
+func ResolveIfaceRef(iface, kind string) ([]string, error) {
+	nets, err := IfAddrs(iface)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, n := range nets {
+		var s string
+		switch kind {
+		case "address":
+			s = n.IP.String() + "/32"
+		case "network":
+			s = (&net.IPNet{IP: n.IP.Mask(n.Mask), Mask: n.Mask}).String()
+		default:
+			return nil, fmt.Errorf("unknown interface reference kind %q", kind)
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// WatchAddrs вызывает fn с именем интерфейса при каждом добавлении/удалении
+// адреса, пока не отменён ctx.
+func WatchAddrs(ctx context.Context, fn func(iface string)) error {
+	h, err := nlHandle()
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	opts := netlink.AddrSubscribeOptions{}
+	if curNetns != "" {
+		ns, err := openNetns(curNetns)
+		if err != nil {
+			return err
+		}
+		defer ns.Close()
+		opts.Namespace = &ns
+	}
+	ch := make(chan netlink.AddrUpdate, 16)
+	done := make(chan struct{})
+	defer close(done)
+	if err := netlink.AddrSubscribeWithOptions(ch, done, opts); err != nil {
+		return fmt.Errorf("netlink subscribe: %w", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case u, ok := <-ch:
+			if !ok {
+				return errors.New("netlink: address subscription closed")
+			}
+			link, err := h.LinkByIndex(u.LinkIndex)
+			if err != nil {
+				continue
+			}
+			fn(link.Attrs().Name)
+		}
+	}
+}
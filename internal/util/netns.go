@@ -0,0 +1,111 @@
+package util
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// целевой network namespace для netlink-хелперов; пусто — текущий
+var curNetns string
+
+// SetNetns задаёт namespace (имя из `ip netns` или путь к ns-файлу), в котором
+// работают IfExists, IfAddrs, ResolveIfaceRef и WatchAddrs.
+func SetNetns(ns string) { curNetns = ns }
+
+// NetnsPath: имя → /run/netns/<name>, путь — как есть.
+func NetnsPath(ns string) string {
+	if ns == "" || strings.Contains(ns, "/") {
+		return ns
+	}
+	return filepath.Join("/run/netns", ns)
+}
+
+// NetnsKey — ключ namespace в БД: имя для /run/netns/<name> и /var/run/netns/<name>,
+// иначе путь целиком. Пусто — namespace хоста.
+func NetnsKey(ns string) string {
+	p := filepath.Clean(NetnsPath(ns))
+	if ns == "" {
+		return ""
+	}
+	for _, dir := range []string{"/run/netns", "/var/run/netns"} {
+		if filepath.Dir(p) == dir {
+			return filepath.Base(p)
+		}
+	}
+	return p
+}
+
+func openNetns(ns string) (netns.NsHandle, error) {
+	h, err := netns.GetFromPath(NetnsPath(ns))
+	if err != nil {
+		return netns.None(), fmt.Errorf("netns %q: %w", ns, err)
+	}
+	return h, nil
+}
+
+// nlHandle — netlink-хендл в целевом namespace.
+func nlHandle() (*netlink.Handle, error) {
+	if curNetns == "" {
+		return netlink.NewHandle()
+	}
+	ns, err := openNetns(curNetns)
+	if err != nil {
+		return nil, err
+	}
+	defer ns.Close()
+	return netlink.NewHandleAt(ns)
+}
+
+// InNetns выполняет fn на OS-потоке, переключённом в namespace ns. Дочерние
+// процессы, запущенные из fn, наследуют этот namespace.
+func InNetns(ns string, fn func() error) error {
+	if ns == "" {
+		return fn()
+	}
+	target, err := openNetns(ns)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	runtime.LockOSThread()
+	orig, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer orig.Close()
+	if err := netns.Set(target); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("enter netns %q: %w", ns, err)
+	}
+	defer func() {
+		// поток с чужим namespace возвращать в пул нельзя: если вернуться
+		// не удалось, оставляем его залоченным — рантайм его завершит
+		if netns.Set(orig) == nil {
+			runtime.UnlockOSThread()
+		}
+	}()
+	return fn()
+}
+
+// NetnsRunner запускает команды внутри network namespace NS.
+type NetnsRunner struct {
+	NS     string
+	Runner Runner
+}
+
+func (r NetnsRunner) Run(name string, stdin []byte, args ...string) (string, string, error) {
+	var out, errs string
+	err := InNetns(r.NS, func() error {
+		var err error
+		out, errs, err = r.Runner.Run(name, stdin, args...)
+		return err
+	})
+	return out, errs, err
+}
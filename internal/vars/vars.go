@@ -0,0 +1,237 @@
+package vars
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"netfence/internal/model"
+	"netfence/internal/snapshot"
+)
+
+// Section — ключ верхнего уровня со значениями переменных по умолчанию.
+const Section = "vars"
+
+var nameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Load читает YAML-снапшот path и подставляет ${name} в значения. Переменные
+// берутся из секции vars: файла, затем из varFiles — более поздний файл
+// перекрывает более ранний. Неизвестная переменная — ошибка с позицией.
+func Load(path string, varFiles []string) (model.Snapshot, error) {
+	doc, err := Expand(path, varFiles)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	return snapshot.Decode(path, doc)
+}
+
+// Expand — раскрытый документ без секции vars:.
+func Expand(path string, varFiles []string) (*yaml.Node, error) {
+	doc, err := readNode(path)
+	if err != nil {
+		return nil, err
+	}
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	vals := map[string]*yaml.Node{}
+	if root.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value != Section {
+				continue
+			}
+			if err := collect(path, root.Content[i+1], vals); err != nil {
+				return nil, err
+			}
+			root.Content = append(root.Content[:i:i], root.Content[i+2:]...)
+			break
+		}
+	}
+	for _, f := range varFiles {
+		n, err := readNode(f)
+		if err != nil {
+			return nil, err
+		}
+		if len(n.Content) == 0 {
+			continue // пустой файл
+		}
+		m := n.Content[0]
+		// допускается и голый словарь, и словарь под ключом vars:
+		if m.Kind == yaml.MappingNode && len(m.Content) == 2 && m.Content[0].Value == Section {
+			m = m.Content[1]
+		}
+		if err := collect(f, m, vals); err != nil {
+			return nil, err
+		}
+	}
+	if err := expand(path, root, vals); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func readNode(path string) (*yaml.Node, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &n, nil
+}
+
+func collect(file string, m *yaml.Node, vals map[string]*yaml.Node) error {
+	if m.Kind == yaml.ScalarNode && m.Tag == "!!null" {
+		return nil
+	}
+	if m.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s:%d:%d: vars must be a mapping of name: value", file, m.Line, m.Column)
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k, v := m.Content[i], m.Content[i+1]
+		if !nameRe.MatchString(k.Value) {
+			return fmt.Errorf("%s:%d:%d: bad variable name %q", file, k.Line, k.Column, k.Value)
+		}
+		if v.Kind == yaml.MappingNode {
+			return fmt.Errorf("%s:%d:%d: variable %q: only scalars and lists are supported", file, v.Line, v.Column, k.Value)
+		}
+		vals[k.Value] = v
+	}
+	return nil
+}
+
+// expand обходит дерево. Скаляр, целиком равный ${x}, заменяется значением
+// переменной (в списке список-переменная разворачивается на месте); в
+// остальных строках ${x} подставляется как текст, $${x} даёт ${x}.
+func expand(file string, n *yaml.Node, vals map[string]*yaml.Node) error {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.MappingNode:
+		for i, c := range n.Content {
+			if n.Kind == yaml.MappingNode && i%2 == 0 {
+				continue // ключи не раскрываются
+			}
+			if err := expand(file, c, vals); err != nil {
+				return err
+			}
+		}
+		if n.Kind == yaml.MappingNode {
+			for i := 1; i < len(n.Content); i += 2 {
+				if err := replaceWhole(file, n.Content, i, vals); err != nil {
+					return err
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		out := make([]*yaml.Node, 0, len(n.Content))
+		for _, c := range n.Content {
+			if name, ok := whole(c); ok {
+				v, err := lookup(file, c, name, vals)
+				if err != nil {
+					return err
+				}
+				if v.Kind == yaml.SequenceNode {
+					out = append(out, v.Content...)
+				} else {
+					out = append(out, v)
+				}
+				continue
+			}
+			if err := expand(file, c, vals); err != nil {
+				return err
+			}
+			out = append(out, c)
+		}
+		n.Content = out
+	case yaml.ScalarNode:
+		if _, ok := whole(n); ok {
+			return nil // заменит родитель
+		}
+		if !strings.Contains(n.Value, "$") {
+			return nil
+		}
+		s, err := interpolate(n.Value, vals)
+		if err != nil {
+			return fmt.Errorf("%s:%d:%d: %w", file, n.Line, n.Column, err)
+		}
+		n.Value = s
+		n.Tag = "!!str"
+	}
+	return nil
+}
+
+func replaceWhole(file string, content []*yaml.Node, i int, vals map[string]*yaml.Node) error {
+	name, ok := whole(content[i])
+	if !ok {
+		return nil
+	}
+	v, err := lookup(file, content[i], name, vals)
+	if err != nil {
+		return err
+	}
+	content[i] = v
+	return nil
+}
+
+func lookup(file string, at *yaml.Node, name string, vals map[string]*yaml.Node) (*yaml.Node, error) {
+	v, ok := vals[name]
+	if !ok {
+		return nil, fmt.Errorf("%s:%d:%d: undefined variable %q", file, at.Line, at.Column, name)
+	}
+	return v, nil
+}
+
+// whole: скаляр вида "${name}" без другого текста.
+func whole(n *yaml.Node) (string, bool) {
+	if n.Kind != yaml.ScalarNode || !strings.HasPrefix(n.Value, "${") || !strings.HasSuffix(n.Value, "}") {
+		return "", false
+	}
+	name := n.Value[2 : len(n.Value)-1]
+	return name, nameRe.MatchString(name)
+}
+
+func interpolate(s string, vals map[string]*yaml.Node) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "$")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+		switch {
+		case strings.HasPrefix(s, "$${"):
+			b.WriteString("${")
+			s = s[3:]
+			continue
+		case !strings.HasPrefix(s, "${"):
+			b.WriteString("$")
+			s = s[1:]
+			continue
+		}
+		end := strings.Index(s, "}")
+		if end < 0 {
+			return "", errors.New("unterminated ${")
+		}
+		name := s[2:end]
+		if !nameRe.MatchString(name) {
+			return "", fmt.Errorf("bad variable name %q", name)
+		}
+		v, ok := vals[name]
+		if !ok {
+			return "", fmt.Errorf("undefined variable %q", name)
+		}
+		if v.Kind != yaml.ScalarNode {
+			return "", fmt.Errorf("variable %q is a list and must be the whole value", name)
+		}
+		b.WriteString(v.Value)
+		s = s[end+1:]
+	}
+}